@@ -1,30 +1,390 @@
 package config
 
 import (
-	"gin-boilerplate/infra/logger"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
+// Configuration is the whole application configuration. It is built once by
+// Load and handed to the components that need it.
 type Configuration struct {
-	Server   ServerConfiguration
-	Database DatabaseConfiguration
+	Server       ServerConfiguration
+	GRPC         GRPCConfiguration
+	LegacyRoutes LegacyRoutesConfiguration
+	Database     DatabaseConfiguration
+	Transaction  TransactionConfiguration
+	Stream       StreamConfiguration
+	GraphQL      GraphQLConfiguration
+	Webhook      WebhookConfiguration
+	Outbox       OutboxConfiguration
+	Purge        PurgeConfiguration
+	Tracing      TracingConfiguration
+	Log          LogConfiguration
 }
 
-// SetupConfig configuration
-func SetupConfig() error {
-	var configuration *Configuration
+// defaults lists every setting with its default value. A setting missing
+// here cannot be set from a file, the environment or a flag.
+var defaults = map[string]interface{}{
+	"SERVER_HOST":                "0.0.0.0",
+	"SERVER_PORT":                "8000",
+	"SERVER_TIMEZONE":            "Asia/Dhaka",
+	"SECRET":                     "",
+	"SECRET_FILE":                "",
+	"DEBUG":                      false,
+	"ALLOWED_HOSTS":              "",
+	"SERVER_READ_TIMEOUT":        "0s",
+	"SERVER_READ_HEADER_TIMEOUT": "10s",
+	"SERVER_WRITE_TIMEOUT":       "0s",
+	"SERVER_IDLE_TIMEOUT":        "120s",
+	"SHUTDOWN_DRAIN_DELAY":       "5s",
+	"SHUTDOWN_TIMEOUT":           "30s",
+	"READINESS_TIMEOUT":          "2s",
+	"REQUIRE_IF_MATCH":           false,
+	"CORS_ALLOWED_ORIGINS":       "",
+
+	"GRPC_HOST":          "127.0.0.1",
+	"GRPC_PORT":          "",
+	"GRPC_MAX_PAGE_SIZE": 100,
+
+	"LEGACY_ROUTES_ENABLED":       true,
+	"LEGACY_ROUTES_DEPRECATED_AT": "2026-10-19",
+	"LEGACY_ROUTES_SUNSET":        "2027-04-30",
+
+	"MASTER_DB_NAME":           "",
+	"MASTER_DB_USER":           "",
+	"MASTER_DB_PASSWORD":       "",
+	"MASTER_DB_PASSWORD_FILE":  "",
+	"MASTER_DB_HOST":           "",
+	"MASTER_DB_PORT":           "5432",
+	"MASTER_SSL_MODE":          "disable",
+	"REPLICA_DB_NAME":          "",
+	"REPLICA_DB_USER":          "",
+	"REPLICA_DB_PASSWORD":      "",
+	"REPLICA_DB_PASSWORD_FILE": "",
+	"REPLICA_DB_HOST":          "",
+	"REPLICA_DB_PORT":          "5432",
+	"REPLICA_SSL_MODE":         "disable",
+	"DB_LOG_MODE":              false,
+	"DB_MAX_OPEN_CONNS":        25,
+	"DB_MAX_IDLE_CONNS":        10,
+	"DB_CONN_MAX_LIFETIME":     "30m",
+	"DB_CONN_MAX_IDLE_TIME":    "5m",
+	"DB_CONNECT_ATTEMPTS":      10,
+	"DB_CONNECT_BACKOFF":       "1s",
+	"DB_CONNECT_TIMEOUT":       "5s",
+	"DB_READ_RETRIES":          2,
+	"DB_READ_RETRY_BACKOFF":    "100ms",
+	"DB_BREAKER_THRESHOLD":     5,
+	"DB_BREAKER_COOLDOWN":      "10s",
+
+	"TRANSACTION_MAX_AMOUNT":       0,
+	"TRANSACTION_INITIAL_STATUSES": "pending",
+	"EXPORT_MAX_ROWS":              100000,
+	"IMPORT_BATCH_SIZE":            500,
+	"IMPORT_MAX_ROWS":              10000,
+	"IMPORT_MAX_BYTES":             10 << 20,
+	"BATCH_MAX_OPERATIONS":         100,
+	"INCLUDE_DELETED_ENABLED":      false,
+
+	"STREAM_HEARTBEAT":          "15s",
+	"STREAM_HISTORY":            1000,
+	"DASHBOARD_RESYNC_INTERVAL": "1m",
+
+	"GRAPHQL_MAX_COMPLEXITY": 1000,
+	"GRAPHQL_MAX_DEPTH":      8,
+	"GRAPHQL_MAX_PAGE_SIZE":  100,
 
-	viper.SetConfigFile(".env")
-	if err := viper.ReadInConfig(); err != nil {
-		logger.Errorf("Error to reading config file, %s", err)
-		return err
+	"WEBHOOK_MAX_ATTEMPTS":  8,
+	"WEBHOOK_BASE_BACKOFF":  "30s",
+	"WEBHOOK_MAX_BACKOFF":   "6h",
+	"WEBHOOK_TIMEOUT":       "10s",
+	"WEBHOOK_POLL_INTERVAL": "5s",
+
+	"WEBHOOK_ALLOW_PRIVATE_URLS": false,
+
+	"OUTBOX_PUBLISHERS":    "log",
+	"OUTBOX_HTTP_URL":      "",
+	"OUTBOX_POLL_INTERVAL": "1s",
+	"OUTBOX_BATCH_SIZE":    100,
+
+	"PURGE_RETENTION": "2160h",
+	"PURGE_INTERVAL":  "24h",
+
+	"TRACING_EXPORTER":      "none",
+	"TRACING_SERVICE_NAME":  "gin-boilerplate",
+	"TRACING_SAMPLE_RATIO":  1.0,
+	"TRACING_OTLP_ENDPOINT": "",
+	"TRACING_OTLP_INSECURE": true,
+
+	"LOG_LEVEL":         "info",
+	"LOG_FORMAT":        "logfmt",
+	"LOG_REDACT_FIELDS": "",
+}
+
+// defaultConfigFile is read when present and no other file is asked for.
+const defaultConfigFile = ".env"
+
+// Load builds the configuration from, by increasing precedence, the defaults,
+// a config file, environment variables and the command line flags in args.
+//
+// The config file is the --config flag, else CONFIG_FILE, else .env when it
+// exists; its type (.env, .yaml, .toml, ...) follows the extension and it
+// uses the same keys as the environment. Every setting also has a flag named
+// after its key, e.g. --server-port for SERVER_PORT.
+//
+// All invalid settings are reported together in a *ValidationError.
+// pflag.ErrHelp is returned when args ask for the usage.
+func Load(args []string) (Configuration, error) {
+	v := newViper()
+
+	flags := pflag.NewFlagSet("gin-boilerplate", pflag.ContinueOnError)
+	configFile := flags.String("config", "", "config file (.env, .yaml or .toml), overrides CONFIG_FILE")
+	for _, key := range keys() {
+		flags.String(flagName(key), "", "overrides "+key)
+	}
+	if err := flags.Parse(args); err != nil {
+		return Configuration{}, err
+	}
+	for _, key := range keys() {
+		if err := v.BindPFlag(key, flags.Lookup(flagName(key))); err != nil {
+			return Configuration{}, err
+		}
 	}
 
-	err := viper.Unmarshal(&configuration)
-	if err != nil {
-		logger.Errorf("error to decode, %v", err)
-		return err
+	if err := readConfigFile(v, *configFile); err != nil {
+		return Configuration{}, err
 	}
+	v.AutomaticEnv()
 
+	r := &reader{v: v}
+	config := read(r)
+	problems := r.problems
+	config.validate(&problems)
+	if len(problems) > 0 {
+		return config, &ValidationError{Problems: problems}
+	}
+	return config, nil
+}
+
+// Default returns the configuration made of the defaults only, as used by
+// tests. It is not validated.
+func Default() Configuration {
+	return read(&reader{v: newViper()})
+}
+
+func newViper() *viper.Viper {
+	v := viper.New()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+	return v
+}
+
+func readConfigFile(v *viper.Viper, path string) error {
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		if _, err := os.Stat(defaultConfigFile); err != nil {
+			// Running on environment variables only.
+			return nil
+		}
+		path = defaultConfigFile
+	}
+
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("read config file %s: %w", path, err)
+	}
 	return nil
 }
+
+func read(r *reader) Configuration {
+	return Configuration{
+		Server:       serverConfig(r),
+		GRPC:         grpcConfig(r),
+		LegacyRoutes: legacyRoutesConfig(r),
+		Database:     databaseConfig(r),
+		Transaction:  transactionConfig(r),
+		Stream:       streamConfig(r),
+		GraphQL:      graphQLConfig(r),
+		Webhook:      webhookConfig(r),
+		Outbox:       outboxConfig(r),
+		Purge:        purgeConfig(r),
+		Tracing:      tracingConfig(r),
+		Log:          logConfig(r),
+	}
+}
+
+func (c Configuration) validate(p *problems) {
+	c.Server.validate(p)
+	c.GRPC.validate(p)
+	c.LegacyRoutes.validate(p)
+	c.Database.validate(p, c.Server.Debug)
+	c.Transaction.validate(p)
+	c.Stream.validate(p)
+	c.GraphQL.validate(p)
+	c.Webhook.validate(p)
+	c.Outbox.validate(p)
+	c.Purge.validate(p)
+	c.Tracing.validate(p)
+	c.Log.validate(p)
+}
+
+func keys() []string {
+	keys := make([]string, 0, len(defaults))
+	for key := range defaults {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// ValidationError lists every invalid setting found by Load, one
+// "KEY: problem" per entry.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// IsHelp reports whether err is the request for usage returned by Load.
+func IsHelp(err error) bool {
+	return errors.Is(err, pflag.ErrHelp)
+}
+
+// problems collects one "KEY: problem" per invalid setting; a value that
+// does not parse is not validated any further.
+type problems []string
+
+func (p *problems) add(key, format string, args ...interface{}) {
+	for _, problem := range *p {
+		if strings.HasPrefix(problem, key+": ") {
+			return
+		}
+	}
+	*p = append(*p, key+": "+fmt.Sprintf(format, args...))
+}
+
+// positive reports a setting that must be greater than zero.
+func (p *problems) positive(key string, value int64) {
+	if value <= 0 {
+		p.add(key, "must be greater than 0, got %d", value)
+	}
+}
+
+// notNegative reports a duration or number that must be zero or more.
+func (p *problems) notNegative(key string, value int64) {
+	if value < 0 {
+		p.add(key, "must not be negative, got %d", value)
+	}
+}
+
+func (p *problems) positiveDuration(key string, value time.Duration) {
+	if value <= 0 {
+		p.add(key, "must be greater than 0, got %s", value)
+	}
+}
+
+func (p *problems) notNegativeDuration(key string, value time.Duration) {
+	if value < 0 {
+		p.add(key, "must not be negative, got %s", value)
+	}
+}
+
+func (p *problems) oneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	p.add(key, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+}
+
+// reader reads typed settings, recording the values that do not parse.
+type reader struct {
+	v        *viper.Viper
+	problems problems
+}
+
+func (r *reader) string(key string) string {
+	return strings.TrimSpace(r.v.GetString(key))
+}
+
+func (r *reader) int(key string) int {
+	value, err := cast.ToIntE(r.v.Get(key))
+	if err != nil {
+		r.problems.add(key, "must be an integer, got %q", r.v.GetString(key))
+	}
+	return value
+}
+
+func (r *reader) int64(key string) int64 {
+	value, err := cast.ToInt64E(r.v.Get(key))
+	if err != nil {
+		r.problems.add(key, "must be an integer, got %q", r.v.GetString(key))
+	}
+	return value
+}
+
+func (r *reader) float64(key string) float64 {
+	value, err := cast.ToFloat64E(r.v.Get(key))
+	if err != nil {
+		r.problems.add(key, "must be a number, got %q", r.v.GetString(key))
+	}
+	return value
+}
+
+func (r *reader) bool(key string) bool {
+	value, err := cast.ToBoolE(r.v.Get(key))
+	if err != nil {
+		r.problems.add(key, "must be true or false, got %q", r.v.GetString(key))
+	}
+	return value
+}
+
+func (r *reader) duration(key string) time.Duration {
+	value, err := cast.ToDurationE(r.v.Get(key))
+	if err != nil {
+		r.problems.add(key, "must be a duration such as 30s or 5m, got %q", r.v.GetString(key))
+	}
+	return value
+}
+
+// list reads a comma separated setting, skipping empty items.
+func (r *reader) list(key string) []string {
+	var items []string
+	for _, item := range strings.Split(r.v.GetString(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// date reads a YYYY-MM-DD date in UTC; empty is the zero time.
+func (r *reader) date(key string) time.Time {
+	value := r.string(key)
+	if value == "" {
+		return time.Time{}
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		r.problems.add(key, "must be a YYYY-MM-DD date, got %q", value)
+	}
+	return date
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setDatabaseEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("MASTER_DB_HOST", "db")
+	t.Setenv("MASTER_DB_NAME", "app")
+	t.Setenv("MASTER_DB_USER", "app")
+	t.Setenv("SECRET", "test-secret")
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	setDatabaseEnv(t)
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("SERVER_PORT: 9000\nSTREAM_HISTORY: 50\nGRAPHQL_MAX_DEPTH: 5\n"), 0o600))
+	t.Setenv("SERVER_PORT", "9100")
+
+	cfg, err := Load([]string{"--config", file, "--graphql-max-depth", "6"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 50, cfg.Stream.History, "file over default")
+	assert.Equal(t, "9100", cfg.Server.Port, "environment over file")
+	assert.Equal(t, 6, cfg.GraphQL.MaxDepth, "flag over file")
+	assert.Equal(t, 15*time.Second, cfg.Stream.Heartbeat, "default")
+	assert.Equal(t, "0.0.0.0:9100", cfg.Server.Addr())
+}
+
+func TestLoad_DotEnvFile(t *testing.T) {
+	setDatabaseEnv(t)
+	file := filepath.Join(t.TempDir(), "app.env")
+	require.NoError(t, os.WriteFile(file, []byte("DEBUG=True\nTRANSACTION_INITIAL_STATUSES=pending, success\nLEGACY_ROUTES_SUNSET=\n"), 0o600))
+	t.Setenv("CONFIG_FILE", file)
+
+	cfg, err := Load(nil)
+	require.NoError(t, err)
+	assert.True(t, cfg.Server.Debug)
+	assert.False(t, cfg.Database.UseReplica(cfg.Server.Debug))
+	assert.Equal(t, []string{"pending", "success"}, cfg.Transaction.InitialStatuses)
+	assert.True(t, cfg.LegacyRoutes.Sunset.IsZero())
+}
+
+func TestLoad_WithoutConfigFile(t *testing.T) {
+	setDatabaseEnv(t)
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+
+	_, err = Load(nil)
+	require.NoError(t, err)
+
+	_, err = Load([]string{"--config", "missing.yaml"})
+	assert.Error(t, err)
+}
+
+func TestLoad_ReportsEveryProblem(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("SERVER_PORT", "http")
+	t.Setenv("WEBHOOK_TIMEOUT", "soon")
+	t.Setenv("OUTBOX_PUBLISHERS", "log,kafka")
+	t.Setenv("LOG_FORMAT", "xml")
+	t.Setenv("TRANSACTION_INITIAL_STATUSES", "pending,refunded")
+
+	_, err := Load(nil)
+	var validation *ValidationError
+	require.True(t, errors.As(err, &validation), "got %v", err)
+	assert.ElementsMatch(t, []string{
+		`SERVER_PORT: must be a port between 1 and 65535, got "http"`,
+		`WEBHOOK_TIMEOUT: must be a duration such as 30s or 5m, got "soon"`,
+		`OUTBOX_PUBLISHERS: must be one of log, http, memory, got "kafka"`,
+		`LOG_FORMAT: must be one of logfmt, json, got "xml"`,
+		`TRANSACTION_INITIAL_STATUSES: must be one of success, pending, failed, got "refunded"`,
+		`MASTER_DB_HOST: is required`,
+		`MASTER_DB_NAME: is required`,
+		`MASTER_DB_USER: is required`,
+		`SECRET: is required outside debug mode`,
+	}, validation.Problems)
+}
+
+func TestLoad_ValidatesDatabasePool(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("MASTER_DB_HOST", "localhost")
+	t.Setenv("MASTER_DB_NAME", "app")
+	t.Setenv("MASTER_DB_USER", "app")
+	t.Setenv("SECRET", "test-secret")
+	t.Setenv("DB_MAX_OPEN_CONNS", "5")
+	t.Setenv("DB_MAX_IDLE_CONNS", "10")
+	t.Setenv("DB_CONNECT_ATTEMPTS", "0")
+	t.Setenv("DB_BREAKER_COOLDOWN", "0s")
+
+	_, err := Load(nil)
+	var validation *ValidationError
+	require.True(t, errors.As(err, &validation), "got %v", err)
+	assert.ElementsMatch(t, []string{
+		`DB_MAX_IDLE_CONNS: must not be more than DB_MAX_OPEN_CONNS`,
+		`DB_CONNECT_ATTEMPTS: must be greater than 0, got 0`,
+		`DB_BREAKER_COOLDOWN: must be greater than 0, got 0s`,
+	}, validation.Problems)
+}
+
+func TestLoad_Help(t *testing.T) {
+	_, err := Load([]string{"--help"})
+	assert.True(t, IsHelp(err))
+}
+
+func TestSecret_IsRedacted(t *testing.T) {
+	secret := Secret("hunter2")
+	assert.Equal(t, "hunter2", secret.Value())
+	assert.Equal(t, "[REDACTED] [REDACTED] \"[REDACTED]\"", fmt.Sprintf("%s %v %#v", secret, secret, secret))
+
+	data, err := json.Marshal(DatabaseEndpoint{Host: "db", Password: secret})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "hunter2")
+	assert.Contains(t, string(data), `"Password":"[REDACTED]"`)
+	assert.Equal(t, "", Secret("").String())
+}
+
+func TestLoad_SecretFiles(t *testing.T) {
+	setDatabaseEnv(t)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret"), []byte("from-file\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "db"), []byte("it's a pass"), 0o600))
+	t.Setenv("SECRET", "from-env")
+	t.Setenv("SECRET_FILE", filepath.Join(dir, "secret"))
+	t.Setenv("MASTER_DB_PASSWORD_FILE", filepath.Join(dir, "db"))
+	t.Setenv("REPLICA_DB_PASSWORD_FILE", filepath.Join(dir, "missing"))
+
+	cfg, err := Load(nil)
+	var validation *ValidationError
+	require.True(t, errors.As(err, &validation), "got %v", err)
+	require.Len(t, validation.Problems, 1)
+	assert.Contains(t, validation.Problems[0], "REPLICA_DB_PASSWORD_FILE: ")
+
+	assert.Equal(t, "from-file", cfg.Server.Secret.Value())
+	assert.Equal(t, "it's a pass", cfg.Database.Master.Password.Value())
+	assert.Contains(t, cfg.Database.Master.DSN(), `password='it\'s a pass'`)
+	assert.Contains(t, cfg.Database.Master.DSN(), "connect_timeout=5")
+	assert.NotContains(t, fmt.Sprintf("%+v", cfg), "it's a pass")
+}
+
+func TestLoad_RefusesExampleSecret(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	example, err := os.ReadFile("../.env.example")
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "example.env")
+	require.NoError(t, os.WriteFile(file, example, 0o600))
+
+	_, err = Load([]string{"--config", file})
+	var validation *ValidationError
+	require.True(t, errors.As(err, &validation), "got %v", err)
+	assert.Equal(t, []string{"SECRET: is the example value from .env.example, set your own outside debug mode"}, validation.Problems)
+
+	t.Setenv("SECRET", exampleSecrets[0])
+	_, err = Load([]string{"--config", file})
+	require.True(t, errors.As(err, &validation), "got %v", err)
+	assert.Len(t, validation.Problems, 1)
+
+	_, err = Load([]string{"--config", file, "--debug", "true"})
+	assert.NoError(t, err)
+}
@@ -2,42 +2,137 @@ package config
 
 import (
 	"fmt"
-	"github.com/spf13/viper"
+	"strings"
+	"time"
 )
 
 type DatabaseConfiguration struct {
-	Driver   string
+	Master  DatabaseEndpoint
+	Replica DatabaseEndpoint
+	LogMode bool
+
+	// Pool settings of the master and the replica; 0 open connections is no
+	// limit.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// ConnectAttempts and ConnectBackoff retry the connection at startup,
+	// doubling the backoff after every attempt.
+	ConnectAttempts int
+	ConnectBackoff  time.Duration
+	// ReadRetries and ReadRetryBackoff retry SELECTs outside transactions
+	// that fail because the database is unavailable.
+	ReadRetries      int
+	ReadRetryBackoff time.Duration
+	// After BreakerThreshold unavailable errors in a row on the master or
+	// the replica, statements and transactions on that one fail fast for
+	// BreakerCooldown. 0 disables the circuit breakers.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// DatabaseEndpoint is where one PostgreSQL server is reached.
+type DatabaseEndpoint struct {
 	Dbname   string
 	Username string
-	Password string
+	Password Secret
 	Host     string
 	Port     string
-	LogMode  bool
+	SSLMode  string
+	// ConnectTimeout bounds opening a connection, so an unreachable host
+	// fails before the OS gives up on TCP; 0 waits forever.
+	ConnectTimeout time.Duration
 }
 
-func DbConfiguration() (string, string) {
-	masterDBName := viper.GetString("MASTER_DB_NAME")
-	masterDBUser := viper.GetString("MASTER_DB_USER")
-	masterDBPassword := viper.GetString("MASTER_DB_PASSWORD")
-	masterDBHost := viper.GetString("MASTER_DB_HOST")
-	masterDBPort := viper.GetString("MASTER_DB_PORT")
-	masterDBSslMode := viper.GetString("MASTER_SSL_MODE")
+func databaseConfig(r *reader) DatabaseConfiguration {
+	return DatabaseConfiguration{
+		Master:  databaseEndpoint(r, "MASTER_DB_", "MASTER_"),
+		Replica: databaseEndpoint(r, "REPLICA_DB_", "REPLICA_"),
+		LogMode: r.bool("DB_LOG_MODE"),
 
-	replicaDBName := viper.GetString("REPLICA_DB_NAME")
-	replicaDBUser := viper.GetString("REPLICA_DB_USER")
-	replicaDBPassword := viper.GetString("REPLICA_DB_PASSWORD")
-	replicaDBHost := viper.GetString("REPLICA_DB_HOST")
-	replicaDBPort := viper.GetString("REPLICA_DB_PORT")
-	replicaDBSslMode := viper.GetString("REPLICA_SSL_MODE")
+		MaxOpenConns:     r.int("DB_MAX_OPEN_CONNS"),
+		MaxIdleConns:     r.int("DB_MAX_IDLE_CONNS"),
+		ConnMaxLifetime:  r.duration("DB_CONN_MAX_LIFETIME"),
+		ConnMaxIdleTime:  r.duration("DB_CONN_MAX_IDLE_TIME"),
+		ConnectAttempts:  r.int("DB_CONNECT_ATTEMPTS"),
+		ConnectBackoff:   r.duration("DB_CONNECT_BACKOFF"),
+		ReadRetries:      r.int("DB_READ_RETRIES"),
+		ReadRetryBackoff: r.duration("DB_READ_RETRY_BACKOFF"),
+		BreakerThreshold: r.int("DB_BREAKER_THRESHOLD"),
+		BreakerCooldown:  r.duration("DB_BREAKER_COOLDOWN"),
+	}
+}
 
-	masterDBDSN := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
-		masterDBHost, masterDBUser, masterDBPassword, masterDBName, masterDBPort, masterDBSslMode,
-	)
+func databaseEndpoint(r *reader, prefix, sslPrefix string) DatabaseEndpoint {
+	return DatabaseEndpoint{
+		Dbname:   r.string(prefix + "NAME"),
+		Username: r.string(prefix + "USER"),
+		Password: r.secret(prefix + "PASSWORD"),
+		Host:     r.string(prefix + "HOST"),
+		Port:     r.string(prefix + "PORT"),
+		SSLMode:  r.string(sslPrefix + "SSL_MODE"),
+
+		ConnectTimeout: r.duration("DB_CONNECT_TIMEOUT"),
+	}
+}
 
-	replicaDBDSN := fmt.Sprintf(
+// DSN is the connection string of the endpoint. It holds the password, so
+// it must not be logged.
+func (e DatabaseEndpoint) DSN() string {
+	dsn := fmt.Sprintf(
 		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
-		replicaDBHost, replicaDBUser, replicaDBPassword, replicaDBName, replicaDBPort, replicaDBSslMode,
+		dsnValue(e.Host), dsnValue(e.Username), dsnValue(e.Password.Value()), dsnValue(e.Dbname), dsnValue(e.Port), dsnValue(e.SSLMode),
 	)
-	return masterDBDSN, replicaDBDSN
+	if e.ConnectTimeout > 0 {
+		// connect_timeout is in whole seconds.
+		seconds := int64((e.ConnectTimeout + time.Second - 1) / time.Second)
+		dsn += fmt.Sprintf(" connect_timeout=%d", seconds)
+	}
+	return dsn
+}
+
+// dsnValue quotes a DSN value, so passwords may contain spaces and quotes.
+func dsnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// UseReplica reports whether reads go to the replica: never in debug mode,
+// nor when REPLICA_DB_HOST is empty.
+func (c DatabaseConfiguration) UseReplica(debug bool) bool {
+	return !debug && c.Replica.Host != ""
+}
+
+func (c DatabaseConfiguration) validate(p *problems, debug bool) {
+	c.Master.validate(p, "MASTER_DB_", "MASTER_")
+	if c.UseReplica(debug) {
+		c.Replica.validate(p, "REPLICA_DB_", "REPLICA_")
+	}
+
+	p.notNegative("DB_MAX_OPEN_CONNS", int64(c.MaxOpenConns))
+	p.notNegative("DB_MAX_IDLE_CONNS", int64(c.MaxIdleConns))
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		p.add("DB_MAX_IDLE_CONNS", "must not be more than DB_MAX_OPEN_CONNS")
+	}
+	p.notNegativeDuration("DB_CONN_MAX_LIFETIME", c.ConnMaxLifetime)
+	p.notNegativeDuration("DB_CONN_MAX_IDLE_TIME", c.ConnMaxIdleTime)
+	p.positive("DB_CONNECT_ATTEMPTS", int64(c.ConnectAttempts))
+	p.positiveDuration("DB_CONNECT_BACKOFF", c.ConnectBackoff)
+	p.notNegativeDuration("DB_CONNECT_TIMEOUT", c.Master.ConnectTimeout)
+	p.notNegative("DB_READ_RETRIES", int64(c.ReadRetries))
+	p.positiveDuration("DB_READ_RETRY_BACKOFF", c.ReadRetryBackoff)
+	p.notNegative("DB_BREAKER_THRESHOLD", int64(c.BreakerThreshold))
+	p.positiveDuration("DB_BREAKER_COOLDOWN", c.BreakerCooldown)
+}
+
+func (e DatabaseEndpoint) validate(p *problems, prefix, sslPrefix string) {
+	required := []struct{ key, value string }{{"HOST", e.Host}, {"NAME", e.Dbname}, {"USER", e.Username}}
+	for _, setting := range required {
+		if setting.value == "" {
+			p.add(prefix+setting.key, "is required")
+		}
+	}
+	validatePort(p, prefix+"PORT", e.Port)
+	p.oneOf(sslPrefix+"SSL_MODE", e.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
 }
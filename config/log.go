@@ -0,0 +1,30 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogConfiguration is the log level, the format ("logfmt" or "json") and the
+// extra field names to redact (LOG_REDACT_FIELDS, comma separated).
+type LogConfiguration struct {
+	Level  string
+	Format string
+	Redact []string
+}
+
+func logConfig(r *reader) LogConfiguration {
+	return LogConfiguration{
+		Level:  strings.ToLower(r.string("LOG_LEVEL")),
+		Format: strings.ToLower(r.string("LOG_FORMAT")),
+		Redact: r.list("LOG_REDACT_FIELDS"),
+	}
+}
+
+func (c LogConfiguration) validate(p *problems) {
+	if _, err := logrus.ParseLevel(c.Level); err != nil {
+		p.add("LOG_LEVEL", "must be one of debug, info, warn, error, got %q", c.Level)
+	}
+	p.oneOf("LOG_FORMAT", c.Format, "logfmt", "json")
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"time"
+)
+
+// OutboxConfiguration is how the outbox relay publishes events.
+type OutboxConfiguration struct {
+	// Publishers are the external publishers events are relayed to, besides
+	// webhooks: any of "log", "http" and "memory".
+	Publishers   []string
+	HTTPURL      string
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+func outboxConfig(r *reader) OutboxConfiguration {
+	return OutboxConfiguration{
+		Publishers:   r.list("OUTBOX_PUBLISHERS"),
+		HTTPURL:      r.string("OUTBOX_HTTP_URL"),
+		PollInterval: r.duration("OUTBOX_POLL_INTERVAL"),
+		BatchSize:    r.int("OUTBOX_BATCH_SIZE"),
+	}
+}
+
+func (c OutboxConfiguration) validate(p *problems) {
+	for _, publisher := range c.Publishers {
+		p.oneOf("OUTBOX_PUBLISHERS", publisher, "log", "http", "memory")
+		if publisher == "http" && c.HTTPURL == "" {
+			p.add("OUTBOX_HTTP_URL", "is required by the http publisher")
+		}
+	}
+	p.positiveDuration("OUTBOX_POLL_INTERVAL", c.PollInterval)
+	p.positive("OUTBOX_BATCH_SIZE", int64(c.BatchSize))
+}
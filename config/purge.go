@@ -0,0 +1,26 @@
+package config
+
+import (
+	"time"
+)
+
+// PurgeConfiguration is how long soft deleted transactions are kept and how
+// often the purge job runs. A zero Retention disables purging.
+type PurgeConfiguration struct {
+	Retention time.Duration
+	Interval  time.Duration
+}
+
+func purgeConfig(r *reader) PurgeConfiguration {
+	return PurgeConfiguration{
+		Retention: r.duration("PURGE_RETENTION"),
+		Interval:  r.duration("PURGE_INTERVAL"),
+	}
+}
+
+func (c PurgeConfiguration) validate(p *problems) {
+	p.notNegativeDuration("PURGE_RETENTION", c.Retention)
+	if c.Retention > 0 {
+		p.positiveDuration("PURGE_INTERVAL", c.Interval)
+	}
+}
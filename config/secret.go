@@ -0,0 +1,52 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+const redactedSecret = "[REDACTED]"
+
+// exampleSecrets are the SECRET of .env.example, refused outside debug
+// mode: as written, and as read from a .env copy, where "#" starts a
+// comment.
+var exampleSecrets = []string{"h9wt*pasj6796j##w(w8=xaje8tpi6h*r&hzgrz065u&ed+k2)", "h9wt*pasj6796j"}
+
+// Secret is a setting that must not end up in logs: printing or marshalling
+// it gives "[REDACTED]" (or "" when it is empty). Value returns the secret.
+type Secret string
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redactedSecret
+}
+
+func (s Secret) GoString() string {
+	return `"` + s.String() + `"`
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// secret reads key, or the file named by key_FILE when that is set, as with
+// Docker and Kubernetes secrets. The trailing newline of the file is
+// dropped.
+func (r *reader) secret(key string) Secret {
+	path := r.string(key + "_FILE")
+	if path == "" {
+		return Secret(r.v.GetString(key))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		r.problems.add(key+"_FILE", "%s", err)
+		return ""
+	}
+	return Secret(strings.TrimRight(string(data), "\r\n"))
+}
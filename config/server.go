@@ -2,21 +2,162 @@ package config
 
 import (
 	"fmt"
-	"github.com/spf13/viper"
-	"log"
+	"strconv"
+	"time"
 )
 
 type ServerConfiguration struct {
-	Port                 string
-	Secret               string
-	LimitCountPerRequest int64
+	Host     string
+	Port     string
+	Location *time.Location
+	Debug    bool
+	// AllowedHosts are the proxies whose forwarded client IP is trusted.
+	AllowedHosts []string
+	// AllowedOrigins may call the API from a browser (CORS) and open the
+	// live dashboard socket; empty allows CORS from anywhere and the socket
+	// from the same origin only.
+	AllowedOrigins []string
+	Secret         Secret
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// DrainDelay is how long the server keeps serving after readiness turns
+	// false, so load balancers stop sending new requests first.
+	DrainDelay time.Duration
+	// ShutdownTimeout bounds the wait for in-flight requests and workers.
+	ShutdownTimeout time.Duration
+	// ReadinessTimeout bounds each dependency check of GET /readyz.
+	ReadinessTimeout time.Duration
+	// RequireIfMatch makes PUT and DELETE send an If-Match header, and
+	// batch status updates a version.
+	RequireIfMatch bool
+}
+
+// serverConfig reads the HTTP server settings. Read and write timeouts cover
+// the whole request, so they are off by default: a non-zero value also ends
+// SSE streams and long exports.
+func serverConfig(r *reader) ServerConfiguration {
+	timezone := r.string("SERVER_TIMEZONE")
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		r.problems.add("SERVER_TIMEZONE", "unknown time zone %q", timezone)
+		location = time.Local
+	}
+
+	return ServerConfiguration{
+		Host:              r.string("SERVER_HOST"),
+		Port:              r.string("SERVER_PORT"),
+		Location:          location,
+		Debug:             r.bool("DEBUG"),
+		AllowedHosts:      r.list("ALLOWED_HOSTS"),
+		AllowedOrigins:    r.list("CORS_ALLOWED_ORIGINS"),
+		Secret:            r.secret("SECRET"),
+		ReadTimeout:       r.duration("SERVER_READ_TIMEOUT"),
+		ReadHeaderTimeout: r.duration("SERVER_READ_HEADER_TIMEOUT"),
+		WriteTimeout:      r.duration("SERVER_WRITE_TIMEOUT"),
+		IdleTimeout:       r.duration("SERVER_IDLE_TIMEOUT"),
+		DrainDelay:        r.duration("SHUTDOWN_DRAIN_DELAY"),
+		ShutdownTimeout:   r.duration("SHUTDOWN_TIMEOUT"),
+		ReadinessTimeout:  r.duration("READINESS_TIMEOUT"),
+		RequireIfMatch:    r.bool("REQUIRE_IF_MATCH"),
+	}
+}
+
+// Addr is the host:port the HTTP server listens on.
+func (c ServerConfiguration) Addr() string {
+	return fmt.Sprintf("%s:%s", c.Host, c.Port)
+}
+
+func (c ServerConfiguration) validate(p *problems) {
+	validatePort(p, "SERVER_PORT", c.Port)
+	c.validateSecret(p)
+	p.notNegativeDuration("SERVER_READ_TIMEOUT", c.ReadTimeout)
+	p.notNegativeDuration("SERVER_READ_HEADER_TIMEOUT", c.ReadHeaderTimeout)
+	p.notNegativeDuration("SERVER_WRITE_TIMEOUT", c.WriteTimeout)
+	p.notNegativeDuration("SERVER_IDLE_TIMEOUT", c.IdleTimeout)
+	p.notNegativeDuration("SHUTDOWN_DRAIN_DELAY", c.DrainDelay)
+	p.positiveDuration("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	p.positiveDuration("READINESS_TIMEOUT", c.ReadinessTimeout)
+}
+
+// validateSecret requires a SECRET of your own outside debug mode.
+func (c ServerConfiguration) validateSecret(p *problems) {
+	if c.Debug {
+		return
+	}
+	if c.Secret.Value() == "" {
+		p.add("SECRET", "is required outside debug mode")
+		return
+	}
+	for _, example := range exampleSecrets {
+		if c.Secret.Value() == example {
+			p.add("SECRET", "is the example value from .env.example, set your own outside debug mode")
+			return
+		}
+	}
+}
+
+// GRPCConfiguration is where the gRPC API listens and the largest page
+// ListTransactions may request. The API has no TLS or authentication, so it
+// is off unless GRPC_PORT is set and only listens on localhost by default.
+type GRPCConfiguration struct {
+	Host        string
+	Port        string
+	MaxPageSize int
 }
 
-func ServerConfig() string {
-	viper.SetDefault("SERVER_HOST", "0.0.0.0")
-	viper.SetDefault("SERVER_PORT", "8000")
+func grpcConfig(r *reader) GRPCConfiguration {
+	return GRPCConfiguration{
+		Host:        r.string("GRPC_HOST"),
+		Port:        r.string("GRPC_PORT"),
+		MaxPageSize: r.int("GRPC_MAX_PAGE_SIZE"),
+	}
+}
+
+// Addr returns the address of the gRPC API, or "" when GRPC_PORT is empty
+// and the gRPC API is disabled.
+func (c GRPCConfiguration) Addr() string {
+	if c.Port == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s", c.Host, c.Port)
+}
+
+func (c GRPCConfiguration) validate(p *problems) {
+	if c.Port != "" {
+		validatePort(p, "GRPC_PORT", c.Port)
+	}
+	p.positive("GRPC_MAX_PAGE_SIZE", int64(c.MaxPageSize))
+}
+
+type LegacyRoutesConfiguration struct {
+	Enabled      bool
+	DeprecatedAt time.Time
+	Sunset       time.Time
+}
+
+// legacyRoutesConfig reads whether the unversioned routes are still served
+// as deprecated aliases of /api/v1, and the dates (YYYY-MM-DD, UTC) announced
+// in their Deprecation and Sunset headers. An empty LEGACY_ROUTES_SUNSET
+// announces no removal date.
+func legacyRoutesConfig(r *reader) LegacyRoutesConfiguration {
+	return LegacyRoutesConfiguration{
+		Enabled:      r.bool("LEGACY_ROUTES_ENABLED"),
+		DeprecatedAt: r.date("LEGACY_ROUTES_DEPRECATED_AT"),
+		Sunset:       r.date("LEGACY_ROUTES_SUNSET"),
+	}
+}
+
+func (c LegacyRoutesConfiguration) validate(p *problems) {
+	if !c.Sunset.IsZero() && !c.DeprecatedAt.IsZero() && !c.Sunset.After(c.DeprecatedAt) {
+		p.add("LEGACY_ROUTES_SUNSET", "must be after LEGACY_ROUTES_DEPRECATED_AT")
+	}
+}
 
-	appServer := fmt.Sprintf("%s:%s", viper.GetString("SERVER_HOST"), viper.GetString("SERVER_PORT"))
-	log.Print("Server Running at :", appServer)
-	return appServer
+func validatePort(p *problems, key, port string) {
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		p.add(key, "must be a port between 1 and 65535, got %q", port)
+	}
 }
@@ -0,0 +1,28 @@
+package config
+
+// TracingConfiguration is where traces are exported: "otlp", "stdout" or
+// "none" (the default, so the app runs without a collector).
+type TracingConfiguration struct {
+	Exporter     string
+	ServiceName  string
+	SampleRatio  float64
+	OTLPEndpoint string
+	OTLPInsecure bool
+}
+
+func tracingConfig(r *reader) TracingConfiguration {
+	return TracingConfiguration{
+		Exporter:     r.string("TRACING_EXPORTER"),
+		ServiceName:  r.string("TRACING_SERVICE_NAME"),
+		SampleRatio:  r.float64("TRACING_SAMPLE_RATIO"),
+		OTLPEndpoint: r.string("TRACING_OTLP_ENDPOINT"),
+		OTLPInsecure: r.bool("TRACING_OTLP_INSECURE"),
+	}
+}
+
+func (c TracingConfiguration) validate(p *problems) {
+	p.oneOf("TRACING_EXPORTER", c.Exporter, "none", "stdout", "otlp")
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		p.add("TRACING_SAMPLE_RATIO", "must be between 0 and 1, got %g", c.SampleRatio)
+	}
+}
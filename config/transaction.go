@@ -0,0 +1,100 @@
+package config
+
+import (
+	"time"
+)
+
+type TransactionConfiguration struct {
+	// MaxAmount caps a single transaction; 0 for no cap.
+	MaxAmount int
+	// InitialStatuses are the statuses a new transaction may start with.
+	InitialStatuses []string
+	// ExportMaxRows is the maximum number of rows a single export may return.
+	ExportMaxRows int
+	// ImportBatchSize, ImportMaxRows and ImportMaxBytes bound a bulk import.
+	ImportBatchSize int
+	ImportMaxRows   int
+	ImportMaxBytes  int64
+	// BatchMaxOperations is the maximum number of operations in a batch
+	// request.
+	BatchMaxOperations int
+	// IncludeDeletedEnabled lets lists and exports ask for soft deleted
+	// transactions and enables restoring them. Nothing is authenticated, so
+	// it is off by default.
+	IncludeDeletedEnabled bool
+}
+
+func transactionConfig(r *reader) TransactionConfiguration {
+	return TransactionConfiguration{
+		MaxAmount:             r.int("TRANSACTION_MAX_AMOUNT"),
+		InitialStatuses:       r.list("TRANSACTION_INITIAL_STATUSES"),
+		ExportMaxRows:         r.int("EXPORT_MAX_ROWS"),
+		ImportBatchSize:       r.int("IMPORT_BATCH_SIZE"),
+		ImportMaxRows:         r.int("IMPORT_MAX_ROWS"),
+		ImportMaxBytes:        r.int64("IMPORT_MAX_BYTES"),
+		BatchMaxOperations:    r.int("BATCH_MAX_OPERATIONS"),
+		IncludeDeletedEnabled: r.bool("INCLUDE_DELETED_ENABLED"),
+	}
+}
+
+func (c TransactionConfiguration) validate(p *problems) {
+	p.notNegative("TRANSACTION_MAX_AMOUNT", int64(c.MaxAmount))
+	if len(c.InitialStatuses) == 0 {
+		p.add("TRANSACTION_INITIAL_STATUSES", "must list at least one status")
+	}
+	for _, status := range c.InitialStatuses {
+		p.oneOf("TRANSACTION_INITIAL_STATUSES", status, "success", "pending", "failed")
+	}
+	p.positive("EXPORT_MAX_ROWS", int64(c.ExportMaxRows))
+	p.positive("IMPORT_BATCH_SIZE", int64(c.ImportBatchSize))
+	p.positive("IMPORT_MAX_ROWS", int64(c.ImportMaxRows))
+	p.positive("IMPORT_MAX_BYTES", c.ImportMaxBytes)
+	p.positive("BATCH_MAX_OPERATIONS", int64(c.BatchMaxOperations))
+}
+
+type StreamConfiguration struct {
+	// Heartbeat is the heartbeat interval of GET /transaction/stream.
+	Heartbeat time.Duration
+	// History is how many events are kept for clients resuming with
+	// Last-Event-ID.
+	History int
+	// DashboardResync is how often live dashboard connections get a fresh
+	// snapshot, correcting drift from changes that are not pushed as deltas.
+	DashboardResync time.Duration
+}
+
+func streamConfig(r *reader) StreamConfiguration {
+	return StreamConfiguration{
+		Heartbeat:       r.duration("STREAM_HEARTBEAT"),
+		History:         r.int("STREAM_HISTORY"),
+		DashboardResync: r.duration("DASHBOARD_RESYNC_INTERVAL"),
+	}
+}
+
+func (c StreamConfiguration) validate(p *problems) {
+	p.positiveDuration("STREAM_HEARTBEAT", c.Heartbeat)
+	p.notNegative("STREAM_HISTORY", int64(c.History))
+	p.positiveDuration("DASHBOARD_RESYNC_INTERVAL", c.DashboardResync)
+}
+
+// GraphQLConfiguration bounds the complexity and depth of a /graphql query
+// and the largest page it may request.
+type GraphQLConfiguration struct {
+	MaxComplexity int
+	MaxDepth      int
+	MaxPageSize   int
+}
+
+func graphQLConfig(r *reader) GraphQLConfiguration {
+	return GraphQLConfiguration{
+		MaxComplexity: r.int("GRAPHQL_MAX_COMPLEXITY"),
+		MaxDepth:      r.int("GRAPHQL_MAX_DEPTH"),
+		MaxPageSize:   r.int("GRAPHQL_MAX_PAGE_SIZE"),
+	}
+}
+
+func (c GraphQLConfiguration) validate(p *problems) {
+	p.positive("GRAPHQL_MAX_COMPLEXITY", int64(c.MaxComplexity))
+	p.positive("GRAPHQL_MAX_DEPTH", int64(c.MaxDepth))
+	p.positive("GRAPHQL_MAX_PAGE_SIZE", int64(c.MaxPageSize))
+}
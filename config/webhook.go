@@ -0,0 +1,40 @@
+package config
+
+import (
+	"time"
+)
+
+// WebhookConfiguration is how webhook deliveries are sent and retried.
+type WebhookConfiguration struct {
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	Timeout      time.Duration
+	PollInterval time.Duration
+	// AllowPrivateURLs lets webhooks call loopback and internal addresses,
+	// for local development only.
+	AllowPrivateURLs bool
+}
+
+func webhookConfig(r *reader) WebhookConfiguration {
+	return WebhookConfiguration{
+		MaxAttempts:  r.int("WEBHOOK_MAX_ATTEMPTS"),
+		BaseBackoff:  r.duration("WEBHOOK_BASE_BACKOFF"),
+		MaxBackoff:   r.duration("WEBHOOK_MAX_BACKOFF"),
+		Timeout:      r.duration("WEBHOOK_TIMEOUT"),
+		PollInterval: r.duration("WEBHOOK_POLL_INTERVAL"),
+
+		AllowPrivateURLs: r.bool("WEBHOOK_ALLOW_PRIVATE_URLS"),
+	}
+}
+
+func (c WebhookConfiguration) validate(p *problems) {
+	p.positive("WEBHOOK_MAX_ATTEMPTS", int64(c.MaxAttempts))
+	p.positiveDuration("WEBHOOK_BASE_BACKOFF", c.BaseBackoff)
+	p.positiveDuration("WEBHOOK_MAX_BACKOFF", c.MaxBackoff)
+	p.positiveDuration("WEBHOOK_TIMEOUT", c.Timeout)
+	p.positiveDuration("WEBHOOK_POLL_INTERVAL", c.PollInterval)
+	if c.MaxBackoff < c.BaseBackoff {
+		p.add("WEBHOOK_MAX_BACKOFF", "must not be less than WEBHOOK_BASE_BACKOFF")
+	}
+}
@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/dashboard"
+	"gin-boilerplate/helpers"
+	"gin-boilerplate/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultDashboardResync is how often a live dashboard connection gets a
+	// fresh snapshot when the controller is not configured.
+	DefaultDashboardResync = time.Minute
+
+	dashboardWriteWait  = 10 * time.Second
+	dashboardPongWait   = 60 * time.Second
+	dashboardPingPeriod = dashboardPongWait * 9 / 10
+	dashboardReadLimit  = 4096
+)
+
+var (
+	errDashboardUnavailable = apperror.Unavailable("dashboard_live_unavailable", "Live dashboard is not available")
+)
+
+// dashboardUpgrader accepts sockets from the same origin and from
+// AllowedOrigins. Browsers do not apply CORS to WebSockets, so without this
+// any page a user visits could open the socket.
+func (tc *TransactionController) dashboardUpgrader() *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin: func(r *http.Request) bool {
+			// Clients other than browsers send no Origin.
+			return r.Header.Get("Origin") == "" || helpers.SameOrigin(r) || helpers.OriginAllowed(tc.AllowedOrigins, r.Header.Get("Origin"))
+		},
+	}
+}
+
+// DashboardRequest is a message sent by a live dashboard client. A
+// "subscribe" message replaces the metrics (all of them when empty) and the
+// scope of the connection and is answered with a new snapshot.
+type DashboardRequest struct {
+	Type    string          `json:"type"`
+	Metrics []string        `json:"metrics"`
+	Scope   dashboard.Scope `json:"scope"`
+}
+
+// DashboardMessage is a message sent to a live dashboard client: a
+// "snapshot" with the current Metrics, a "delta" with the Changes to add to
+// them, or an "error".
+type DashboardMessage struct {
+	Type    string             `json:"type"`
+	Scope   *dashboard.Scope   `json:"scope,omitempty"`
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+	Changes map[string]float64 `json:"changes,omitempty"`
+	Code    string             `json:"code,omitempty"`
+	Message string             `json:"message,omitempty"`
+}
+
+func (tc *TransactionController) dashboardResync() time.Duration {
+	if tc.DashboardResync > 0 {
+		return tc.DashboardResync
+	}
+	return DefaultDashboardResync
+}
+
+// dashboardConnection is the state of one live dashboard socket.
+type dashboardConnection struct {
+	conn    *websocket.Conn
+	repo    repository.TransactionRepository
+	metrics []string
+	scope   dashboard.Scope
+	summary repository.TransactionSummary
+}
+
+func (c *dashboardConnection) write(message DashboardMessage) error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(dashboardWriteWait))
+	return c.conn.WriteJSON(message)
+}
+
+func (c *dashboardConnection) writeError(err error) error {
+	appErr := apperror.From(err)
+	return c.write(DashboardMessage{Type: "error", Code: appErr.Code, Message: appErr.Message})
+}
+
+// snapshot reloads the summary for the scope and sends it.
+func (c *dashboardConnection) snapshot() error {
+	var summary repository.TransactionSummary
+	var err error
+	if c.scope.UserID != 0 {
+		summary, err = c.repo.GetUserTransactionSummary(c.scope.UserID)
+	} else {
+		summary, err = c.repo.GetTransactionSummary()
+	}
+	if err != nil {
+		return c.writeError(err)
+	}
+
+	c.summary = summary
+	scope := c.scope
+	return c.write(DashboardMessage{Type: "snapshot", Scope: &scope, Metrics: dashboard.Values(summary, c.metrics)})
+}
+
+// apply sends the delta of change for the connection's metrics, if any.
+func (c *dashboardConnection) apply(change dashboard.Change) error {
+	after, ok := change.Apply(c.summary, c.scope)
+	if !ok {
+		return nil
+	}
+	changes := dashboard.Diff(c.summary, after, c.metrics)
+	c.summary = after
+	if len(changes) == 0 {
+		return nil
+	}
+	scope := c.scope
+	return c.write(DashboardMessage{Type: "delta", Scope: &scope, Changes: changes})
+}
+
+// handle applies a client request.
+func (c *dashboardConnection) handle(raw []byte) error {
+	var req DashboardRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return c.writeError(apperror.Validation("malformed_message", err.Error()))
+	}
+	if req.Type != "subscribe" {
+		return c.writeError(apperror.Validation("unknown_message", fmt.Sprintf("Unknown message type %q", req.Type)))
+	}
+
+	metrics := req.Metrics
+	if len(metrics) == 0 {
+		metrics = dashboard.Metrics
+	}
+	for _, metric := range metrics {
+		if !dashboard.IsMetric(metric) {
+			return c.writeError(apperror.Validation("invalid_subscription", fmt.Sprintf("Unknown metric %q", metric)))
+		}
+	}
+	if req.Scope.UserID < 0 {
+		return c.writeError(apperror.Validation("invalid_subscription", "scope.user_id must be a positive integer"))
+	}
+
+	c.metrics, c.scope = metrics, req.Scope
+	return c.snapshot()
+}
+
+// DashboardLive pushes the dashboard summary over a WebSocket: a snapshot of
+// every metric on connect, then deltas as transactions are created or change
+// status. Clients narrow the metrics and scope with subscribe messages.
+func (tc *TransactionController) DashboardLive(ctx *gin.Context) {
+	if tc.DashboardChanges == nil {
+		helpers.Problem(ctx, errDashboardUnavailable)
+		return
+	}
+
+	conn, err := tc.dashboardUpgrader().Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// Upgrader sudah menulis response error
+		return
+	}
+	defer conn.Close()
+
+	changes, _, _ := tc.DashboardChanges.Subscribe(0)
+	defer func() { changes.Close() }()
+
+	requests := make(chan []byte)
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(dashboardReadLimit)
+		_ = conn.SetReadDeadline(time.Now().Add(dashboardPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(dashboardPongWait))
+		})
+		for {
+			_, raw, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			select {
+			case requests <- raw:
+			case <-ctx.Request.Context().Done():
+				return
+			}
+		}
+	}()
+
+	c := &dashboardConnection{conn: conn, repo: tc.repo(ctx), metrics: dashboard.Metrics}
+	if err := c.snapshot(); err != nil {
+		return
+	}
+
+	resync := time.NewTicker(tc.dashboardResync())
+	defer resync.Stop()
+	ping := time.NewTicker(dashboardPingPeriod)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case <-closed:
+			return
+		case <-tc.Closing:
+			closing := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+			_ = conn.WriteControl(websocket.CloseMessage, closing, time.Now().Add(dashboardWriteWait))
+			return
+		case raw := <-requests:
+			err = c.handle(raw)
+		case message, ok := <-changes.C:
+			if !ok {
+				// Tertinggal terlalu jauh, langganan ulang dan kirim snapshot baru
+				changes, _, _ = tc.DashboardChanges.Subscribe(0)
+				err = c.snapshot()
+				break
+			}
+			switch message.Event.Type {
+			case dashboard.EventChange:
+				err = c.apply(message.Event.Data.(dashboard.Change))
+			case dashboard.EventResync:
+				err = c.snapshot()
+			}
+		case <-resync.C:
+			err = c.snapshot()
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(dashboardWriteWait))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
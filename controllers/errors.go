@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"gin-boilerplate/apperror"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	// Report validation errors with the JSON field names clients send.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" || name == "" {
+		return field.Name
+	}
+	return name
+}
+
+var (
+	errInvalidID          = apperror.Validation("invalid_id", "Invalid transaction ID", apperror.FieldError{Field: "id", Code: "integer", Message: "id must be a positive integer"})
+	errInvalidStatus      = apperror.Validation("invalid_status", "Invalid status value. Allowed values: success, pending, failed", apperror.FieldError{Field: "status", Code: "oneof", Message: "status must be one of success, pending, failed"})
+	errInvalidIfMatch     = apperror.Validation("invalid_if_match", "Invalid If-Match header")
+	errIfMatchRequired    = apperror.PreconditionRequired("if_match_required", "If-Match header required")
+	errInvalidRequestBody = apperror.Validation("invalid_body", "Invalid request body")
+)
+
+// parseID reads the :id path parameter.
+func parseID(ctx *gin.Context) (int, error) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil || id <= 0 {
+		return 0, errInvalidID
+	}
+	return id, nil
+}
+
+// queryInt reads an optional positive integer query parameter, falling back
+// to def when it is absent.
+func queryInt(ctx *gin.Context, name string, def int) (int, *apperror.FieldError) {
+	raw := ctx.Query(name)
+	if raw == "" {
+		return def, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value <= 0 {
+		return 0, &apperror.FieldError{Field: name, Code: "integer", Message: fieldMessage(language(ctx), "integer", name, "")}
+	}
+	return value, nil
+}
+
+// queryBool reads an optional boolean query parameter.
+func queryBool(ctx *gin.Context, name string) (bool, *apperror.FieldError) {
+	raw := ctx.Query(name)
+	if raw == "" {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, &apperror.FieldError{Field: name, Code: "boolean", Message: fieldMessage(language(ctx), "boolean", name, "")}
+	}
+	return value, nil
+}
+
+// queryIncludeDeleted reads include_deleted, refusing true unless enabled.
+func queryIncludeDeleted(ctx *gin.Context, enabled bool) (bool, *apperror.FieldError) {
+	includeDeleted, fieldErr := queryBool(ctx, "include_deleted")
+	if fieldErr == nil && includeDeleted && !enabled {
+		return false, &apperror.FieldError{Field: "include_deleted", Code: "disabled", Message: fieldMessage(language(ctx), "disabled", "include_deleted", "")}
+	}
+	return includeDeleted, fieldErr
+}
+
+// bindError turns a ShouldBindJSON failure into a validation error, keeping
+// the decoder message for malformed JSON and listing rejected fields otherwise.
+func bindError(ctx *gin.Context, err error) error {
+	return bindErrorLang(language(ctx), err)
+}
+
+func bindErrorLang(lang string, err error) error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]apperror.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, apperror.FieldError{
+				Field:   fe.Field(),
+				Code:    fe.Tag(),
+				Message: fieldMessage(lang, fe.Tag(), fe.Field(), fe.Param()),
+			})
+		}
+		return invalidBody(lang, fields...)
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return apperror.Validation("malformed_body", err.Error())
+	}
+	return errInvalidRequestBody.Wrap(err)
+}
+
+// invalidBody builds the localized invalid_body error for rejected fields.
+func invalidBody(lang string, fields ...apperror.FieldError) error {
+	detail := detailMessage(lang, errInvalidRequestBody.Code, errInvalidRequestBody.Message)
+	return apperror.Validation(errInvalidRequestBody.Code, detail, fields...)
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"strings"
+)
+
+const (
+	langEnglish    = "en"
+	langIndonesian = "id"
+)
+
+// fieldMessages holds validation messages per language, keyed by rule code.
+// Each format takes the field name and the rule parameter.
+var fieldMessages = map[string]map[string]string{
+	langEnglish: {
+		"required":      "%s is required",
+		"gt":            "%s must be greater than %s",
+		"lte":           "%s must not exceed %s",
+		"oneof":         "%s must be one of: %s",
+		"integer":       "%s must be a positive integer",
+		"boolean":       "%s must be a boolean",
+		"invalid":       "%s is invalid",
+		"type":          "%s has an invalid type",
+		"immutable":     "%s cannot be changed",
+		"unknown":       "%s is not a known field",
+		"status_locked": "%s cannot be changed while status is %s",
+		"http_url":      "%s must be an http or https URL",
+		"min":           "%s must be at least %s characters long",
+
+		"if_match_required": "%s is required because If-Match is required",
+		"public_url":        "%s must resolve to a public address",
+		"disabled":          "%s is disabled on this server",
+		"columns":           "%s must not have more than %s fields",
+	},
+	langIndonesian: {
+		"required":      "%s wajib diisi",
+		"gt":            "%s harus lebih besar dari %s",
+		"lte":           "%s tidak boleh lebih dari %s",
+		"oneof":         "%s harus salah satu dari: %s",
+		"integer":       "%s harus berupa bilangan bulat positif",
+		"boolean":       "%s harus berupa boolean",
+		"invalid":       "%s tidak valid",
+		"type":          "%s memiliki tipe yang tidak valid",
+		"immutable":     "%s tidak dapat diubah",
+		"unknown":       "%s bukan field yang dikenal",
+		"status_locked": "%s tidak dapat diubah saat status %s",
+		"http_url":      "%s harus berupa URL http atau https",
+		"min":           "%s minimal %s karakter",
+
+		"if_match_required": "%s wajib diisi karena If-Match diwajibkan",
+		"public_url":        "%s harus mengarah ke alamat publik",
+		"disabled":          "%s dinonaktifkan di server ini",
+		"columns":           "%s tidak boleh memiliki lebih dari %s kolom",
+	},
+}
+
+var detailMessages = map[string]map[string]string{
+	langEnglish: {
+		"invalid_body":  "Invalid request body",
+		"invalid_query": "Invalid query parameters",
+		"invalid_patch": "Invalid patch document",
+		"field_locked":  "Some fields cannot be changed in the current status",
+	},
+	langIndonesian: {
+		"invalid_body":  "Body request tidak valid",
+		"invalid_query": "Parameter query tidak valid",
+		"invalid_patch": "Dokumen patch tidak valid",
+		"field_locked":  "Beberapa field tidak dapat diubah pada status saat ini",
+	},
+}
+
+// language picks the response language from Accept-Language.
+func language(ctx *gin.Context) string {
+	if ctx.Request == nil {
+		return langEnglish
+	}
+	return Language(ctx.GetHeader("Accept-Language"))
+}
+
+// Language picks the message language for an Accept-Language value,
+// defaulting to English. "in" is the legacy ISO code for Indonesian.
+func Language(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		switch {
+		case strings.HasPrefix(tag, "id"), strings.HasPrefix(tag, "in"):
+			return langIndonesian
+		case strings.HasPrefix(tag, "en"):
+			return langEnglish
+		}
+	}
+	return langEnglish
+}
+
+// fieldMessage renders the message for a failed rule on field.
+func fieldMessage(lang, code, field, param string) string {
+	format, ok := fieldMessages[lang][code]
+	if !ok {
+		format = fieldMessages[lang]["invalid"]
+	}
+	if strings.Count(format, "%s") == 2 {
+		return fmt.Sprintf(format, field, param)
+	}
+	return fmt.Sprintf(format, field)
+}
+
+// detailMessage returns the localized problem detail for code, or fallback.
+func detailMessage(lang, code, fallback string) string {
+	if msg, ok := detailMessages[lang][code]; ok {
+		return msg
+	}
+	return fallback
+}
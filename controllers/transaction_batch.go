@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/helpers"
+	"gin-boilerplate/models"
+	"gin-boilerplate/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"strconv"
+)
+
+// DefaultBatchMaxOperations caps a batch when the controller is not configured.
+const DefaultBatchMaxOperations = 100
+
+// BatchOperationRequest is one entry of POST /transaction/batch. "create"
+// uses Data; "update_status" uses ID, Status and optionally Version.
+type BatchOperationRequest struct {
+	Op      string                    `json:"op"`
+	Data    *CreateTransactionRequest `json:"data,omitempty"`
+	ID      int                       `json:"id,omitempty"`
+	Status  string                    `json:"status,omitempty"`
+	Version int                       `json:"version,omitempty"`
+}
+
+// BatchRequest is the body of POST /transaction/batch.
+type BatchRequest struct {
+	Atomic     bool                    `json:"atomic"`
+	Operations []BatchOperationRequest `json:"operations" binding:"required"`
+}
+
+// BatchItemError describes why a batch operation did not apply.
+type BatchItemError struct {
+	Code    string                `json:"code"`
+	Message string                `json:"message"`
+	Errors  []apperror.FieldError `json:"errors,omitempty"`
+}
+
+// BatchItemResult is the outcome of the operation at Index.
+type BatchItemResult struct {
+	Index       int                 `json:"index"`
+	Op          string              `json:"op"`
+	Status      string              `json:"status"`
+	Transaction *models.Transaction `json:"transaction,omitempty"`
+	Error       *BatchItemError     `json:"error,omitempty"`
+}
+
+// BatchResponse is the data of a POST /transaction/batch response.
+type BatchResponse struct {
+	Atomic    bool              `json:"atomic"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []BatchItemResult `json:"results"`
+}
+
+func (tc *TransactionController) batchMaxOperations() int {
+	if tc.BatchMaxOperations > 0 {
+		return tc.BatchMaxOperations
+	}
+	return DefaultBatchMaxOperations
+}
+
+// validateBatchOperation checks op and maps it to a repository operation.
+func (tc *TransactionController) validateBatchOperation(op BatchOperationRequest, lang string) (repository.BatchOperation, []apperror.FieldError) {
+	switch op.Op {
+	case "create":
+		if op.Data == nil {
+			return repository.BatchOperation{}, []apperror.FieldError{{Field: "data", Code: "required", Message: fieldMessage(lang, "required", "data", "")}}
+		}
+		var fields []apperror.FieldError
+		if err := binding.Validator.ValidateStruct(op.Data); err != nil {
+			var appErr *apperror.Error
+			if errors.As(bindErrorLang(lang, err), &appErr) {
+				fields = appErr.Fields
+			}
+		}
+		rules := tc.createRules()
+		if len(fields) == 0 {
+			fields = op.Data.Validate(rules, lang)
+		}
+		if len(fields) > 0 {
+			return repository.BatchOperation{}, prefixFields("data.", fields)
+		}
+		return repository.BatchOperation{Create: op.Data.ToModel(rules)}, nil
+
+	case "update_status":
+		var fields []apperror.FieldError
+		if op.ID <= 0 {
+			fields = append(fields, apperror.FieldError{Field: "id", Code: "integer", Message: fieldMessage(lang, "integer", "id", "")})
+		}
+		if !contains(importStatuses, op.Status) {
+			fields = append(fields, apperror.FieldError{Field: "status", Code: "oneof", Message: fieldMessage(lang, "oneof", "status", "success, pending, failed")})
+		}
+		// version stands in for If-Match, so it is just as mandatory.
+		if tc.RequireIfMatch && op.Version <= 0 {
+			fields = append(fields, apperror.FieldError{Field: "version", Code: errIfMatchRequired.Code, Message: fieldMessage(lang, errIfMatchRequired.Code, "version", "")})
+		}
+		if len(fields) > 0 {
+			return repository.BatchOperation{}, fields
+		}
+		return repository.BatchOperation{UpdateID: op.ID, Status: op.Status, Version: op.Version}, nil
+	}
+
+	return repository.BatchOperation{}, []apperror.FieldError{{Field: "op", Code: "oneof", Message: fieldMessage(lang, "oneof", "op", "create, update_status")}}
+}
+
+func prefixFields(prefix string, fields []apperror.FieldError) []apperror.FieldError {
+	prefixed := make([]apperror.FieldError, len(fields))
+	for i, field := range fields {
+		field.Field = prefix + field.Field
+		prefixed[i] = field
+	}
+	return prefixed
+}
+
+func batchItemError(err error) *BatchItemError {
+	appErr := apperror.From(err)
+	return &BatchItemError{Code: appErr.Code, Message: appErr.Message, Errors: appErr.Fields}
+}
+
+// BatchTransactions applies up to BatchMaxOperations creates and status
+// updates. With atomic=true every operation is validated up front and all of
+// them are committed in one database transaction or none are, the failure
+// being reported as a problem for the offending index. Otherwise each valid
+// operation is applied on its own and the response lists per-item results.
+func (tc *TransactionController) BatchTransactions(ctx *gin.Context) {
+	lang := language(ctx)
+
+	var req BatchRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.Problem(ctx, bindError(ctx, err))
+		return
+	}
+	if max := tc.batchMaxOperations(); len(req.Operations) == 0 || len(req.Operations) > max {
+		helpers.Problem(ctx, invalidBody(lang, apperror.FieldError{
+			Field:   "operations",
+			Code:    "lte",
+			Message: fieldMessage(lang, "lte", "operations", strconv.Itoa(max)),
+		}))
+		return
+	}
+
+	results := make([]BatchItemResult, len(req.Operations))
+	ops := make([]repository.BatchOperation, 0, len(req.Operations))
+	opIndex := make([]int, 0, len(req.Operations))
+	var invalid []apperror.FieldError
+
+	for i, op := range req.Operations {
+		results[i] = BatchItemResult{Index: i, Op: op.Op}
+		repoOp, fields := tc.validateBatchOperation(op, lang)
+		if len(fields) > 0 {
+			invalid = append(invalid, prefixFields(fmt.Sprintf("operations[%d].", i), fields)...)
+			results[i].Status = "rejected"
+			results[i].Error = &BatchItemError{Code: "invalid_operation", Message: detailMessage(lang, errInvalidRequestBody.Code, errInvalidRequestBody.Message), Errors: fields}
+			continue
+		}
+		ops = append(ops, repoOp)
+		opIndex = append(opIndex, i)
+	}
+
+	if req.Atomic && len(invalid) > 0 {
+		helpers.Problem(ctx, invalidBody(lang, invalid...))
+		return
+	}
+
+	applied, err := tc.repo(ctx).ApplyBatch(ops, req.Atomic)
+	if req.Atomic && err != nil {
+		for i, result := range applied {
+			if result.Err != nil && !errors.Is(result.Err, repository.ErrBatchRolledBack) {
+				failed := apperror.From(err)
+				wrapped := *failed
+				wrapped.Message = fmt.Sprintf("operations[%d]: %s", opIndex[i], failed.Message)
+				helpers.Problem(ctx, &wrapped)
+				return
+			}
+		}
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	response := BatchResponse{Atomic: req.Atomic, Results: results}
+	for i, result := range applied {
+		item := &response.Results[opIndex[i]]
+		if result.Err != nil {
+			item.Status = "failed"
+			item.Error = batchItemError(result.Err)
+			continue
+		}
+		item.Status = "ok"
+		item.Transaction = result.Transaction
+	}
+	for _, item := range response.Results {
+		if item.Status == "ok" {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+
+	helpers.Success(ctx, "Success batch", response)
+}
@@ -1,13 +1,18 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/events"
 	"gin-boilerplate/models"
 	"github.com/gin-gonic/gin"
-	"net/http"
 	"strconv"
+	"strings"
 	"math"
 	"gin-boilerplate/helpers"
 	"gin-boilerplate/repository"
+	"time"
 )
 
 
@@ -16,37 +21,119 @@ import (
 
 type TransactionController struct {
 	Repo repository.TransactionRepository
+	// RequireIfMatch makes PUT and DELETE reject requests without If-Match,
+	// and batch status updates without a version.
+	RequireIfMatch bool
+	// AllowedOrigins may open the live dashboard socket on top of the same
+	// origin; "*" allows every origin.
+	AllowedOrigins []string
+	// CreateRules are the limits applied to new transactions.
+	CreateRules CreateTransactionRules
+	// ExportMaxRows caps the rows returned by ExportTransactions.
+	ExportMaxRows int
+	// ImportBatchSize, ImportMaxRows and ImportMaxBytes bound ImportTransactions.
+	ImportBatchSize int
+	ImportMaxRows   int
+	ImportMaxBytes  int64
+	// BatchMaxOperations caps the operations of a single BatchTransactions call.
+	BatchMaxOperations int
+	// IncludeDeletedEnabled lets GetTransactions and ExportTransactions
+	// return soft deleted transactions with include_deleted=true, and
+	// enables RestoreTransaction.
+	IncludeDeletedEnabled bool
+	// Live feeds StreamTransactions, which pings idle clients every
+	// StreamHeartbeat.
+	Live            *events.Hub
+	StreamHeartbeat time.Duration
+	// DashboardChanges feeds DashboardLive, which also sends a fresh snapshot
+	// every DashboardResync.
+	DashboardChanges *events.Hub
+	DashboardResync  time.Duration
+	// Closing ends StreamTransactions and DashboardLive when the server shuts
+	// down, since the HTTP server does not wait for or close them itself.
+	Closing <-chan struct{}
 }
 
+// repo binds the repository to the request, so its queries are traced under
+// the request span and cancelled when the client goes away.
+func (tc *TransactionController) repo(ctx *gin.Context) repository.TransactionRepository {
+	if ctx.Request == nil {
+		return tc.Repo
+	}
+	return tc.Repo.WithContext(ctx.Request.Context())
+}
 
-func (tc *TransactionController) GetTransactions(ctx *gin.Context) {
-	// Ambil query params
+func (tc *TransactionController) createRules() CreateTransactionRules {
+	rules := tc.CreateRules
+	if len(rules.InitialStatuses) == 0 {
+		rules.InitialStatuses = DefaultCreateTransactionRules.InitialStatuses
+	}
+	return rules
+}
 
-	pageStr := ctx.Query("page_number")
-	pageSizeStr := ctx.Query("page_size")
-	status := ctx.Query("status")
-	userIDStr := ctx.Query("user_id")
+// etag formats a transaction version as a strong entity tag.
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
 
-	// Default pagination
-	pageNumber := 1
-	pageSize := 10
-	var userID int
+// ifMatchVersion reads the If-Match header. It returns 0 when the header is
+// absent or "*", meaning the write is not conditional.
+func (tc *TransactionController) ifMatchVersion(ctx *gin.Context) (int, error) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" {
+		if tc.RequireIfMatch {
+			return 0, errIfMatchRequired
+		}
+		return 0, nil
+	}
+	if header == "*" {
+		return 0, nil
+	}
 
-	if pageStr != "" {
-		pageNumber, _ = strconv.Atoi(pageStr)
+	tag, err := strconv.Unquote(strings.TrimPrefix(header, "W/"))
+	if err != nil {
+		tag = header
 	}
-	if pageSizeStr != "" {
-		pageSize, _ = strconv.Atoi(pageSizeStr)
+	version, err := strconv.Atoi(tag)
+	if err != nil || version <= 0 {
+		return 0, errInvalidIfMatch
 	}
-	if userIDStr != "" {
-		userID, _ = strconv.Atoi(userIDStr)
+	return version, nil
+}
+
+
+func (tc *TransactionController) GetTransactions(ctx *gin.Context) {
+	// Ambil query params, default pagination 1 / 10
+	status := ctx.Query("status")
+
+	var fieldErrs []apperror.FieldError
+	pageNumber, fieldErr := queryInt(ctx, "page_number", 1)
+	if fieldErr != nil {
+		fieldErrs = append(fieldErrs, *fieldErr)
+	}
+	pageSize, fieldErr := queryInt(ctx, "page_size", 10)
+	if fieldErr != nil {
+		fieldErrs = append(fieldErrs, *fieldErr)
+	}
+	userID, fieldErr := queryInt(ctx, "user_id", 0)
+	if fieldErr != nil {
+		fieldErrs = append(fieldErrs, *fieldErr)
+	}
+	includeDeleted, fieldErr := queryIncludeDeleted(ctx, tc.IncludeDeletedEnabled)
+	if fieldErr != nil {
+		fieldErrs = append(fieldErrs, *fieldErr)
+	}
+	if len(fieldErrs) > 0 {
+		detail := detailMessage(language(ctx), "invalid_query", "Invalid query parameters")
+		helpers.Problem(ctx, apperror.Validation("invalid_query", detail, fieldErrs...))
+		return
 	}
 
 	// Ambil data dari repository
 	var transactions []models.Transaction
-	totalRecordCount, err := tc.Repo.GetTransactionsWithFilters(&transactions, pageNumber, pageSize, status, userID)
+	totalRecordCount, err := tc.repo(ctx).GetTransactionsWithFilters(&transactions, pageNumber, pageSize, status, userID, includeDeleted)
 	if err != nil {
-		helpers.Error(ctx, err.Error(), nil)
+		helpers.Problem(ctx, err)
 		return
 	}
 
@@ -64,22 +151,22 @@ func (tc *TransactionController) GetTransactions(ctx *gin.Context) {
 
 func (tc *TransactionController) GetDashboardReport(ctx *gin.Context) {
 	// Total transaksi sukses hari ini
-	totalSuccessToday, err := tc.Repo.CountSuccessToday()
+	totalSuccessToday, err := tc.repo(ctx).CountSuccessToday()
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		helpers.Problem(ctx, err)
 		return
 	}
 
 	// Total transaksi dan unique user
-	totalTransactions, err := tc.Repo.CountTotalTransactions()
+	totalTransactions, err := tc.repo(ctx).CountTotalTransactions()
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		helpers.Problem(ctx, err)
 		return
 	}
 
-	uniqueUsers, err := tc.Repo.CountUniqueUsers()
+	uniqueUsers, err := tc.repo(ctx).CountUniqueUsers()
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		helpers.Problem(ctx, err)
 		return
 	}
 
@@ -92,9 +179,9 @@ func (tc *TransactionController) GetDashboardReport(ctx *gin.Context) {
 	}
 
 	// Daftar 10 transaksi terbaru
-	latestTransactions, err := tc.Repo.GetLatestTransactions(10)
+	latestTransactions, err := tc.repo(ctx).GetLatestTransactions(10)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		helpers.Problem(ctx, err)
 		return
 	}
 
@@ -111,15 +198,19 @@ func (tc *TransactionController) GetDashboardReport(ctx *gin.Context) {
 func (tc *TransactionController) GetTransactionByID(ctx *gin.Context) {
 
 	// Ambil param ID dari URL
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
+	id, err := parseID(ctx)
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
 
 	// Ambil data transaksi dari repository
-	transaction, err := tc.Repo.GetTransactionByID(id)
+	transaction, err := tc.repo(ctx).GetTransactionByID(id)
 	if err != nil {
-		helpers.Error(ctx, "Transaction not found", nil)
+		helpers.Problem(ctx, err)
 		return
 	}
+	ctx.Header("ETag", etag(transaction.Version))
 	helpers.Success(ctx, "success get by id", transaction)
 }
 
@@ -130,17 +221,16 @@ type UpdateStatusRequest struct {
 
 func (tc *TransactionController)  UpdateTransactionStatus(ctx *gin.Context) {
 	// Ambil param ID dari URL
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
+	id, err := parseID(ctx)
 	if err != nil {
-		helpers.Error(ctx, "Invalid transaction ID", nil)
+		helpers.Problem(ctx, err)
 		return
 	}
 
 	// Binding request body
 	var req UpdateStatusRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		helpers.Error(ctx, "Invalid request body", nil)
+		helpers.Problem(ctx, bindError(ctx, err))
 		return
 	}
 
@@ -152,26 +242,92 @@ func (tc *TransactionController)  UpdateTransactionStatus(ctx *gin.Context) {
 	}
 
 	if !validStatuses[req.Status] {
-		helpers.Error(ctx, "Invalid status value. Allowed values: success, pending, failed", nil)
+		helpers.Problem(ctx, errInvalidStatus)
+		return
+	}
+
+	version, err := tc.ifMatchVersion(ctx)
+	if err != nil {
+		helpers.Problem(ctx, err)
 		return
 	}
 
 	// Update transaksi lewat repository
-	transaction, err := tc.Repo.UpdateTransactionStatus(id, req.Status)
+	transaction, err := tc.repo(ctx).UpdateTransactionStatus(id, req.Status, version)
 	if err != nil {
-		helpers.Error(ctx, "Transaction not found", nil)
+		helpers.Problem(ctx, err)
 		return
 	}
+	ctx.Header("ETag", etag(transaction.Version))
 
 	// Return response sukses
 	helpers.Success(ctx, "Success Update Transaction Status", transaction)
 }
 
 
+// PatchTransaction applies a JSON Merge Patch to the editable fields of a
+// transaction and records the change in the audit log.
+func (tc *TransactionController) PatchTransaction(ctx *gin.Context) {
+	// Ambil param ID dari URL
+	id, err := parseID(ctx)
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	version, err := tc.ifMatchVersion(ctx)
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	// Dokumen merge patch harus berupa object JSON
+	var doc map[string]json.RawMessage
+	if err := ctx.ShouldBindJSON(&doc); err != nil {
+		helpers.Problem(ctx, bindError(ctx, err))
+		return
+	}
+	if doc == nil {
+		helpers.Problem(ctx, apperror.Validation("invalid_patch", detailMessage(language(ctx), "invalid_patch", "Invalid patch document")))
+		return
+	}
+
+	current, err := tc.repo(ctx).GetTransactionByID(id)
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+	if version != 0 && current.Version != version {
+		helpers.Problem(ctx, repository.ErrVersionConflict)
+		return
+	}
+
+	patch, err := tc.mergeTransactionPatch(current, doc, language(ctx))
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	// Tidak ada perubahan, kembalikan data saat ini
+	transaction := current
+	if len(patch.changes) > 0 {
+		audit := &models.TransactionAudit{Action: "patch", Changes: patch.audit}
+		transaction, err = tc.repo(ctx).PatchTransaction(id, current.Version, patch.changes, audit)
+		if err != nil {
+			helpers.Problem(ctx, err)
+			return
+		}
+	}
+	ctx.Header("ETag", etag(transaction.Version))
+
+	helpers.Success(ctx, "Success Patch Transaction", transaction)
+}
+
+
 func (tc *TransactionController) GetDashboardSummary(ctx *gin.Context) {
-	summary, err := tc.Repo.GetTransactionSummary()
+	summary, err := tc.repo(ctx).GetTransactionSummary()
 	if err != nil {
-		helpers.Error(ctx, "Failed to fetch summary data", nil)
+		helpers.Problem(ctx, err)
 		return
 	}
 
@@ -181,17 +337,22 @@ func (tc *TransactionController) GetDashboardSummary(ctx *gin.Context) {
 
 func (tc *TransactionController) DeleteTransaction(ctx *gin.Context) {
 	// Ambil param ID dari URL
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
+	id, err := parseID(ctx)
 	if err != nil {
-		helpers.Error(ctx, "Invalid transaction ID", nil)
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	version, err := tc.ifMatchVersion(ctx)
+	if err != nil {
+		helpers.Problem(ctx, err)
 		return
 	}
 
 	// Hapus transaksi pakai repository
-	err = tc.Repo.DeleteTransactionByID(id)
+	err = tc.repo(ctx).DeleteTransactionByID(id, version)
 	if err != nil {
-		helpers.Error(ctx, "Transaction not found", nil)
+		helpers.Problem(ctx, err)
 		return
 	}
 
@@ -199,19 +360,55 @@ func (tc *TransactionController) DeleteTransaction(ctx *gin.Context) {
 }
 
 
+func (tc *TransactionController) RestoreTransaction(ctx *gin.Context) {
+	// Restore, seperti include_deleted, hanya tersedia jika diaktifkan
+	if !tc.IncludeDeletedEnabled {
+		helpers.Problem(ctx, apperror.NotFound("restore_disabled", "Restore is disabled on this server"))
+		return
+	}
+
+	// Ambil param ID dari URL
+	id, err := parseID(ctx)
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	// Kembalikan transaksi yang sudah di soft delete
+	transaction, err := tc.repo(ctx).RestoreTransactionByID(id)
+	if errors.Is(err, repository.ErrTransactionNotFound) {
+		helpers.Problem(ctx, apperror.NotFound("deleted_transaction_not_found", "Deleted transaction not found"))
+		return
+	}
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	ctx.Header("ETag", etag(transaction.Version))
+	helpers.Success(ctx, "Success restore", transaction)
+}
+
+
 func (tc *TransactionController) CreateTransaction(ctx *gin.Context) {
-	transaction := new(models.Transaction)
+	var req CreateTransactionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.Problem(ctx, bindError(ctx, err))
+		return
+	}
 
-	if err := ctx.ShouldBindJSON(transaction); err != nil {
-		helpers.Error(ctx, err.Error(), nil)
+	// Validasi aturan yang bergantung pada konfigurasi
+	rules := tc.createRules()
+	if fields := req.Validate(rules, language(ctx)); len(fields) > 0 {
+		helpers.Problem(ctx, invalidBody(language(ctx), fields...))
 		return
 	}
 
-	if err := tc.Repo.Save(transaction); err != nil {
-		helpers.Error(ctx, err.Error(), nil)
+	transaction := req.ToModel(rules)
+	if err := tc.repo(ctx).Save(transaction); err != nil {
+		helpers.Problem(ctx, err)
 		return
 	}
 
 	helpers.Success(ctx, "Success Create", transaction)
 }
-
@@ -11,7 +11,16 @@ import (
     "errors"
     "bytes"
     "encoding/json"
+    "gin-boilerplate/apperror"
     "gin-boilerplate/repository"
+    "time"
+    "bufio"
+    "context"
+    "strings"
+    "io"
+    "gin-boilerplate/events"
+    "gin-boilerplate/dashboard"
+    "github.com/gorilla/websocket"
 )
 
 // MockTransactionRepository mocks the TransactionRepository interface
@@ -53,29 +62,80 @@ func (m *MockTransactionRepository) GetTransactionSummary() (repository.Transact
 	return args.Get(0).(repository.TransactionSummary), args.Error(1)
 }
 
-func (m *MockTransactionRepository) UpdateTransactionStatus(id int, status string) (*models.Transaction, error) {
-	args := m.Called(id, status)
+func (m *MockTransactionRepository) GetUserTransactionSummary(userID int) (repository.TransactionSummary, error) {
+	args := m.Called(userID)
+	return args.Get(0).(repository.TransactionSummary), args.Error(1)
+}
+
+func (m *MockTransactionRepository) UpdateTransactionStatus(id int, status string, version int) (*models.Transaction, error) {
+	args := m.Called(id, status, version)
 	if args.Get(0) != nil {
 		return args.Get(0).(*models.Transaction), args.Error(1)
 	}
 	return nil, args.Error(1)
 }
 
-func (m *MockTransactionRepository) DeleteTransactionByID(id int) error {
-	args := m.Called(id)
+func (m *MockTransactionRepository) PatchTransaction(id int, version int, changes map[string]interface{}, audit *models.TransactionAudit) (*models.Transaction, error) {
+	args := m.Called(id, version, changes, audit)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Transaction), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTransactionRepository) DeleteTransactionByID(id int, version int) error {
+	args := m.Called(id, version)
 	return args.Error(0)
 }
 
+func (m *MockTransactionRepository) RestoreTransactionByID(id int) (*models.Transaction, error) {
+	args := m.Called(id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Transaction), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTransactionRepository) PurgeDeletedBefore(cutoff time.Time) (int64, error) {
+	args := m.Called(cutoff)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockTransactionRepository) Save(transaction *models.Transaction) error {
 	args := m.Called(transaction)
 	return args.Error(0)
 }
 
-func (m *MockTransactionRepository) GetTransactionsWithFilters(transactions *[]models.Transaction, pageNumber, pageSize int, status string, userID int) (int64, error) {
-	args := m.Called(transactions, pageNumber, pageSize, status, userID)
+func (m *MockTransactionRepository) SaveBatch(transactions []*models.Transaction) error {
+	args := m.Called(transactions)
+	return args.Error(0)
+}
+
+func (m *MockTransactionRepository) ApplyBatch(ops []repository.BatchOperation, atomic bool) ([]repository.BatchResult, error) {
+	args := m.Called(ops, atomic)
+	return args.Get(0).([]repository.BatchResult), args.Error(1)
+}
+
+func (m *MockTransactionRepository) GetTransactionsWithFilters(transactions *[]models.Transaction, pageNumber, pageSize int, status string, userID int, includeDeleted bool) (int64, error) {
+	args := m.Called(transactions, pageNumber, pageSize, status, userID, includeDeleted)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockTransactionRepository) GetSortedTransactionsWithFilters(transactions *[]models.Transaction, pageNumber, pageSize int, status string, userID int, includeDeleted bool, sort repository.TransactionSort) (int64, error) {
+	args := m.Called(transactions, pageNumber, pageSize, status, userID, includeDeleted, sort)
 	return args.Get(0).(int64), args.Error(1)
 }
 
+func (m *MockTransactionRepository) GetUserStats(userIDs []int) ([]repository.UserStats, error) {
+	args := m.Called(userIDs)
+	return args.Get(0).([]repository.UserStats), args.Error(1)
+}
+
+// WithContext is not recorded; the mock answers for every context.
+func (m *MockTransactionRepository) WithContext(ctx context.Context) repository.TransactionRepository {
+	return m
+}
+
 
 func TestGetTransactionByID_Success(t *testing.T) {
     gin.SetMode(gin.TestMode)
@@ -88,6 +148,7 @@ func TestGetTransactionByID_Success(t *testing.T) {
         UserID: 123,
         Amount: 1000,
         Status: "completed",
+        Version: 2,
     }
     mockRepo.On("GetTransactionByID", 1).Return(transaction, nil)
 
@@ -98,6 +159,7 @@ func TestGetTransactionByID_Success(t *testing.T) {
     controller.GetTransactionByID(ctx)
 
     assert.Equal(t, http.StatusOK, w.Code)
+    assert.Equal(t, `"2"`, w.Header().Get("ETag"))
     assert.Contains(t, w.Body.String(), `"id":1`)
     assert.Contains(t, w.Body.String(), `"user_id":123`)
     assert.Contains(t, w.Body.String(), `"amount":1000`)
@@ -112,7 +174,7 @@ func TestGetTransactionByID_NotFound(t *testing.T) {
     mockRepo := new(MockTransactionRepository)
     controller := &TransactionController{Repo: mockRepo}
 
-    mockRepo.On("GetTransactionByID", 1).Return(nil, errors.New("not found"))
+    mockRepo.On("GetTransactionByID", 1).Return(nil, repository.ErrTransactionNotFound)
 
     w := httptest.NewRecorder()
     ctx, _ := gin.CreateTestContext(w)
@@ -120,6 +182,9 @@ func TestGetTransactionByID_NotFound(t *testing.T) {
 
     controller.GetTransactionByID(ctx)
 
+    assert.Equal(t, http.StatusNotFound, w.Code)
+    assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+    assert.Contains(t, w.Body.String(), `"code":"transaction_not_found"`)
     assert.Contains(t, w.Body.String(), "Transaction not found")
 }
 
@@ -181,9 +246,10 @@ func TestGetDashboardSummary_Failure(t *testing.T) {
 	controller := &TransactionController{Repo: mockRepo}
 	controller.GetDashboardSummary(ctx)
 
-	assert.Equal(t, http.StatusBadRequest, w.Code) // Karena pakai helpers.Error jadi 400
-	assert.Contains(t, w.Body.String(), `"status":"error"`)
-	assert.Contains(t, w.Body.String(), `"message":"Failed to fetch summary data"`)
+	// Error yang tidak dikenal dianggap internal, detailnya tidak dibocorkan
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"internal_error"`)
+	assert.NotContains(t, w.Body.String(), "database error")
 }
 
 func TestUpdateTransactionStatus_Success(t *testing.T) {
@@ -200,7 +266,7 @@ func TestUpdateTransactionStatus_Success(t *testing.T) {
 	mockRepo := new(MockTransactionRepository)
 	mockTransaction := &models.Transaction{ID: 1, Status: "success"}
 
-	mockRepo.On("UpdateTransactionStatus", 1, "success").Return(mockTransaction, nil)
+	mockRepo.On("UpdateTransactionStatus", 1, "success", 0).Return(mockTransaction, nil)
 
 	controller := &TransactionController{Repo: mockRepo}
 	controller.UpdateTransactionStatus(ctx)
@@ -223,8 +289,8 @@ func TestUpdateTransactionStatus_InvalidID(t *testing.T) {
 	controller.UpdateTransactionStatus(ctx)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.Contains(t, w.Body.String(), `"status":"error"`)
-	assert.Contains(t, w.Body.String(), `"message":"Invalid transaction ID"`)
+	assert.Contains(t, w.Body.String(), `"code":"invalid_id"`)
+	assert.Contains(t, w.Body.String(), `"detail":"Invalid transaction ID"`)
 }
 
 func TestUpdateTransactionStatus_InvalidRequestBody(t *testing.T) {
@@ -242,8 +308,8 @@ func TestUpdateTransactionStatus_InvalidRequestBody(t *testing.T) {
 	controller.UpdateTransactionStatus(ctx)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.Contains(t, w.Body.String(), `"status":"error"`)
-	assert.Contains(t, w.Body.String(), `"message":"Invalid request body"`)
+	assert.Contains(t, w.Body.String(), `"detail":"Invalid request body"`)
+	assert.Contains(t, w.Body.String(), `"field":"status"`)
 }
 
 func TestUpdateTransactionStatus_InvalidStatusValue(t *testing.T) {
@@ -277,26 +343,26 @@ func TestUpdateTransactionStatus_TransactionNotFound(t *testing.T) {
 	ctx.Request.Header.Set("Content-Type", "application/json")
 
 	mockRepo := new(MockTransactionRepository)
-	mockRepo.On("UpdateTransactionStatus", 1, "success").Return(nil, errors.New("not found"))
+	mockRepo.On("UpdateTransactionStatus", 1, "success", 0).Return(nil, repository.ErrTransactionNotFound)
 
 	controller := &TransactionController{Repo: mockRepo}
 	controller.UpdateTransactionStatus(ctx)
 
-	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.Contains(t, w.Body.String(), `"status":"error"`)
-	assert.Contains(t, w.Body.String(), `"message":"Transaction not found"`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"detail":"Transaction not found"`)
 }
 
 
 func TestDeleteTransaction_Success(t *testing.T) {
 	mockRepo := new(MockTransactionRepository)
-	mockRepo.On("DeleteTransactionByID", 1).Return(nil)
+	mockRepo.On("DeleteTransactionByID", 1, 0).Return(nil)
 
 	controller := &TransactionController{Repo: mockRepo}
 
 	w := httptest.NewRecorder()
 	ctx, _ := gin.CreateTestContext(w)
 	ctx.Params = []gin.Param{{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest(http.MethodDelete, "/transactions/1", nil)
 
 	controller.DeleteTransaction(ctx)
 
@@ -319,20 +385,225 @@ func TestDeleteTransaction_InvalidID(t *testing.T) {
 
 func TestDeleteTransaction_NotFound(t *testing.T) {
 	mockRepo := new(MockTransactionRepository)
-	mockRepo.On("DeleteTransactionByID", 1).Return(errors.New("not found"))
+	mockRepo.On("DeleteTransactionByID", 1, 0).Return(repository.ErrTransactionNotFound)
 
 	controller := &TransactionController{Repo: mockRepo}
 
 	w := httptest.NewRecorder()
 	ctx, _ := gin.CreateTestContext(w)
 	ctx.Params = []gin.Param{{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest(http.MethodDelete, "/transactions/1", nil)
 
 	controller.DeleteTransaction(ctx)
 
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 	assert.Contains(t, w.Body.String(), "Transaction not found")
 }
 
+func TestUpdateTransactionStatus_IfMatch(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest(http.MethodPut, "/transactions/1", bytes.NewBufferString(`{"status":"success"}`))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	ctx.Request.Header.Set("If-Match", `"3"`)
+
+	mockRepo := new(MockTransactionRepository)
+	mockRepo.On("UpdateTransactionStatus", 1, "success", 3).Return(&models.Transaction{ID: 1, Status: "success", Version: 4}, nil)
+
+	controller := &TransactionController{Repo: mockRepo}
+	controller.UpdateTransactionStatus(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateTransactionStatus_VersionConflict(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest(http.MethodPut, "/transactions/1", bytes.NewBufferString(`{"status":"success"}`))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	ctx.Request.Header.Set("If-Match", `"3"`)
+
+	mockRepo := new(MockTransactionRepository)
+	mockRepo.On("UpdateTransactionStatus", 1, "success", 3).Return(nil, repository.ErrVersionConflict)
+
+	controller := &TransactionController{Repo: mockRepo}
+	controller.UpdateTransactionStatus(ctx)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+}
+
+func TestGetTransactions_InvalidQuery(t *testing.T) {
+	controller := TransactionController{Repo: new(MockTransactionRepository)}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodGet, "/transactions?page_number=abc&user_id=x", nil)
+
+	controller.GetTransactions(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"page_number"`)
+	assert.Contains(t, w.Body.String(), `"field":"user_id"`)
+}
+
+func TestGetDashboardReport_Unavailable(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+
+	mockRepo := new(MockTransactionRepository)
+	mockRepo.On("CountSuccessToday").Return(0, apperror.Unavailable("database_unavailable", "Database is unavailable"))
+
+	controller := &TransactionController{Repo: mockRepo}
+	controller.GetDashboardReport(ctx)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"database_unavailable"`)
+}
+
+func TestDeleteTransaction_IfMatchRequired(t *testing.T) {
+	controller := &TransactionController{Repo: new(MockTransactionRepository), RequireIfMatch: true}
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = []gin.Param{{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest(http.MethodDelete, "/transactions/1", nil)
+
+	controller.DeleteTransaction(ctx)
+
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+}
+
+func newPatchContext(w *httptest.ResponseRecorder, body string) *gin.Context {
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+	ctx.Request = httptest.NewRequest(http.MethodPatch, "/transaction/1", bytes.NewBufferString(body))
+	ctx.Request.Header.Set("Content-Type", "application/merge-patch+json")
+	return ctx
+}
+
+func TestPatchTransaction_Success(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	current := &models.Transaction{ID: 1, Status: "pending", Amount: 100, Version: 2, Metadata: models.JSONMap{"a": "1", "b": "2"}}
+	mockRepo.On("GetTransactionByID", 1).Return(current, nil)
+
+	expectedChanges := map[string]interface{}{
+		"amount":      250,
+		"description": "refund",
+		"metadata":    models.JSONMap{"a": "1", "c": "3"},
+	}
+	mockRepo.On("PatchTransaction", 1, 2, expectedChanges, mock.MatchedBy(func(audit *models.TransactionAudit) bool {
+		return audit.Action == "patch" && len(audit.Changes) == 3
+	})).Return(&models.Transaction{ID: 1, Status: "pending", Amount: 250, Version: 3}, nil)
+
+	w := httptest.NewRecorder()
+	ctx := newPatchContext(w, `{"amount":250,"description":"refund","metadata":{"b":null,"c":"3"}}`)
+
+	controller := &TransactionController{Repo: mockRepo}
+	controller.PatchTransaction(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchTransaction_AmountLockedAfterPending(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	mockRepo.On("GetTransactionByID", 1).Return(&models.Transaction{ID: 1, Status: "success", Amount: 100, Version: 1}, nil)
+
+	w := httptest.NewRecorder()
+	ctx := newPatchContext(w, `{"amount":250}`)
+
+	controller := &TransactionController{Repo: mockRepo}
+	controller.PatchTransaction(ctx)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"amount","code":"status_locked"`)
+	mockRepo.AssertNotCalled(t, "PatchTransaction", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPatchTransaction_ImmutableField(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	mockRepo.On("GetTransactionByID", 1).Return(&models.Transaction{ID: 1, Status: "pending", Version: 1}, nil)
+
+	w := httptest.NewRecorder()
+	ctx := newPatchContext(w, `{"status":"success","user_id":9}`)
+
+	controller := &TransactionController{Repo: mockRepo}
+	controller.PatchTransaction(ctx)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"status","code":"immutable"`)
+	assert.Contains(t, w.Body.String(), `"field":"user_id","code":"immutable"`)
+}
+
+func TestPatchTransaction_StaleIfMatch(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	mockRepo.On("GetTransactionByID", 1).Return(&models.Transaction{ID: 1, Status: "pending", Version: 4}, nil)
+
+	w := httptest.NewRecorder()
+	ctx := newPatchContext(w, `{"description":"x"}`)
+	ctx.Request.Header.Set("If-Match", `"3"`)
+
+	controller := &TransactionController{Repo: mockRepo}
+	controller.PatchTransaction(ctx)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+}
+
+func TestRestoreTransaction_Success(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	mockRepo.On("RestoreTransactionByID", 1).Return(&models.Transaction{ID: 1, Status: "pending", Version: 3}, nil)
+
+	controller := &TransactionController{Repo: mockRepo, IncludeDeletedEnabled: true}
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = []gin.Param{{Key: "id", Value: "1"}}
+
+	controller.RestoreTransaction(ctx)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Success restore")
+	assert.Contains(t, w.Body.String(), `"deleted_at":null`)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+}
+
+func TestRestoreTransaction_NotFound(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	mockRepo.On("RestoreTransactionByID", 1).Return(nil, repository.ErrTransactionNotFound)
+
+	controller := &TransactionController{Repo: mockRepo, IncludeDeletedEnabled: true}
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = []gin.Param{{Key: "id", Value: "1"}}
+
+	controller.RestoreTransaction(ctx)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "Deleted transaction not found")
+}
+
+func TestRestoreTransaction_Disabled(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+
+	controller := &TransactionController{Repo: mockRepo}
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Params = []gin.Param{{Key: "id", Value: "1"}}
+
+	controller.RestoreTransaction(ctx)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"restore_disabled"`)
+	mockRepo.AssertNotCalled(t, "RestoreTransactionByID", mock.Anything)
+}
+
 func TestCreateTransaction_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -340,7 +611,7 @@ func TestCreateTransaction_Success(t *testing.T) {
 	controller := &TransactionController{Repo: mockRepo}
 
 	transaction := &models.Transaction{
-		ID:     1,
+		UserID: 7,
 		Amount: 100000,
 		Status: "pending",
 	}
@@ -350,7 +621,7 @@ func TestCreateTransaction_Success(t *testing.T) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 
-	body, _ := json.Marshal(transaction)
+	body, _ := json.Marshal(CreateTransactionRequest{UserID: 7, Amount: 100000})
 	c.Request, _ = http.NewRequest(http.MethodPost, "/transactions", bytes.NewBuffer(body))
 	c.Request.Header.Set("Content-Type", "application/json")
 
@@ -390,8 +661,9 @@ func TestCreateTransaction_InvalidRequestBody(t *testing.T) {
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
 
-	assert.Equal(t, "error", response["status"])
-	assert.Contains(t, response["message"], "invalid character")
+	assert.EqualValues(t, http.StatusBadRequest, response["status"])
+	assert.Equal(t, "malformed_body", response["code"])
+	assert.Contains(t, response["detail"], "invalid character")
 
 	mockRepo.AssertExpectations(t)
 }
@@ -403,7 +675,7 @@ func TestCreateTransaction_FailedToSave(t *testing.T) {
 	controller := &TransactionController{Repo: mockRepo}
 
 	transaction := &models.Transaction{
-		ID:     1,
+		UserID: 7,
 		Amount: 100000,
 		Status: "pending",
 	}
@@ -413,24 +685,63 @@ func TestCreateTransaction_FailedToSave(t *testing.T) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 
-	body, _ := json.Marshal(transaction)
+	body, _ := json.Marshal(CreateTransactionRequest{UserID: 7, Amount: 100000, Status: "pending"})
 	c.Request, _ = http.NewRequest(http.MethodPost, "/transactions", bytes.NewBuffer(body))
 	c.Request.Header.Set("Content-Type", "application/json")
 
 	controller.CreateTransaction(c)
 
-	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
 
 	var response map[string]interface{}
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
 
-	assert.Equal(t, "error", response["status"])
-	assert.Equal(t, "failed to save transaction", response["message"])
+	assert.Equal(t, "internal_error", response["code"])
+	assert.Equal(t, "Internal server error", response["detail"])
 
 	mockRepo.AssertExpectations(t)
 }
 
+func TestCreateTransaction_ValidationErrors(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	controller := &TransactionController{Repo: mockRepo}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodPost, "/transactions", bytes.NewBufferString(`{"id":5,"user_id":0,"amount":-10}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	controller.CreateTransaction(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"user_id","code":"required","message":"user_id is required"`)
+	assert.Contains(t, w.Body.String(), `"field":"amount","code":"gt","message":"amount must be greater than 0"`)
+	mockRepo.AssertNotCalled(t, "Save", mock.Anything)
+}
+
+func TestCreateTransaction_ConfigRulesIndonesian(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	controller := &TransactionController{
+		Repo:        mockRepo,
+		CreateRules: CreateTransactionRules{MaxAmount: 5000, InitialStatuses: []string{"pending"}},
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodPost, "/transactions", bytes.NewBufferString(`{"user_id":1,"amount":9000,"status":"success"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set("Accept-Language", "id-ID,id;q=0.9,en;q=0.8")
+
+	controller.CreateTransaction(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"detail":"Body request tidak valid"`)
+	assert.Contains(t, w.Body.String(), "amount tidak boleh lebih dari 5000")
+	assert.Contains(t, w.Body.String(), "status harus salah satu dari: pending")
+	mockRepo.AssertNotCalled(t, "Save", mock.Anything)
+}
+
 func TestGetTransactions_Success(t *testing.T) {
     mockRepo := new(MockTransactionRepository)
     controller := TransactionController{Repo: mockRepo}
@@ -440,7 +751,7 @@ func TestGetTransactions_Success(t *testing.T) {
     req, _ := http.NewRequest(http.MethodGet, "/transactions?page_number=1&page_size=10", nil)
     c.Request = req
 
-    mockRepo.On("GetTransactionsWithFilters", mock.Anything, 1, 10, "", 0).
+    mockRepo.On("GetTransactionsWithFilters", mock.Anything, 1, 10, "", 0, false).
         Return(int64(2), nil).
         Run(func(args mock.Arguments) {
             ptr := args.Get(0).(*[]models.Transaction)
@@ -471,7 +782,545 @@ func TestGetTransactions_Success(t *testing.T) {
 }
 
 
+func TestGetTransactions_IncludeDeleted(t *testing.T) {
+    mockRepo := new(MockTransactionRepository)
+    controller := TransactionController{Repo: mockRepo, IncludeDeletedEnabled: true}
+
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request, _ = http.NewRequest(http.MethodGet, "/transactions?include_deleted=true", nil)
+
+    mockRepo.On("GetTransactionsWithFilters", mock.Anything, 1, 10, "", 0, true).Return(int64(0), nil)
+
+    controller.GetTransactions(c)
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    mockRepo.AssertExpectations(t)
+}
+
+func TestGetTransactions_IncludeDeletedDisabled(t *testing.T) {
+    mockRepo := new(MockTransactionRepository)
+    controller := TransactionController{Repo: mockRepo}
+
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request, _ = http.NewRequest(http.MethodGet, "/transactions?include_deleted=true", nil)
+
+    controller.GetTransactions(c)
+
+    assert.Equal(t, http.StatusBadRequest, w.Code)
+    assert.Contains(t, w.Body.String(), `"field":"include_deleted","code":"disabled"`)
+    mockRepo.AssertNotCalled(t, "GetTransactionsWithFilters", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+
+func (m *MockTransactionRepository) StreamTransactionsWithFilters(status string, userID int, includeDeleted bool, limit int, fn func(*models.Transaction) error) error {
+	args := m.Called(status, userID, includeDeleted, limit, fn)
+	if rows, ok := args.Get(0).([]models.Transaction); ok {
+		for i := range rows {
+			if err := fn(&rows[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func TestExportTransactions_CSV(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	controller := TransactionController{Repo: mockRepo, ExportMaxRows: 50}
+
+	created := time.Date(2025, 2, 19, 2, 0, 0, 0, time.UTC)
+	mockRepo.On("StreamTransactionsWithFilters", "success", 3, false, 50, mock.Anything).Return([]models.Transaction{
+		{ID: 1, UserID: 3, Amount: 1000, Status: "success", CreatedAt: created},
+		{ID: 2, UserID: 3, Amount: 2500, Status: "success", CreatedAt: created},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodGet, "/transaction/export?format=csv&status=success&user_id=3&limit=500&columns=id,amount,created_at&tz=Asia/Jakarta", nil)
+
+	controller.ExportTransactions(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "50", w.Header().Get("X-Export-Row-Limit"))
+	assert.Equal(t, "id,amount,created_at\n1,1000,2025-02-19T09:00:00+07:00\n2,2500,2025-02-19T09:00:00+07:00\n", w.Body.String())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExportTransactions_InvalidFormat(t *testing.T) {
+	controller := TransactionController{Repo: new(MockTransactionRepository)}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodGet, "/transaction/export?format=pdf&columns=id,password", nil)
+
+	controller.ExportTransactions(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"format"`)
+	assert.Contains(t, w.Body.String(), `"field":"columns"`)
+}
+
+func TestExportTransactions_DatabaseDown(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	controller := TransactionController{Repo: mockRepo}
+	mockRepo.On("StreamTransactionsWithFilters", "", 0, false, DefaultExportMaxRows, mock.Anything).
+		Return(nil, apperror.Unavailable("database_unavailable", "Database is unavailable"))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodGet, "/transaction/export?format=xlsx", nil)
+
+	controller.ExportTransactions(c)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+}
+
+func TestImportTransactions_CSV(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	controller := TransactionController{Repo: mockRepo, ImportBatchSize: 2}
+
+	var batches [][]int
+	nextID := uint(100)
+	mockRepo.On("SaveBatch", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		var amounts []int
+		for _, transaction := range args.Get(0).([]*models.Transaction) {
+			nextID++
+			transaction.ID = nextID
+			amounts = append(amounts, transaction.Amount)
+		}
+		batches = append(batches, amounts)
+	})
+
+	csvBody := "user_id,amount,status,created_at\n" +
+		"1,100,success,2024-01-02T03:04:05Z\n" +
+		"0,200,pending,\n" +
+		"2,abc,pending,\n" +
+		"3,300,failed,\n" +
+		"5,1\"0,pending,\n" +
+		"6,600,pending,,extra\n" +
+		"x,700,refunded,\n" +
+		",,refunded,\n" +
+		"4,400\n"
 
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodPost, "/transaction/import", bytes.NewBufferString(csvBody))
+	c.Request.Header.Set("Content-Type", "text/csv")
+
+	controller.ImportTransactions(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, [][]int{{100, 300}, {400}}, batches)
+
+	var response struct {
+		Data ImportReport `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	report := response.Data
+	assert.Equal(t, 9, report.Total)
+	assert.Equal(t, 3, report.Accepted)
+	assert.Equal(t, 6, report.Rejected)
+	assert.False(t, report.Truncated)
+
+	rejected := map[int][]string{}
+	for _, row := range report.Rows {
+		for _, fieldErr := range row.Errors {
+			rejected[row.Line] = append(rejected[row.Line], fieldErr.Field+":"+fieldErr.Code)
+		}
+	}
+	assert.Equal(t, map[int][]string{
+		3: {"user_id:required"},
+		4: {"amount:type"},
+		6: {"row:malformed"},
+		7: {"row:columns"},
+		8: {"user_id:type", "status:oneof"},
+		9: {"user_id:required", "amount:required", "status:oneof"},
+	}, rejected)
+}
+
+func TestImportTransactions_DryRunNDJSON(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	controller := TransactionController{Repo: mockRepo}
+
+	body := `{"user_id":1,"amount":100,"status":"success"}` + "\n\n" + `{"user_id":1,"amount":"x"}` + "\n" + `{"user_id":1,"amount":5,"status":"refunded"}`
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodPost, "/transaction/import?dry_run=true", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/x-ndjson")
+
+	controller.ImportTransactions(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"dry_run":true`)
+	assert.Contains(t, w.Body.String(), `"total":3,"accepted":1,"rejected":2`)
+	assert.Contains(t, w.Body.String(), `{"line":3,"status":"rejected","errors":[{"field":"amount","code":"type"`)
+	assert.Contains(t, w.Body.String(), `{"line":4,"status":"rejected","errors":[{"field":"status","code":"oneof"`)
+	mockRepo.AssertNotCalled(t, "SaveBatch", mock.Anything)
+}
+
+func newBatchContext(w *httptest.ResponseRecorder, body string) *gin.Context {
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodPost, "/transaction/batch", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return c
+}
+
+func TestBatchTransactions_BestEffort(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	controller := TransactionController{Repo: mockRepo}
+
+	expectedOps := []repository.BatchOperation{
+		{Create: &models.Transaction{UserID: 1, Amount: 100, Status: "pending"}},
+		{UpdateID: 9, Status: "success"},
+	}
+	mockRepo.On("ApplyBatch", expectedOps, false).Return([]repository.BatchResult{
+		{Transaction: &models.Transaction{ID: 10, UserID: 1, Amount: 100, Status: "pending"}},
+		{Err: repository.ErrTransactionNotFound},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	c := newBatchContext(w, `{"operations":[
+		{"op":"create","data":{"user_id":1,"amount":100}},
+		{"op":"create","data":{"user_id":1,"amount":0}},
+		{"op":"update_status","id":9,"status":"success"}
+	]}`)
+
+	controller.BatchTransactions(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data BatchResponse `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Data.Succeeded)
+	assert.Equal(t, 2, response.Data.Failed)
+	assert.Equal(t, "ok", response.Data.Results[0].Status)
+	assert.Equal(t, "rejected", response.Data.Results[1].Status)
+	assert.Equal(t, "data.amount", response.Data.Results[1].Error.Errors[0].Field)
+	assert.Equal(t, "failed", response.Data.Results[2].Status)
+	assert.Equal(t, "transaction_not_found", response.Data.Results[2].Error.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBatchTransactions_AtomicRejectsInvalidUpFront(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	controller := TransactionController{Repo: mockRepo}
+
+	w := httptest.NewRecorder()
+	c := newBatchContext(w, `{"atomic":true,"operations":[{"op":"update_status","id":1,"status":"success"},{"op":"delete","id":2}]}`)
+
+	controller.BatchTransactions(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"operations[1].op"`)
+	mockRepo.AssertNotCalled(t, "ApplyBatch", mock.Anything, mock.Anything)
+}
+
+func TestBatchTransactions_AtomicRollback(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	controller := TransactionController{Repo: mockRepo}
+
+	mockRepo.On("ApplyBatch", mock.Anything, true).Return([]repository.BatchResult{
+		{Err: repository.ErrBatchRolledBack},
+		{Err: repository.ErrVersionConflict},
+	}, repository.ErrVersionConflict)
+
+	w := httptest.NewRecorder()
+	c := newBatchContext(w, `{"atomic":true,"operations":[{"op":"update_status","id":1,"status":"success"},{"op":"update_status","id":2,"status":"failed","version":3}]}`)
+
+	controller.BatchTransactions(c)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.Contains(t, w.Body.String(), `"detail":"operations[1]: Transaction was modified, reload and retry"`)
+}
+
+func TestBatchTransactions_RequireIfMatchNeedsVersion(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	controller := TransactionController{Repo: mockRepo, RequireIfMatch: true}
+
+	w := httptest.NewRecorder()
+	c := newBatchContext(w, `{"atomic":true,"operations":[{"op":"update_status","id":1,"status":"success","version":2},{"op":"update_status","id":2,"status":"failed"}]}`)
+
+	controller.BatchTransactions(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"operations[1].version","code":"if_match_required"`)
+	mockRepo.AssertNotCalled(t, "ApplyBatch", mock.Anything, mock.Anything)
+}
+
+func TestBatchTransactions_TooManyOperations(t *testing.T) {
+	controller := TransactionController{Repo: new(MockTransactionRepository), BatchMaxOperations: 1}
+
+	w := httptest.NewRecorder()
+	c := newBatchContext(w, `{"operations":[{"op":"update_status","id":1,"status":"success"},{"op":"update_status","id":2,"status":"success"}]}`)
+
+	controller.BatchTransactions(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"operations","code":"lte"`)
+}
+
+// readStreamEvents reads Server-Sent Events from body until n "id" or named
+// events have been seen, skipping pings.
+func readStreamEvents(t *testing.T, reader *bufio.Reader, n int) []string {
+	var events []string
+	var current []string
+	for len(events) < n {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v (got %v)", err, events)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line != "" {
+			current = append(current, line)
+			continue
+		}
+		if len(current) > 0 && current[0] != "event: ping" {
+			events = append(events, strings.Join(current, "|"))
+		}
+		current = nil
+	}
+	return events
+}
+
+func TestStreamTransactions_ResumeAndFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	hub := events.NewHub(10, 10)
+	controller := &TransactionController{Live: hub, StreamHeartbeat: time.Hour}
+	router := gin.New()
+	router.GET("/transaction/stream", controller.StreamTransactions)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	publish := func(eventType string, transaction *models.Transaction) {
+		assert.NoError(t, hub.Publish(context.Background(), events.New(eventType, transaction)))
+	}
+	publish(events.TransactionCreated, &models.Transaction{ID: 1, UserID: 7, Status: "pending"})
+	publish(events.TransactionCreated, &models.Transaction{ID: 2, UserID: 8, Status: "pending"})
+	publish(events.TransactionUpdated, &models.Transaction{ID: 3, UserID: 7, Status: "pending"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/transaction/stream?user_id=7&status=pending", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	// Replay after id 1 skips the other user's transaction.
+	replayed := readStreamEvents(t, reader, 1)
+	assert.True(t, strings.HasPrefix(replayed[0], "id: 3|event: transaction.updated|data: {\"id\":3,"), replayed[0])
+
+	publish(events.TransactionUpdated, &models.Transaction{ID: 1, UserID: 7, Status: "success"})
+	publish(events.TransactionCreated, &models.Transaction{ID: 4, UserID: 7, Status: "pending"})
+	assert.NoError(t, hub.Publish(context.Background(), events.New(events.TransactionStatusChanged, events.StatusChanged{
+		Transaction:    &models.Transaction{ID: 3, UserID: 7, Status: "pending"},
+		PreviousStatus: "failed",
+	})))
+	live := readStreamEvents(t, reader, 2)
+	assert.True(t, strings.HasPrefix(live[0], "id: 5|event: transaction.created|data: {\"id\":4,"), live[0])
+	assert.True(t, strings.HasPrefix(live[1], "id: 6|event: transaction.updated|data: {\"id\":3,"), live[1])
+}
+
+func TestStreamTransactions_EndsOnClosing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	closing := make(chan struct{})
+	controller := &TransactionController{Live: events.NewHub(10, 10), StreamHeartbeat: time.Hour, Closing: closing}
+	router := gin.New()
+	router.GET("/transaction/stream", controller.StreamTransactions)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/transaction/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+
+	close(closing)
+	_, err = io.ReadAll(resp.Body)
+	assert.NoError(t, err, "the stream should end cleanly before the client gives up")
+}
+
+func TestStreamTransactions_Resync(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	hub := events.NewHub(1, 10)
+	controller := &TransactionController{Live: hub, StreamHeartbeat: time.Hour}
+	router := gin.New()
+	router.GET("/transaction/stream", controller.StreamTransactions)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	for id := uint(1); id <= 3; id++ {
+		assert.NoError(t, hub.Publish(context.Background(), events.New(events.TransactionCreated, &models.Transaction{ID: id, Status: "pending"})))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/transaction/stream?last_event_id=1", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+
+	// Event 2 is no longer kept, so the client is told to resync before
+	// getting what is left.
+	got := readStreamEvents(t, bufio.NewReader(resp.Body), 2)
+	assert.Equal(t, `event: resync|data: {"last_event_id":1}`, got[0])
+	assert.True(t, strings.HasPrefix(got[1], "id: 3|"), got[1])
+}
+
+func TestDashboardLive_SnapshotDeltaSubscribe(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockRepo := new(MockTransactionRepository)
+	mockRepo.On("GetTransactionSummary").Return(repository.TransactionSummary{TotalTransactions: 4, UniqueUsers: 2, TotalPendingTransactions: 4, AverageTransactionPerUser: 2}, nil)
+	mockRepo.On("GetUserTransactionSummary", 7).Return(repository.TransactionSummary{TotalTransactions: 1, UniqueUsers: 1, TotalPendingTransactions: 1, AverageTransactionPerUser: 1}, nil)
+
+	changes := events.NewHub(1, 10)
+	controller := &TransactionController{Repo: mockRepo, DashboardChanges: changes}
+	router := gin.New()
+	router.GET("/dashboard/live", controller.DashboardLive)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/dashboard/live", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var message DashboardMessage
+	assert.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, "snapshot", message.Type)
+	assert.Equal(t, float64(4), message.Metrics["total_transactions"])
+	assert.Len(t, message.Metrics, 7)
+
+	publish := func(change dashboard.Change) {
+		assert.NoError(t, changes.Publish(context.Background(), events.New(dashboard.EventChange, change)))
+	}
+	publish(dashboard.Change{UserID: 3, FromStatus: "pending", ToStatus: "success"})
+	message = DashboardMessage{}
+	assert.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, "delta", message.Type)
+	assert.Equal(t, map[string]float64{"total_pending_transactions": -1, "total_success_transactions": 1}, message.Changes)
+
+	assert.NoError(t, conn.WriteJSON(DashboardRequest{Type: "subscribe", Metrics: []string{"total_transactions"}, Scope: dashboard.Scope{UserID: 7}}))
+	message = DashboardMessage{}
+	assert.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, "snapshot", message.Type)
+	assert.Equal(t, &dashboard.Scope{UserID: 7}, message.Scope)
+	assert.Equal(t, map[string]float64{"total_transactions": 1}, message.Metrics)
+
+	// Other users and unsubscribed metrics are not pushed.
+	publish(dashboard.Change{UserID: 3, Added: 1, ToStatus: "pending"})
+	publish(dashboard.Change{UserID: 7, FromStatus: "pending", ToStatus: "failed"})
+	publish(dashboard.Change{UserID: 7, Added: 1, ToStatus: "pending"})
+	message = DashboardMessage{}
+	assert.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, map[string]float64{"total_transactions": 1}, message.Changes)
+
+	assert.NoError(t, conn.WriteJSON(DashboardRequest{Type: "subscribe", Metrics: []string{"bogus"}}))
+	message = DashboardMessage{}
+	assert.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, "error", message.Type)
+	assert.Equal(t, "invalid_subscription", message.Code)
+}
+
+func TestDashboardLive_PeriodicSnapshot(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Deltas can drift from the database, e.g. unique_users after out of
+	// order creates; the periodic snapshot reads the figures again.
+	mockRepo := new(MockTransactionRepository)
+	mockRepo.On("GetTransactionSummary").Return(repository.TransactionSummary{TotalTransactions: 2, UniqueUsers: 0}, nil).Once()
+	mockRepo.On("GetTransactionSummary").Return(repository.TransactionSummary{TotalTransactions: 2, UniqueUsers: 1}, nil)
+
+	controller := &TransactionController{Repo: mockRepo, DashboardChanges: events.NewHub(1, 10), DashboardResync: 20 * time.Millisecond}
+	router := gin.New()
+	router.GET("/dashboard/live", controller.DashboardLive)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/dashboard/live", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var message DashboardMessage
+	assert.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, float64(0), message.Metrics["unique_users"])
+
+	message = DashboardMessage{}
+	assert.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, "snapshot", message.Type)
+	assert.Equal(t, float64(1), message.Metrics["unique_users"])
+}
+
+func TestDashboardLive_ChecksOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockRepo := new(MockTransactionRepository)
+	mockRepo.On("GetTransactionSummary").Return(repository.TransactionSummary{}, nil)
+	controller := &TransactionController{Repo: mockRepo, DashboardChanges: events.NewHub(1, 10), AllowedOrigins: []string{"https://dashboard.example.com"}}
+	router := gin.New()
+	router.GET("/dashboard/live", controller.DashboardLive)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/dashboard/live"
+
+	for origin, allowed := range map[string]bool{
+		"https://evil.example.com":      false,
+		"https://dashboard.example.com": true,
+		server.URL:                      true,
+	} {
+		conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {origin}})
+		if allowed {
+			if assert.NoError(t, err, origin) {
+				conn.Close()
+			}
+			continue
+		}
+		assert.Error(t, err, origin)
+		if assert.NotNil(t, resp, origin) {
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode, origin)
+		}
+	}
+}
+
+func TestStreamTransactions_InvalidQuery(t *testing.T) {
+	controller := &TransactionController{Live: events.NewHub(1, 1)}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodGet, "/transaction/stream?status=unknown", nil)
+
+	controller.StreamTransactions(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"status","code":"oneof"`)
+}
 
 
 // func TestGetTransactions_ErrorFetching(t *testing.T) {
@@ -0,0 +1,397 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/helpers"
+	"gin-boilerplate/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultImportBatchSize is the number of rows inserted per database transaction.
+	DefaultImportBatchSize = 500
+	// DefaultImportMaxRows caps the rows accepted in a single upload.
+	DefaultImportMaxRows = 10000
+	// DefaultImportMaxBytes caps the size of a single upload.
+	DefaultImportMaxBytes = 10 << 20
+)
+
+// importStatuses are the statuses historical rows may carry; unlike
+// POST /transaction, imports are not limited to the initial statuses.
+var importStatuses = []string{"pending", "success", "failed"}
+
+// ImportTransactionRow is one CSV record or NDJSON line of an import. It
+// accepts the create fields plus the original creation time.
+type ImportTransactionRow struct {
+	CreateTransactionRequest
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// ImportRowResult reports the outcome of one input line.
+type ImportRowResult struct {
+	Line   int                   `json:"line"`
+	Status string                `json:"status"`
+	ID     uint                  `json:"id,omitempty"`
+	Errors []apperror.FieldError `json:"errors,omitempty"`
+}
+
+// ImportReport is the response body of POST /transaction/import. Truncated
+// is set when the import stopped early because of the row cap or a database
+// outage; rows after the last reported line were not processed.
+type ImportReport struct {
+	DryRun    bool              `json:"dry_run"`
+	Truncated bool              `json:"truncated"`
+	Total     int               `json:"total"`
+	Accepted  int               `json:"accepted"`
+	Rejected  int               `json:"rejected"`
+	Rows      []ImportRowResult `json:"rows"`
+}
+
+func (r *ImportReport) accept(line int, id uint) {
+	r.Total++
+	r.Accepted++
+	r.Rows = append(r.Rows, ImportRowResult{Line: line, Status: "accepted", ID: id})
+}
+
+func (r *ImportReport) reject(line int, fields ...apperror.FieldError) {
+	r.Total++
+	r.Rejected++
+	r.Rows = append(r.Rows, ImportRowResult{Line: line, Status: "rejected", Errors: fields})
+}
+
+// importReader yields rows of an upload. A row level problem is returned as
+// fieldErrs with a nil error, along with the partially decoded row unless
+// the line could not be decoded at all; a non-nil error aborts the import.
+type importReader interface {
+	Next() (line int, row *ImportTransactionRow, fieldErrs []apperror.FieldError, err error)
+}
+
+func (tc *TransactionController) importLimits() (int, int, int64) {
+	batchSize, maxRows, maxBytes := tc.ImportBatchSize, tc.ImportMaxRows, tc.ImportMaxBytes
+	if batchSize <= 0 {
+		batchSize = DefaultImportBatchSize
+	}
+	if maxRows <= 0 {
+		maxRows = DefaultImportMaxRows
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultImportMaxBytes
+	}
+	return batchSize, maxRows, maxBytes
+}
+
+// ImportTransactions validates a CSV or NDJSON upload row by row and inserts
+// the valid rows in batches, each batch in its own database transaction. With
+// dry_run=true nothing is written. The upload is either the "file" field of a
+// multipart form or the raw request body.
+func (tc *TransactionController) ImportTransactions(ctx *gin.Context) {
+	lang := language(ctx)
+	batchSize, maxRows, maxBytes := tc.importLimits()
+
+	dryRun, fieldErr := queryBool(ctx, "dry_run")
+	if fieldErr != nil {
+		helpers.Problem(ctx, apperror.Validation("invalid_query", detailMessage(lang, "invalid_query", "Invalid query parameters"), *fieldErr))
+		return
+	}
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBytes)
+	body, format, err := importSource(ctx)
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+	defer body.Close()
+
+	var reader importReader
+	switch format {
+	case "csv":
+		reader, err = newCSVImportReader(body, lang)
+	case "ndjson":
+		reader = newNDJSONImportReader(body, lang)
+	default:
+		err = apperror.Validation("invalid_format", "Upload must be CSV or NDJSON", apperror.FieldError{Field: "format", Code: "oneof", Message: fieldMessage(lang, "oneof", "format", "csv, ndjson")})
+	}
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	rules := tc.createRules()
+	rules.InitialStatuses = importStatuses
+
+	report := &ImportReport{DryRun: dryRun, Rows: []ImportRowResult{}}
+	var batch []*models.Transaction
+	var batchLines []int
+
+	// flush stores the pending batch. It reports false when the database is
+	// unavailable, which stops the import.
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+		err := tc.repo(ctx).SaveBatch(batch)
+		for i, transaction := range batch {
+			if err != nil {
+				appErr := apperror.From(err)
+				report.reject(batchLines[i], apperror.FieldError{Field: "row", Code: appErr.Code, Message: appErr.Message})
+				continue
+			}
+			report.accept(batchLines[i], transaction.ID)
+		}
+		batch, batchLines = batch[:0], batchLines[:0]
+		return err == nil || apperror.From(err).Kind != apperror.KindUnavailable
+	}
+
+	for {
+		line, row, fieldErrs, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Rows of earlier batches may already be stored, so keep the report.
+			if report.Total == 0 && len(batch) == 0 {
+				helpers.Problem(ctx, importReadError(err))
+				return
+			}
+			report.Truncated = true
+			break
+		}
+		if report.Total+len(batch) >= maxRows {
+			report.Truncated = true
+			break
+		}
+
+		if row != nil {
+			fieldErrs = mergeFieldErrors(fieldErrs, validateImportRow(row, rules, lang))
+		}
+		if len(fieldErrs) > 0 {
+			report.reject(line, fieldErrs...)
+			continue
+		}
+
+		if dryRun {
+			report.accept(line, 0)
+			continue
+		}
+
+		transaction := row.ToModel(rules)
+		if row.CreatedAt != nil {
+			transaction.CreatedAt = *row.CreatedAt
+		}
+		batch = append(batch, transaction)
+		batchLines = append(batchLines, line)
+		if len(batch) >= batchSize && !flush() {
+			report.Truncated = true
+			break
+		}
+	}
+	if !flush() {
+		report.Truncated = true
+	}
+
+	helpers.Success(ctx, "Success import", report)
+}
+
+// importSource returns the upload body and its format, taken from the
+// format query parameter, the file extension or the content type.
+func importSource(ctx *gin.Context) (io.ReadCloser, string, error) {
+	format := ctx.Query("format")
+
+	mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		file, header, err := ctx.Request.FormFile("file")
+		if err != nil {
+			if strings.Contains(err.Error(), errUploadTooLarge) {
+				return nil, "", importReadError(err)
+			}
+			return nil, "", apperror.Validation("invalid_body", "Multipart upload must contain a file field").Wrap(err)
+		}
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+		return file, format, nil
+	}
+
+	if format == "" {
+		switch mediaType {
+		case "text/csv":
+			format = "csv"
+		case "application/x-ndjson", "application/ndjson":
+			format = "ndjson"
+		}
+	}
+	return ctx.Request.Body, format, nil
+}
+
+// errUploadTooLarge matches the error http.MaxBytesReader returns once the
+// limit is hit.
+const errUploadTooLarge = "http: request body too large"
+
+func importReadError(err error) error {
+	if strings.Contains(err.Error(), errUploadTooLarge) {
+		return apperror.Validation("upload_too_large", "Upload is too large").Wrap(err)
+	}
+	return apperror.Validation("malformed_body", err.Error())
+}
+
+// validateImportRow runs both the binding rules and the configured rules, so
+// the report lists every problem of the row at once.
+func validateImportRow(row *ImportTransactionRow, rules CreateTransactionRules, lang string) []apperror.FieldError {
+	var fields []apperror.FieldError
+	if err := binding.Validator.ValidateStruct(&row.CreateTransactionRequest); err != nil {
+		var appErr *apperror.Error
+		if errors.As(bindErrorLang(lang, err), &appErr) {
+			fields = appErr.Fields
+		}
+	}
+	return append(fields, row.Validate(rules, lang)...)
+}
+
+// mergeFieldErrors appends the validation errors of fields that have no
+// decoding error yet, so a field is not also reported as missing.
+func mergeFieldErrors(decodeErrs, validationErrs []apperror.FieldError) []apperror.FieldError {
+	reported := map[string]bool{}
+	for _, fieldErr := range decodeErrs {
+		reported[fieldErr.Field] = true
+	}
+	for _, fieldErr := range validationErrs {
+		if !reported[fieldErr.Field] {
+			decodeErrs = append(decodeErrs, fieldErr)
+		}
+	}
+	return decodeErrs
+}
+
+// csvImportReader maps CSV records to rows using the header line. Records
+// may have any number of fields: missing trailing fields are left empty, so
+// the row validation reports them, and extra fields reject the row.
+type csvImportReader struct {
+	r       *csv.Reader
+	columns []string
+	lang    string
+}
+
+func newCSVImportReader(r io.Reader, lang string) (*csvImportReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, importReadError(err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+	}
+	return &csvImportReader{r: reader, columns: header, lang: lang}, nil
+}
+
+func (c *csvImportReader) Next() (int, *ImportTransactionRow, []apperror.FieldError, error) {
+	record, err := c.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		// The reader resumes after the malformed record.
+		return parseErr.StartLine, nil, []apperror.FieldError{{Field: "row", Code: "malformed", Message: parseErr.Error()}}, nil
+	}
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	line, _ := c.r.FieldPos(0)
+	if len(record) > len(c.columns) {
+		return line, nil, []apperror.FieldError{{Field: "row", Code: "columns", Message: fieldMessage(c.lang, "columns", "row", strconv.Itoa(len(c.columns)))}}, nil
+	}
+
+	row := &ImportTransactionRow{}
+	var fieldErrs []apperror.FieldError
+	typeErr := func(field string) {
+		fieldErrs = append(fieldErrs, apperror.FieldError{Field: field, Code: "type", Message: fieldMessage(c.lang, "type", field, "")})
+	}
+
+	for i, value := range record {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		switch field := c.columns[i]; field {
+		case "user_id", "amount":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				typeErr(field)
+				continue
+			}
+			if field == "user_id" {
+				row.UserID = n
+			} else {
+				row.Amount = n
+			}
+		case "status":
+			row.Status = value
+		case "description":
+			row.Description = value
+		case "reference":
+			row.Reference = value
+		case "metadata":
+			if err := json.Unmarshal([]byte(value), &row.Metadata); err != nil {
+				typeErr(field)
+			}
+		case "created_at":
+			createdAt, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				typeErr(field)
+				continue
+			}
+			row.CreatedAt = &createdAt
+		}
+	}
+	return line, row, fieldErrs, nil
+}
+
+// ndjsonImportReader decodes one JSON object per line, skipping blank lines.
+type ndjsonImportReader struct {
+	scanner *bufio.Scanner
+	line    int
+	lang    string
+}
+
+func newNDJSONImportReader(r io.Reader, lang string) *ndjsonImportReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	return &ndjsonImportReader{scanner: scanner, lang: lang}
+}
+
+func (n *ndjsonImportReader) Next() (int, *ImportTransactionRow, []apperror.FieldError, error) {
+	for n.scanner.Scan() {
+		n.line++
+		data := bytes.TrimSpace(n.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		row := &ImportTransactionRow{}
+		if err := json.Unmarshal(data, row); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				return n.line, row, []apperror.FieldError{{Field: typeErr.Field, Code: "type", Message: fieldMessage(n.lang, "type", typeErr.Field, "")}}, nil
+			}
+			return n.line, nil, []apperror.FieldError{{Field: "row", Code: "malformed", Message: err.Error()}}, nil
+		}
+		return n.line, row, nil, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return 0, nil, nil, err
+	}
+	return 0, nil, nil, io.EOF
+}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"encoding/json"
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/models"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// patchableFields lists the fields PATCH may change and the statuses in which
+// each may change. A nil list means the field is editable in any status.
+var patchableFields = map[string][]string{
+	"description": nil,
+	"metadata":    nil,
+	"reference":   {"pending", "failed"},
+	"amount":      {"pending"},
+}
+
+// immutableFields are never changed by PATCH; status has its own endpoint.
+var immutableFields = map[string]bool{
+	"id":         true,
+	"user_id":    true,
+	"status":     true,
+	"version":    true,
+	"created_at": true,
+	"updated_at": true,
+	"deleted_at": true,
+}
+
+// transactionPatch is the outcome of applying a merge patch document to a
+// transaction: the column updates and the audit trail of what changed.
+type transactionPatch struct {
+	changes map[string]interface{}
+	audit   models.JSONMap
+}
+
+func (p *transactionPatch) set(field string, from, to interface{}) {
+	if reflect.DeepEqual(from, to) {
+		return
+	}
+	p.changes[field] = to
+	p.audit[field] = map[string]interface{}{"from": from, "to": to}
+}
+
+// mergeTransactionPatch applies a JSON Merge Patch (RFC 7396) document to
+// current. Type and field errors are reported as validation errors; fields
+// that are editable in general but locked by the current status as a conflict.
+func (tc *TransactionController) mergeTransactionPatch(current *models.Transaction, doc map[string]json.RawMessage, lang string) (*transactionPatch, error) {
+	patch := &transactionPatch{changes: map[string]interface{}{}, audit: models.JSONMap{}}
+
+	names := make([]string, 0, len(doc))
+	for name := range doc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var invalid, locked []apperror.FieldError
+	for _, name := range names {
+		raw := doc[name]
+
+		statuses, ok := patchableFields[name]
+		if !ok {
+			code := "unknown"
+			if immutableFields[name] {
+				code = "immutable"
+			}
+			invalid = append(invalid, apperror.FieldError{Field: name, Code: code, Message: fieldMessage(lang, code, name, "")})
+			continue
+		}
+		if statuses != nil && !contains(statuses, current.Status) {
+			locked = append(locked, apperror.FieldError{Field: name, Code: "status_locked", Message: fieldMessage(lang, "status_locked", name, current.Status)})
+			continue
+		}
+
+		if fieldErr := tc.mergePatchField(patch, current, name, raw, lang); fieldErr != nil {
+			invalid = append(invalid, *fieldErr)
+		}
+	}
+
+	if len(invalid) > 0 {
+		detail := detailMessage(lang, "invalid_patch", "Invalid patch document")
+		return nil, apperror.Validation("invalid_patch", detail, invalid...)
+	}
+	if len(locked) > 0 {
+		err := apperror.Conflict("field_locked", detailMessage(lang, "field_locked", "Some fields cannot be changed in the current status"))
+		err.Fields = locked
+		return nil, err
+	}
+	return patch, nil
+}
+
+func (tc *TransactionController) mergePatchField(patch *transactionPatch, current *models.Transaction, name string, raw json.RawMessage, lang string) *apperror.FieldError {
+	isNull := strings.TrimSpace(string(raw)) == "null"
+	typeErr := &apperror.FieldError{Field: name, Code: "type", Message: fieldMessage(lang, "type", name, "")}
+
+	switch name {
+	case "description", "reference":
+		var value string
+		if !isNull && json.Unmarshal(raw, &value) != nil {
+			return typeErr
+		}
+		from := current.Description
+		if name == "reference" {
+			from = current.Reference
+		}
+		patch.set(name, from, value)
+
+	case "amount":
+		var value int
+		if isNull {
+			return &apperror.FieldError{Field: name, Code: "required", Message: fieldMessage(lang, "required", name, "")}
+		}
+		if json.Unmarshal(raw, &value) != nil {
+			return typeErr
+		}
+		if value <= 0 {
+			return &apperror.FieldError{Field: name, Code: "gt", Message: fieldMessage(lang, "gt", name, "0")}
+		}
+		if max := tc.createRules().MaxAmount; max > 0 && value > max {
+			return &apperror.FieldError{Field: name, Code: "lte", Message: fieldMessage(lang, "lte", name, strconv.Itoa(max))}
+		}
+		patch.set(name, current.Amount, value)
+
+	case "metadata":
+		if isNull {
+			if current.Metadata != nil {
+				patch.set(name, current.Metadata, models.JSONMap(nil))
+			}
+			return nil
+		}
+		var value map[string]interface{}
+		if json.Unmarshal(raw, &value) != nil {
+			return typeErr
+		}
+		merged := mergePatch(map[string]interface{}(current.Metadata), value).(map[string]interface{})
+		patch.set(name, current.Metadata, models.JSONMap(merged))
+	}
+	return nil
+}
+
+// mergePatch implements the RFC 7396 MergePatch algorithm on decoded JSON.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	result := map[string]interface{}{}
+	if targetObj, ok := target.(map[string]interface{}); ok {
+		for k, v := range targetObj {
+			result[k] = v
+		}
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(result, k)
+			continue
+		}
+		result[k] = mergePatch(result[k], v)
+	}
+	return result
+}
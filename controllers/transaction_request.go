@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/models"
+	"github.com/gin-gonic/gin/binding"
+	"strconv"
+	"strings"
+)
+
+// CreateTransactionRules are the configurable limits applied to new transactions.
+type CreateTransactionRules struct {
+	// MaxAmount caps the amount of a single transaction; 0 means no cap.
+	MaxAmount int
+	// InitialStatuses lists the statuses a transaction may be created with.
+	// The first entry is used when the request omits status.
+	InitialStatuses []string
+}
+
+// DefaultCreateTransactionRules only allows new transactions to start pending.
+var DefaultCreateTransactionRules = CreateTransactionRules{
+	InitialStatuses: []string{"pending"},
+}
+
+// CreateTransactionRequest is the body accepted by POST /transaction. Server
+// managed fields like id and timestamps are deliberately absent.
+type CreateTransactionRequest struct {
+	UserID      int            `json:"user_id" binding:"required,gt=0"`
+	Amount      int            `json:"amount" binding:"required,gt=0"`
+	Status      string         `json:"status"`
+	Description string         `json:"description,omitempty"`
+	Reference   string         `json:"reference,omitempty"`
+	Metadata    models.JSONMap `json:"metadata,omitempty"`
+}
+
+// Validate applies the rules that depend on configuration, after the static
+// binding rules have passed.
+func (r *CreateTransactionRequest) Validate(rules CreateTransactionRules, lang string) []apperror.FieldError {
+	var fields []apperror.FieldError
+
+	if rules.MaxAmount > 0 && r.Amount > rules.MaxAmount {
+		max := strconv.Itoa(rules.MaxAmount)
+		fields = append(fields, apperror.FieldError{Field: "amount", Code: "lte", Message: fieldMessage(lang, "lte", "amount", max)})
+	}
+
+	if r.Status != "" && !contains(rules.InitialStatuses, r.Status) {
+		allowed := strings.Join(rules.InitialStatuses, ", ")
+		fields = append(fields, apperror.FieldError{Field: "status", Code: "oneof", Message: fieldMessage(lang, "oneof", "status", allowed)})
+	}
+
+	return fields
+}
+
+// Check runs the binding rules and then Validate, returning the same
+// invalid_body error POST /transaction would. It is for callers that do not
+// go through ShouldBindJSON.
+func (r *CreateTransactionRequest) Check(rules CreateTransactionRules, lang string) error {
+	if err := binding.Validator.ValidateStruct(r); err != nil {
+		return bindErrorLang(lang, err)
+	}
+	if fields := r.Validate(rules, lang); len(fields) > 0 {
+		return invalidBody(lang, fields...)
+	}
+	return nil
+}
+
+// ToModel maps the request into a new transaction.
+func (r *CreateTransactionRequest) ToModel(rules CreateTransactionRules) *models.Transaction {
+	status := r.Status
+	if status == "" && len(rules.InitialStatuses) > 0 {
+		status = rules.InitialStatuses[0]
+	}
+	return &models.Transaction{
+		UserID:      r.UserID,
+		Amount:      r.Amount,
+		Status:      status,
+		Description: r.Description,
+		Reference:   r.Reference,
+		Metadata:    r.Metadata,
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
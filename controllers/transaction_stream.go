@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/events"
+	"gin-boilerplate/helpers"
+	"gin-boilerplate/models"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultStreamHeartbeat is the ping interval when the controller is not configured.
+const DefaultStreamHeartbeat = 15 * time.Second
+
+var (
+	errStreamUnavailable = apperror.Unavailable("stream_unavailable", "Live stream is not available")
+	streamStatuses       = []string{"success", "pending", "failed"}
+)
+
+// streamFilter selects the transactions a stream client asked for.
+type streamFilter struct {
+	status string
+	userID int
+}
+
+func (f streamFilter) match(transaction *models.Transaction) bool {
+	if f.status != "" && transaction.Status != f.status {
+		return false
+	}
+	return f.userID == 0 || transaction.UserID == f.userID
+}
+
+func (tc *TransactionController) streamHeartbeat() time.Duration {
+	if tc.StreamHeartbeat > 0 {
+		return tc.StreamHeartbeat
+	}
+	return DefaultStreamHeartbeat
+}
+
+// StreamTransactions pushes created, updated and deleted transactions as
+// Server-Sent Events. Each event id is the hub sequence number; a client
+// reconnecting with Last-Event-ID gets the events it missed, or a "resync"
+// event when they are no longer available and it should reload instead.
+func (tc *TransactionController) StreamTransactions(ctx *gin.Context) {
+	if tc.Live == nil {
+		helpers.Problem(ctx, errStreamUnavailable)
+		return
+	}
+
+	lang := language(ctx)
+	var fieldErrs []apperror.FieldError
+	filter := streamFilter{status: ctx.Query("status")}
+	if filter.status != "" && !contains(streamStatuses, filter.status) {
+		fieldErrs = append(fieldErrs, apperror.FieldError{Field: "status", Code: "oneof", Message: fieldMessage(lang, "oneof", "status", "success pending failed")})
+	}
+	userID, fieldErr := queryInt(ctx, "user_id", 0)
+	if fieldErr != nil {
+		fieldErrs = append(fieldErrs, *fieldErr)
+	}
+	filter.userID = userID
+
+	// EventSource mengirim Last-Event-ID sebagai header, query untuk client lain
+	rawLastID := ctx.GetHeader("Last-Event-ID")
+	if rawLastID == "" {
+		rawLastID = ctx.Query("last_event_id")
+	}
+	var lastID uint64
+	if rawLastID != "" {
+		var err error
+		if lastID, err = strconv.ParseUint(rawLastID, 10, 64); err != nil {
+			fieldErrs = append(fieldErrs, apperror.FieldError{Field: "last_event_id", Code: "integer", Message: fieldMessage(lang, "integer", "last_event_id", "")})
+		}
+	}
+	if len(fieldErrs) > 0 {
+		detail := detailMessage(lang, "invalid_query", "Invalid query parameters")
+		helpers.Problem(ctx, apperror.Validation("invalid_query", detail, fieldErrs...))
+		return
+	}
+
+	sub, replay, complete := tc.Live.Subscribe(lastID)
+	defer sub.Close()
+
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	if !complete {
+		writeStreamEvent(ctx.Writer, "", "resync", gin.H{"last_event_id": lastID})
+	}
+	for _, message := range replay {
+		writeStreamMessage(ctx.Writer, message, filter)
+	}
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(tc.streamHeartbeat())
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case <-tc.Closing:
+			// Server berhenti, client reconnect ke instance lain dengan Last-Event-ID
+			return
+		case message, ok := <-sub.C:
+			if !ok {
+				// Client terlalu lambat, biarkan reconnect dengan Last-Event-ID
+				return
+			}
+			if !writeStreamMessage(ctx.Writer, message, filter) {
+				continue
+			}
+		case now := <-heartbeat.C:
+			writeStreamEvent(ctx.Writer, "", "ping", gin.H{"time": now.UTC().Format(time.RFC3339)})
+		}
+		ctx.Writer.Flush()
+	}
+}
+
+// writeStreamMessage writes message if it carries a transaction matching
+// filter and reports whether it did. Status changes and restores are sent
+// as updates.
+func writeStreamMessage(w io.Writer, message events.Message, filter streamFilter) bool {
+	var transaction *models.Transaction
+	eventType := message.Event.Type
+	switch data := message.Event.Data.(type) {
+	case *models.Transaction:
+		transaction = data
+		if eventType == events.TransactionRestored {
+			eventType = events.TransactionUpdated
+		}
+	case events.StatusChanged:
+		transaction, eventType = data.Transaction, events.TransactionUpdated
+	}
+	if transaction == nil || !filter.match(transaction) {
+		return false
+	}
+	writeStreamEvent(w, strconv.FormatUint(message.Seq, 10), eventType, transaction)
+	return true
+}
+
+func writeStreamEvent(w io.Writer, id, event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
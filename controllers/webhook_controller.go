@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/helpers"
+	"gin-boilerplate/models"
+	"gin-boilerplate/repository"
+	"gin-boilerplate/webhooks"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookController struct {
+	Repo    repository.WebhookRepository
+	Service *webhooks.Service
+}
+
+// repo binds the repository to the request, so its queries are cancelled
+// with it.
+func (wc *WebhookController) repo(ctx *gin.Context) repository.WebhookRepository {
+	if ctx.Request == nil {
+		return wc.Repo
+	}
+	return wc.Repo.WithContext(ctx.Request.Context())
+}
+
+// WebhookRequest creates or replaces a subscription. An empty events list
+// subscribes to every event; a missing secret is generated. The secret
+// cannot be changed once the subscription exists.
+type WebhookRequest struct {
+	URL    string   `json:"url" binding:"required,http_url"`
+	Events []string `json:"events" binding:"omitempty,dive,oneof=transaction.created transaction.status_changed transaction.deleted transaction.restored"`
+	Secret string   `json:"secret" binding:"omitempty,min=16"`
+	Active *bool    `json:"active"`
+}
+
+func (r *WebhookRequest) active() bool {
+	return r.Active == nil || *r.Active
+}
+
+// paramID reads a positive integer path parameter.
+func paramID(ctx *gin.Context, name string) (int, error) {
+	id, err := strconv.Atoi(ctx.Param(name))
+	if err != nil || id <= 0 {
+		lang := language(ctx)
+		return 0, apperror.Validation("invalid_id", "Invalid ID", apperror.FieldError{
+			Field:   name,
+			Code:    "integer",
+			Message: fieldMessage(lang, "integer", name, ""),
+		})
+	}
+	return id, nil
+}
+
+// checkURL refuses URLs that the delivery worker is not allowed to call,
+// such as loopback or internal addresses.
+func (wc *WebhookController) checkURL(ctx *gin.Context, rawURL string) error {
+	if err := wc.Service.CheckURL(ctx.Request.Context(), rawURL); err != nil {
+		lang := language(ctx)
+		return invalidBody(lang, apperror.FieldError{
+			Field:   "url",
+			Code:    "public_url",
+			Message: fieldMessage(lang, "public_url", "url", ""),
+		})
+	}
+	return nil
+}
+
+func (wc *WebhookController) CreateWebhook(ctx *gin.Context) {
+	var req WebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.Problem(ctx, bindError(ctx, err))
+		return
+	}
+	if err := wc.checkURL(ctx, req.URL); err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	subscription := &models.WebhookSubscription{
+		URL:    req.URL,
+		Secret: req.Secret,
+		Events: req.Events,
+		Active: req.active(),
+	}
+	if subscription.Secret == "" {
+		subscription.Secret = webhooks.NewSecret()
+	}
+	if err := wc.repo(ctx).CreateSubscription(subscription); err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	// Secret hanya dikembalikan sekali, saat subscription dibuat
+	ctx.JSON(http.StatusCreated, helpers.APIResponse{Status: "success", Message: "Success Create", Data: subscription})
+}
+
+func (wc *WebhookController) GetWebhooks(ctx *gin.Context) {
+	subscriptions, err := wc.repo(ctx).ListSubscriptions()
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+
+	helpers.Success(ctx, "success get data", subscriptions)
+}
+
+func (wc *WebhookController) GetWebhookByID(ctx *gin.Context) {
+	id, err := paramID(ctx, "id")
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	subscription, err := wc.repo(ctx).GetSubscriptionByID(id)
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+	subscription.Secret = ""
+
+	helpers.Success(ctx, "success get data", subscription)
+}
+
+func (wc *WebhookController) UpdateWebhook(ctx *gin.Context) {
+	id, err := paramID(ctx, "id")
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	var req WebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.Problem(ctx, bindError(ctx, err))
+		return
+	}
+	if err := wc.checkURL(ctx, req.URL); err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	subscription := &models.WebhookSubscription{
+		ID:     uint(id),
+		URL:    req.URL,
+		Events: req.Events,
+		Active: req.active(),
+	}
+	if err := wc.repo(ctx).UpdateSubscription(subscription); err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+	subscription.Secret = ""
+
+	helpers.Success(ctx, "Success update", subscription)
+}
+
+func (wc *WebhookController) DeleteWebhook(ctx *gin.Context) {
+	id, err := paramID(ctx, "id")
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	if err := wc.repo(ctx).DeleteSubscriptionByID(id); err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	helpers.Success(ctx, "Success delete", nil)
+}
+
+// GetWebhookDeliveries returns the delivery log of a subscription, newest first.
+func (wc *WebhookController) GetWebhookDeliveries(ctx *gin.Context) {
+	id, err := paramID(ctx, "id")
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	var fieldErrs []apperror.FieldError
+	pageNumber, fieldErr := queryInt(ctx, "page_number", 1)
+	if fieldErr != nil {
+		fieldErrs = append(fieldErrs, *fieldErr)
+	}
+	pageSize, fieldErr := queryInt(ctx, "page_size", 10)
+	if fieldErr != nil {
+		fieldErrs = append(fieldErrs, *fieldErr)
+	}
+	if len(fieldErrs) > 0 {
+		detail := detailMessage(language(ctx), "invalid_query", "Invalid query parameters")
+		helpers.Problem(ctx, apperror.Validation("invalid_query", detail, fieldErrs...))
+		return
+	}
+
+	// Pastikan subscription ada, supaya id yang salah menjadi 404
+	if _, err := wc.repo(ctx).GetSubscriptionByID(id); err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	var deliveries []models.WebhookDelivery
+	totalRecordCount, err := wc.repo(ctx).ListDeliveries(&deliveries, id, pageNumber, pageSize)
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	helpers.Success(ctx, "success get data", gin.H{
+		"page_number":        pageNumber,
+		"page_size":          pageSize,
+		"total_record_count": totalRecordCount,
+		"data":               deliveries,
+	})
+}
+
+// RedeliverWebhook queues a new delivery with the payload of an earlier one.
+func (wc *WebhookController) RedeliverWebhook(ctx *gin.Context) {
+	id, err := paramID(ctx, "id")
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+	deliveryID, err := paramID(ctx, "delivery_id")
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	delivery, err := wc.Service.Redeliver(ctx.Request.Context(), id, deliveryID)
+	if err != nil {
+		helpers.Problem(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, helpers.APIResponse{Status: "success", Message: "Redelivery queued", Data: delivery})
+}
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"gin-boilerplate/models"
+	"gin-boilerplate/repository"
+	"gin-boilerplate/webhooks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockWebhookRepository mocks the WebhookRepository interface
+type MockWebhookRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebhookRepository) CreateSubscription(subscription *models.WebhookSubscription) error {
+	args := m.Called(subscription)
+	subscription.ID = 1
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) GetSubscriptionByID(id int) (*models.WebhookSubscription, error) {
+	args := m.Called(id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.WebhookSubscription), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWebhookRepository) ListSubscriptions() ([]models.WebhookSubscription, error) {
+	args := m.Called()
+	return args.Get(0).([]models.WebhookSubscription), args.Error(1)
+}
+
+func (m *MockWebhookRepository) ListActiveSubscriptions() ([]models.WebhookSubscription, error) {
+	args := m.Called()
+	return args.Get(0).([]models.WebhookSubscription), args.Error(1)
+}
+
+func (m *MockWebhookRepository) UpdateSubscription(subscription *models.WebhookSubscription) error {
+	return m.Called(subscription).Error(0)
+}
+
+func (m *MockWebhookRepository) DeleteSubscriptionByID(id int) error {
+	return m.Called(id).Error(0)
+}
+
+func (m *MockWebhookRepository) CreateDeliveries(deliveries []*models.WebhookDelivery) error {
+	return m.Called(deliveries).Error(0)
+}
+
+func (m *MockWebhookRepository) GetDelivery(subscriptionID, id int) (*models.WebhookDelivery, error) {
+	args := m.Called(subscriptionID, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.WebhookDelivery), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWebhookRepository) ListDeliveries(deliveries *[]models.WebhookDelivery, subscriptionID, pageNumber, pageSize int) (int64, error) {
+	args := m.Called(deliveries, subscriptionID, pageNumber, pageSize)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWebhookRepository) ClaimDueDeliveries(now time.Time, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	args := m.Called(now, limit, lease)
+	return args.Get(0).([]models.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookRepository) SaveDelivery(delivery *models.WebhookDelivery) error {
+	return m.Called(delivery).Error(0)
+}
+
+func (m *MockWebhookRepository) WithContext(ctx context.Context) repository.WebhookRepository {
+	return m
+}
+
+func newWebhookController(repo *MockWebhookRepository) *WebhookController {
+	return &WebhookController{Repo: repo, Service: webhooks.NewService(repo, webhooks.Config{})}
+}
+
+func newWebhookContext(w *httptest.ResponseRecorder, method, path, body string, params ...gin.Param) *gin.Context {
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(method, path, bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = params
+	return c
+}
+
+func TestCreateWebhook(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("CreateSubscription", mock.MatchedBy(func(s *models.WebhookSubscription) bool {
+		return s.URL == "https://93.184.216.34/hooks" && len(s.Secret) > 0 && s.Active
+	})).Return(nil)
+
+	w := httptest.NewRecorder()
+	c := newWebhookContext(w, http.MethodPost, "/webhooks", `{"url":"https://93.184.216.34/hooks","events":["transaction.created"]}`)
+
+	newWebhookController(mockRepo).CreateWebhook(c)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response struct {
+		Data models.WebhookSubscription `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotEmpty(t, response.Data.Secret, "secret is returned once")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateWebhook_RefusesPrivateAddresses(t *testing.T) {
+	for _, url := range []string{"http://127.0.0.1:8000/hooks", "http://169.254.169.254/latest/meta-data", "http://10.0.0.5/hooks"} {
+		mockRepo := new(MockWebhookRepository)
+
+		w := httptest.NewRecorder()
+		c := newWebhookContext(w, http.MethodPost, "/webhooks", `{"url":"`+url+`"}`)
+
+		newWebhookController(mockRepo).CreateWebhook(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+		assert.Contains(t, w.Body.String(), `"field":"url","code":"public_url"`, url)
+		mockRepo.AssertNotCalled(t, "CreateSubscription", mock.Anything)
+	}
+}
+
+func TestCreateWebhook_InvalidBody(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+
+	w := httptest.NewRecorder()
+	c := newWebhookContext(w, http.MethodPost, "/webhooks", `{"url":"ftp://example.com","events":["transaction.exploded"]}`)
+
+	newWebhookController(mockRepo).CreateWebhook(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"http_url"`)
+	mockRepo.AssertNotCalled(t, "CreateSubscription", mock.Anything)
+}
+
+func TestGetWebhooks_HidesSecrets(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("ListSubscriptions").Return([]models.WebhookSubscription{
+		{ID: 1, URL: "https://93.184.216.34/hooks", Secret: "whsec-0123456789abcdef", Active: true},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	c := newWebhookContext(w, http.MethodGet, "/webhooks", "")
+
+	newWebhookController(mockRepo).GetWebhooks(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "whsec-0123456789abcdef")
+}
+
+func TestGetWebhookByID_NotFound(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("GetSubscriptionByID", 7).Return(nil, repository.ErrWebhookNotFound)
+
+	w := httptest.NewRecorder()
+	c := newWebhookContext(w, http.MethodGet, "/webhooks/7", "", gin.Param{Key: "id", Value: "7"})
+
+	newWebhookController(mockRepo).GetWebhookByID(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUpdateWebhook(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("UpdateSubscription", &models.WebhookSubscription{ID: 3, URL: "https://93.184.216.34/new", Active: false}).Return(nil)
+
+	w := httptest.NewRecorder()
+	c := newWebhookContext(w, http.MethodPut, "/webhooks/3", `{"url":"https://93.184.216.34/new","active":false}`, gin.Param{Key: "id", Value: "3"})
+
+	newWebhookController(mockRepo).UpdateWebhook(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateWebhook_RefusesPrivateAddresses(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+
+	w := httptest.NewRecorder()
+	c := newWebhookContext(w, http.MethodPut, "/webhooks/3", `{"url":"http://localhost:5432/"}`, gin.Param{Key: "id", Value: "3"})
+
+	newWebhookController(mockRepo).UpdateWebhook(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"public_url"`)
+	mockRepo.AssertNotCalled(t, "UpdateSubscription", mock.Anything)
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("DeleteSubscriptionByID", 3).Return(nil)
+
+	w := httptest.NewRecorder()
+	c := newWebhookContext(w, http.MethodDelete, "/webhooks/3", "", gin.Param{Key: "id", Value: "3"})
+
+	newWebhookController(mockRepo).DeleteWebhook(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteWebhook_InvalidID(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+
+	w := httptest.NewRecorder()
+	c := newWebhookContext(w, http.MethodDelete, "/webhooks/abc", "", gin.Param{Key: "id", Value: "abc"})
+
+	newWebhookController(mockRepo).DeleteWebhook(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"invalid_id"`)
+}
+
+func TestGetWebhookDeliveries_UnknownSubscription(t *testing.T) {
+	mockRepo := new(MockWebhookRepository)
+	mockRepo.On("GetSubscriptionByID", 9).Return(nil, repository.ErrWebhookNotFound)
+
+	w := httptest.NewRecorder()
+	c := newWebhookContext(w, http.MethodGet, "/webhooks/9/deliveries", "", gin.Param{Key: "id", Value: "9"})
+
+	newWebhookController(mockRepo).GetWebhookDeliveries(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertNotCalled(t, "ListDeliveries", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
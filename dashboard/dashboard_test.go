@@ -0,0 +1,159 @@
+package dashboard
+
+import (
+	"gin-boilerplate/events"
+	"gin-boilerplate/models"
+	"gin-boilerplate/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLookup knows the live transaction IDs of each user.
+type fakeLookup struct {
+	transactions map[int][]uint
+	lookups      int
+}
+
+func (f *fakeLookup) HasTransactionsBefore(userID int, id uint) (bool, error) {
+	f.lookups++
+	for _, other := range f.transactions[userID] {
+		if other < id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeLookup) HasTransactionsBesides(userID int, id uint) (bool, error) {
+	f.lookups++
+	for _, other := range f.transactions[userID] {
+		if other != id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestChangeApply(t *testing.T) {
+	summary := repository.TransactionSummary{TotalTransactions: 3, UniqueUsers: 2, TotalPendingTransactions: 2, TotalSuccessTransactions: 1, AverageTransactionPerUser: 1.5}
+
+	created := Change{UserID: 9, Added: 1, CreatedToday: true, ToStatus: "pending", UniqueUsers: 1}
+	after, ok := created.Apply(summary, Scope{})
+	require.True(t, ok)
+	diff := Diff(summary, after, Metrics)
+	assert.InDelta(t, 4.0/3-1.5, diff["average_transaction_per_user"], 1e-9)
+	delete(diff, "average_transaction_per_user")
+	assert.Equal(t, map[string]float64{
+		"total_transactions":         1,
+		"total_transactions_today":   1,
+		"total_pending_transactions": 1,
+		"unique_users":               1,
+	}, diff)
+
+	// Another user's change is outside a user scope.
+	_, ok = created.Apply(summary, Scope{UserID: 1})
+	assert.False(t, ok)
+
+	// A user's first transaction makes them a unique user in their own scope.
+	after, ok = created.Apply(repository.TransactionSummary{}, Scope{UserID: 9})
+	require.True(t, ok)
+	assert.Equal(t, 1, after.UniqueUsers)
+	assert.Equal(t, 1.0, after.AverageTransactionPerUser)
+
+	settled := Change{UserID: 9, FromStatus: "pending", ToStatus: "success"}
+	after, _ = settled.Apply(summary, Scope{})
+	assert.Equal(t, map[string]float64{"total_success_transactions": 1}, Diff(summary, after, []string{"total_success_transactions", "total_transactions"}))
+	assert.Equal(t, 1, after.TotalPendingTransactions)
+
+	deleted := Change{UserID: 9, Added: -1, FromStatus: "success", UniqueUsers: -1}
+	after, _ = deleted.Apply(summary, Scope{})
+	assert.Equal(t, map[string]float64{
+		"total_transactions":         -1,
+		"total_success_transactions": -1,
+		"unique_users":               -1,
+	}, Diff(summary, after, []string{"total_transactions", "total_transactions_today", "total_success_transactions", "unique_users"}))
+	assert.Equal(t, 2.0, after.AverageTransactionPerUser)
+
+	// Deleting a user's last transaction leaves no unique user in their scope.
+	after, _ = deleted.Apply(repository.TransactionSummary{TotalTransactions: 1, UniqueUsers: 1, TotalSuccessTransactions: 1, AverageTransactionPerUser: 1}, Scope{UserID: 9})
+	assert.Equal(t, repository.TransactionSummary{}, after)
+}
+
+func TestTrackerChange(t *testing.T) {
+	lookup := &fakeLookup{transactions: map[int][]uint{5: {1, 2}, 6: {3, 4}}}
+	tracker := NewTracker(lookup, events.NewHub(1, 1))
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.Local)
+	tracker.now = func() time.Time { return now }
+
+	feed := []events.Event{
+		events.New(events.TransactionCreated, &models.Transaction{ID: 1, UserID: 5, Status: "pending", CreatedAt: now}),
+		events.New(events.TransactionUpdated, &models.Transaction{ID: 1, UserID: 5, Status: "pending"}),
+		events.New(events.TransactionCreated, &models.Transaction{ID: 2, UserID: 5, Status: "pending", CreatedAt: now.AddDate(0, 0, -1)}),
+		events.New(events.TransactionStatusChanged, events.StatusChanged{
+			Transaction:    &models.Transaction{ID: 1, UserID: 5, Status: "success"},
+			PreviousStatus: "pending",
+		}),
+		events.New(events.TransactionStatusChanged, events.StatusChanged{
+			Transaction:    &models.Transaction{ID: 1, UserID: 5, Status: "success"},
+			PreviousStatus: "success",
+		}),
+		// User 6 had transaction 3 before the tracker started.
+		events.New(events.TransactionCreated, &models.Transaction{ID: 4, UserID: 6, Status: "failed", CreatedAt: now}),
+	}
+
+	var got []Change
+	for _, event := range feed {
+		if change, ok := tracker.change(event); ok {
+			got = append(got, change)
+		}
+	}
+	assert.Equal(t, []Change{
+		{UserID: 5, Added: 1, CreatedToday: true, ToStatus: "pending", UniqueUsers: 1},
+		{UserID: 5, Added: 1, ToStatus: "pending"},
+		{UserID: 5, FromStatus: "pending", ToStatus: "success"},
+		{UserID: 6, Added: 1, CreatedToday: true, ToStatus: "failed"},
+	}, got)
+	assert.Equal(t, 2, lookup.lookups, "users are looked up once")
+}
+
+func TestTrackerChange_DeleteAndRestore(t *testing.T) {
+	lookup := &fakeLookup{transactions: map[int][]uint{5: {1, 2}}}
+	tracker := NewTracker(lookup, events.NewHub(1, 1))
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.Local)
+	tracker.now = func() time.Time { return now }
+
+	first := &models.Transaction{ID: 1, UserID: 5, Status: "success", CreatedAt: now}
+	second := &models.Transaction{ID: 2, UserID: 5, Status: "pending", CreatedAt: now.AddDate(0, 0, -1)}
+
+	lookup.transactions[5] = []uint{2}
+	change, ok := tracker.change(events.New(events.TransactionDeleted, first))
+	require.True(t, ok)
+	assert.Equal(t, Change{UserID: 5, Added: -1, CreatedToday: true, FromStatus: "success"}, change)
+
+	lookup.transactions[5] = nil
+	change, _ = tracker.change(events.New(events.TransactionDeleted, second))
+	assert.Equal(t, Change{UserID: 5, Added: -1, FromStatus: "pending", UniqueUsers: -1}, change)
+
+	lookup.transactions[5] = []uint{1}
+	change, _ = tracker.change(events.New(events.TransactionRestored, first))
+	assert.Equal(t, Change{UserID: 5, Added: 1, CreatedToday: true, ToStatus: "success", UniqueUsers: 1}, change)
+	assert.Equal(t, 2, lookup.lookups, "a user known to have no transactions is not looked up")
+}
+
+// Out of order creates for a new user leave unique_users short; the periodic
+// snapshot of live dashboards corrects it.
+func TestTrackerChange_OutOfOrderCreates(t *testing.T) {
+	lookup := &fakeLookup{transactions: map[int][]uint{5: {10, 11}}}
+	tracker := NewTracker(lookup, events.NewHub(1, 1))
+
+	unique := 0
+	for _, id := range []uint{11, 10} {
+		change, ok := tracker.change(events.New(events.TransactionCreated, &models.Transaction{ID: id, UserID: 5, Status: "pending"}))
+		require.True(t, ok)
+		unique += change.UniqueUsers
+	}
+	assert.Equal(t, 0, unique)
+}
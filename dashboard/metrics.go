@@ -0,0 +1,120 @@
+// Package dashboard keeps live dashboard metrics up to date by turning
+// transaction feed events into changes to the transaction summary.
+package dashboard
+
+import (
+	"gin-boilerplate/repository"
+	"math"
+)
+
+// Metrics are the summary metrics that can be subscribed to, named as in the
+// JSON of repository.TransactionSummary.
+var Metrics = []string{
+	"total_transactions_today",
+	"average_transaction_per_user",
+	"total_transactions",
+	"unique_users",
+	"total_pending_transactions",
+	"total_success_transactions",
+	"total_failed_transactions",
+}
+
+var metricValues = map[string]func(s repository.TransactionSummary) float64{
+	"total_transactions_today":     func(s repository.TransactionSummary) float64 { return float64(s.TotalTransactionsToday) },
+	"average_transaction_per_user": func(s repository.TransactionSummary) float64 { return s.AverageTransactionPerUser },
+	"total_transactions":           func(s repository.TransactionSummary) float64 { return float64(s.TotalTransactions) },
+	"unique_users":                 func(s repository.TransactionSummary) float64 { return float64(s.UniqueUsers) },
+	"total_pending_transactions":   func(s repository.TransactionSummary) float64 { return float64(s.TotalPendingTransactions) },
+	"total_success_transactions":   func(s repository.TransactionSummary) float64 { return float64(s.TotalSuccessTransactions) },
+	"total_failed_transactions":    func(s repository.TransactionSummary) float64 { return float64(s.TotalFailedTransactions) },
+}
+
+// IsMetric reports whether name is one of Metrics.
+func IsMetric(name string) bool {
+	_, ok := metricValues[name]
+	return ok
+}
+
+// Values returns the given metrics of summary.
+func Values(summary repository.TransactionSummary, metrics []string) map[string]float64 {
+	values := make(map[string]float64, len(metrics))
+	for _, metric := range metrics {
+		values[metric] = metricValues[metric](summary)
+	}
+	return values
+}
+
+// Diff returns how much each of the given metrics changed from before to
+// after, leaving out the ones that did not.
+func Diff(before, after repository.TransactionSummary, metrics []string) map[string]float64 {
+	changes := map[string]float64{}
+	for _, metric := range metrics {
+		value := metricValues[metric]
+		if delta := value(after) - value(before); math.Abs(delta) > 1e-9 {
+			changes[metric] = delta
+		}
+	}
+	return changes
+}
+
+// Scope limits the summary to the transactions of one user. The zero Scope
+// covers every transaction.
+type Scope struct {
+	UserID int `json:"user_id,omitempty"`
+}
+
+// Change is the effect of one created, deleted, restored or status changed
+// transaction on the summary. Added is 1 for a transaction that was created
+// or restored and -1 for one that was deleted. UniqueUsers is the number of
+// users that gained their first or lost their last transaction, across all
+// users.
+type Change struct {
+	UserID       int    `json:"user_id"`
+	Added        int    `json:"added"`
+	CreatedToday bool   `json:"created_today"`
+	FromStatus   string `json:"from_status,omitempty"`
+	ToStatus     string `json:"to_status,omitempty"`
+	UniqueUsers  int    `json:"unique_users"`
+}
+
+// Apply returns summary with the change applied, and false when the change
+// is outside scope.
+func (c Change) Apply(summary repository.TransactionSummary, scope Scope) (repository.TransactionSummary, bool) {
+	if scope.UserID != 0 && scope.UserID != c.UserID {
+		return summary, false
+	}
+
+	if c.Added != 0 {
+		summary.TotalTransactions += c.Added
+		if c.CreatedToday {
+			summary.TotalTransactionsToday += c.Added
+		}
+		switch {
+		case scope.UserID == 0:
+			summary.UniqueUsers += c.UniqueUsers
+		case summary.TotalTransactions > 0:
+			summary.UniqueUsers = 1
+		default:
+			summary.UniqueUsers = 0
+		}
+	}
+	addStatus(&summary, c.FromStatus, -1)
+	addStatus(&summary, c.ToStatus, 1)
+
+	summary.AverageTransactionPerUser = 0
+	if summary.UniqueUsers > 0 {
+		summary.AverageTransactionPerUser = float64(summary.TotalTransactions) / float64(summary.UniqueUsers)
+	}
+	return summary, true
+}
+
+func addStatus(summary *repository.TransactionSummary, status string, n int) {
+	switch status {
+	case "pending":
+		summary.TotalPendingTransactions += n
+	case "success":
+		summary.TotalSuccessTransactions += n
+	case "failed":
+		summary.TotalFailedTransactions += n
+	}
+}
@@ -0,0 +1,168 @@
+package dashboard
+
+import (
+	"context"
+	"gin-boilerplate/events"
+	"gin-boilerplate/infra/logger"
+	"gin-boilerplate/models"
+	"time"
+)
+
+const (
+	// EventChange carries a Change.
+	EventChange = "dashboard.change"
+	// EventResync tells subscribers that changes were missed and they
+	// should reload the summary.
+	EventResync = "dashboard.resync"
+)
+
+// UserLookup tells whether a user has live transactions besides one that
+// was just created, deleted or restored.
+type UserLookup interface {
+	// HasTransactionsBefore reports whether the user has transactions with
+	// an ID below id; if not, a just created transaction id is their first.
+	HasTransactionsBefore(userID int, id uint) (bool, error)
+	// HasTransactionsBesides reports whether the user has transactions other
+	// than id.
+	HasTransactionsBesides(userID int, id uint) (bool, error)
+}
+
+// Tracker turns the transaction feed into Changes, published on Changes.
+type Tracker struct {
+	Repo    UserLookup
+	Changes *events.Hub
+
+	// users caches whether a user has live transactions, so only the first
+	// event of a user since the last resync costs a query.
+	users map[int]bool
+	now   func() time.Time
+}
+
+func NewTracker(repo UserLookup, changes *events.Hub) *Tracker {
+	return &Tracker{Repo: repo, Changes: changes, users: map[int]bool{}, now: time.Now}
+}
+
+// Run follows feed until ctx is cancelled.
+func (t *Tracker) Run(ctx context.Context, feed *events.Hub) {
+	for {
+		sub, _, _ := feed.Subscribe(0)
+		if !t.follow(ctx, sub) {
+			return
+		}
+		// Fell behind the feed: start over and make everyone reload.
+		t.users = map[int]bool{}
+		_ = t.Changes.Publish(ctx, events.New(EventResync, nil))
+	}
+}
+
+// follow applies feed events until ctx is done, returning false, or the
+// subscription is dropped, returning true.
+func (t *Tracker) follow(ctx context.Context, sub *events.Subscription) bool {
+	defer sub.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case message, ok := <-sub.C:
+			if !ok {
+				return true
+			}
+			if change, ok := t.change(message.Event); ok {
+				_ = t.Changes.Publish(ctx, events.New(EventChange, change))
+			}
+		}
+	}
+}
+
+// change describes the effect of event, if any, on the summary.
+func (t *Tracker) change(event events.Event) (Change, bool) {
+	switch data := event.Data.(type) {
+	case *models.Transaction:
+		switch event.Type {
+		case events.TransactionCreated:
+			return Change{
+				UserID:       data.UserID,
+				Added:        1,
+				CreatedToday: sameDay(data.CreatedAt, t.now()),
+				ToStatus:     data.Status,
+				UniqueUsers:  t.userAdded(data, t.Repo.HasTransactionsBefore),
+			}, true
+		case events.TransactionRestored:
+			return Change{
+				UserID:       data.UserID,
+				Added:        1,
+				CreatedToday: sameDay(data.CreatedAt, t.now()),
+				ToStatus:     data.Status,
+				UniqueUsers:  t.userAdded(data, t.Repo.HasTransactionsBesides),
+			}, true
+		case events.TransactionDeleted:
+			return Change{
+				UserID:       data.UserID,
+				Added:        -1,
+				CreatedToday: sameDay(data.CreatedAt, t.now()),
+				FromStatus:   data.Status,
+				UniqueUsers:  -t.userRemoved(data),
+			}, true
+		}
+	case events.StatusChanged:
+		if data.PreviousStatus == data.Transaction.Status {
+			return Change{}, false
+		}
+		return Change{
+			UserID:     data.Transaction.UserID,
+			FromStatus: data.PreviousStatus,
+			ToStatus:   data.Transaction.Status,
+		}, true
+	}
+	return Change{}, false
+}
+
+// userAdded returns 1 if transaction, just created or restored, made its
+// user a unique user. others is only asked about users not cached yet.
+//
+// The answer assumes the feed is in commit order. When the first two
+// transactions of a user commit close together and their events arrive in
+// the other order, the later ID sees the earlier one and neither counts the
+// user, so unique_users stays one short. Live dashboards are corrected by
+// the fresh snapshot they get every DASHBOARD_RESYNC_INTERVAL, which reads
+// the figure from the database.
+func (t *Tracker) userAdded(transaction *models.Transaction, others func(userID int, id uint) (bool, error)) int {
+	had, cached := t.users[transaction.UserID]
+	if !cached {
+		var err error
+		if had, err = others(transaction.UserID, transaction.ID); err != nil {
+			logger.Errorf("dashboard user lookup error: %s", err)
+			return 0
+		}
+	}
+	t.users[transaction.UserID] = true
+	if had {
+		return 0
+	}
+	return 1
+}
+
+// userRemoved returns 1 if deleting transaction left its user without
+// transactions.
+func (t *Tracker) userRemoved(transaction *models.Transaction) int {
+	if has, cached := t.users[transaction.UserID]; cached && !has {
+		return 0
+	}
+	has, err := t.Repo.HasTransactionsBesides(transaction.UserID, transaction.ID)
+	if err != nil {
+		logger.Errorf("dashboard user lookup error: %s", err)
+		delete(t.users, transaction.UserID)
+		return 0
+	}
+	t.users[transaction.UserID] = has
+	if has {
+		return 0
+	}
+	return 1
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.In(time.Local).Date()
+	by, bm, bd := b.In(time.Local).Date()
+	return ay == by && am == bm && ad == bd
+}
@@ -0,0 +1,60 @@
+// Package events defines the transaction lifecycle events and the publishers
+// the outbox relay hands them to.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	TransactionCreated       = "transaction.created"
+	TransactionStatusChanged = "transaction.status_changed"
+	TransactionDeleted       = "transaction.deleted"
+	TransactionRestored      = "transaction.restored"
+
+	// TransactionUpdated is only published on the live feed, for any change
+	// to an existing transaction.
+	TransactionUpdated = "transaction.updated"
+)
+
+// Types lists every event type that can be subscribed to by webhooks.
+var Types = []string{TransactionCreated, TransactionStatusChanged, TransactionDeleted, TransactionRestored}
+
+// Event is a change that has been committed to the database. RequestID is
+// the request that made the change, forwarded as X-Request-ID by the
+// publishers that make HTTP calls.
+type Event struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+	RequestID  string      `json:"-"`
+}
+
+// New returns an event of the given type with a random ID.
+func New(eventType string, data interface{}) Event {
+	return Event{
+		ID:         newID(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Publisher delivers events somewhere. An error means the event was not
+// delivered and will be published again, so publishers must tolerate
+// duplicates.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
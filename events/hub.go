@@ -0,0 +1,124 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// Message is an event numbered by the Hub that published it. Seq starts at 1
+// and increases by one per event, so a subscriber can resume after the last
+// Seq it saw.
+type Message struct {
+	Seq   uint64
+	Event Event
+}
+
+// Hub is an in-process Publisher that fans events out to subscribers and
+// keeps the latest ones for subscribers that reconnect.
+type Hub struct {
+	mu      sync.Mutex
+	seq     uint64
+	history []Message
+	next    int
+	subs    map[*Subscription]struct{}
+	buffer  int
+}
+
+// NewHub returns a Hub that keeps the last history events for replay and
+// buffers up to buffer events per subscriber.
+func NewHub(history, buffer int) *Hub {
+	if history < 1 {
+		history = 1
+	}
+	if buffer < 1 {
+		buffer = 1
+	}
+	return &Hub{
+		history: make([]Message, 0, history),
+		subs:    map[*Subscription]struct{}{},
+		buffer:  buffer,
+	}
+}
+
+// Subscription receives the events published after it was created. C is
+// closed when the subscription is closed, either by Close or by the Hub
+// because the subscriber fell behind; it can then resubscribe after the
+// last Seq it received.
+type Subscription struct {
+	C <-chan Message
+
+	c    chan Message
+	hub  *Hub
+	once sync.Once
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.close()
+}
+
+// close must be called with the hub locked.
+func (s *Subscription) close() {
+	s.once.Do(func() {
+		delete(s.hub.subs, s)
+		close(s.c)
+	})
+}
+
+func (h *Hub) Publish(ctx context.Context, event Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	message := Message{Seq: h.seq, Event: event}
+	if len(h.history) < cap(h.history) {
+		h.history = append(h.history, message)
+	} else {
+		h.history[h.next] = message
+		h.next = (h.next + 1) % len(h.history)
+	}
+
+	for sub := range h.subs {
+		select {
+		case sub.c <- message:
+		default:
+			sub.close()
+		}
+	}
+	return nil
+}
+
+// Subscribe starts a subscription and returns the kept events after Seq
+// after. A zero after replays nothing. complete is false when events after
+// it are no longer kept, or after is from before the Hub was started, so
+// the subscriber may have missed some.
+func (h *Hub) Subscribe(after uint64) (sub *Subscription, replay []Message, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := make(chan Message, h.buffer)
+	sub = &Subscription{C: c, c: c, hub: h}
+	h.subs[sub] = struct{}{}
+
+	if after == 0 {
+		return sub, nil, true
+	}
+	if after > h.seq {
+		return sub, nil, false
+	}
+
+	complete = true
+	if len(h.history) > 0 {
+		oldest := h.history[h.next].Seq
+		complete = after+1 >= oldest
+	}
+	for i := 0; i < len(h.history); i++ {
+		message := h.history[(h.next+i)%len(h.history)]
+		if message.Seq > after {
+			replay = append(replay, message)
+		}
+	}
+	return sub, replay, complete
+}
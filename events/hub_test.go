@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func publishN(hub *Hub, n int) {
+	for i := 0; i < n; i++ {
+		_ = hub.Publish(context.Background(), New(TransactionCreated, i))
+	}
+}
+
+func seqs(messages []Message) []uint64 {
+	var out []uint64
+	for _, message := range messages {
+		out = append(out, message.Seq)
+	}
+	return out
+}
+
+func TestHubReplay(t *testing.T) {
+	hub := NewHub(3, 10)
+	publishN(hub, 5)
+
+	sub, replay, complete := hub.Subscribe(2)
+	defer sub.Close()
+	assert.True(t, complete)
+	assert.Equal(t, []uint64{3, 4, 5}, seqs(replay))
+
+	_, replay, complete = hub.Subscribe(1)
+	assert.False(t, complete, "event 2 is no longer kept")
+	assert.Equal(t, []uint64{3, 4, 5}, seqs(replay))
+
+	_, replay, complete = hub.Subscribe(5)
+	assert.True(t, complete)
+	assert.Empty(t, replay)
+
+	// An id from before a restart.
+	_, _, complete = hub.Subscribe(99)
+	assert.False(t, complete)
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	hub := NewHub(10, 2)
+	fast, _, _ := hub.Subscribe(0)
+	slow, _, _ := hub.Subscribe(0)
+	defer fast.Close()
+
+	_ = hub.Publish(context.Background(), New(TransactionCreated, 1))
+	<-fast.C
+	publishN(hub, 2)
+	<-fast.C
+	<-fast.C
+	publishN(hub, 1)
+
+	var received []Message
+	for message := range slow.C {
+		received = append(received, message)
+	}
+	assert.Equal(t, []uint64{1, 2}, seqs(received), "closed once its buffer overflowed")
+
+	message, ok := <-fast.C
+	assert.True(t, ok)
+	assert.Equal(t, uint64(4), message.Seq)
+
+	// Closing twice is fine.
+	slow.Close()
+}
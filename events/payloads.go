@@ -0,0 +1,15 @@
+package events
+
+import "gin-boilerplate/models"
+
+// StatusChanged is the data of a transaction.status_changed event.
+type StatusChanged struct {
+	Transaction    *models.Transaction `json:"transaction"`
+	PreviousStatus string              `json:"previous_status"`
+}
+
+// Deleted is the data of a transaction.deleted event in the outbox. The live
+// feed carries the deleted transaction itself.
+type Deleted struct {
+	ID uint `json:"id"`
+}
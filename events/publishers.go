@@ -0,0 +1,82 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gin-boilerplate/infra/logger"
+	"gin-boilerplate/infra/requestid"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// LogPublisher writes events to the application log.
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	logger.Infof("event %s %s: %s", event.Type, event.ID, data)
+	return nil
+}
+
+// HTTPPublisher posts every event as JSON to URL. Any response other than
+// 2xx is a failure.
+type HTTPPublisher struct {
+	URL    string
+	Client *http.Client
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	requestid.SetHeader(req, event.RequestID)
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("publish %s: unexpected status %d", event.ID, resp.StatusCode)
+	}
+	return nil
+}
+
+// MemoryPublisher keeps published events in memory, for tests and local runs.
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, event Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+// Events returns the events published so far.
+func (p *MemoryPublisher) Events() []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Event(nil), p.events...)
+}
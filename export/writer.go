@@ -0,0 +1,165 @@
+// Package export writes tabular data as CSV, NDJSON or XLSX, one row at a
+// time, so large result sets can be streamed straight to an HTTP response.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Writer receives a header followed by rows whose values line up with it.
+// Values may be strings, integers, floats, nil or anything JSON encodable.
+type Writer interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []interface{}) error
+	// Close flushes buffered output. It does not close the underlying writer.
+	Close() error
+}
+
+// Format describes an export format.
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	newWriter   func(io.Writer) Writer
+}
+
+// NewWriter returns a Writer in this format on top of w.
+func (f Format) NewWriter(w io.Writer) Writer {
+	return f.newWriter(w)
+}
+
+var formats = map[string]Format{
+	"csv": {
+		Name:        "csv",
+		ContentType: "text/csv; charset=utf-8",
+		Extension:   "csv",
+		newWriter:   func(w io.Writer) Writer { return &csvWriter{w: csv.NewWriter(w)} },
+	},
+	"ndjson": {
+		Name:        "ndjson",
+		ContentType: "application/x-ndjson",
+		Extension:   "ndjson",
+		newWriter:   func(w io.Writer) Writer { return &ndjsonWriter{w: bufio.NewWriter(w)} },
+	},
+	"xlsx": {
+		Name:        "xlsx",
+		ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		Extension:   "xlsx",
+		newWriter:   newXLSXWriter,
+	},
+}
+
+// Lookup returns the format registered under name.
+func Lookup(name string) (Format, bool) {
+	f, ok := formats[name]
+	return f, ok
+}
+
+// FormatValue renders a value as text, as used by CSV cells.
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case fmt.Stringer:
+		return v.String()
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
+
+// cellText renders a value for a CSV or XLSX cell. Text starting with =, +,
+// -, @, a tab or a carriage return gets a leading ' so spreadsheet programs
+// show it instead of running it as a formula; numbers are left alone.
+func cellText(value interface{}) string {
+	text := FormatValue(value)
+	switch value.(type) {
+	case int, int64, uint, float64:
+		return text
+	}
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) WriteHeader(columns []string) error {
+	return c.w.Write(columns)
+}
+
+func (c *csvWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = cellText(v)
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonWriter writes one JSON object per row, keys in column order.
+type ndjsonWriter struct {
+	w       *bufio.Writer
+	columns []string
+}
+
+func (n *ndjsonWriter) WriteHeader(columns []string) error {
+	n.columns = make([]string, len(columns))
+	for i, column := range columns {
+		key, err := json.Marshal(column)
+		if err != nil {
+			return err
+		}
+		n.columns[i] = string(key)
+	}
+	return nil
+}
+
+func (n *ndjsonWriter) WriteRow(values []interface{}) error {
+	n.w.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			n.w.WriteByte(',')
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		n.w.WriteString(n.columns[i])
+		n.w.WriteByte(':')
+		n.w.Write(value)
+	}
+	n.w.WriteString("}\n")
+	return nil
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.w.Flush()
+}
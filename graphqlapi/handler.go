@@ -0,0 +1,99 @@
+// Package graphqlapi serves a read-only GraphQL API on /graphql for
+// dashboards that need their own combinations of transaction fields, per
+// user stats and summary aggregates. Queries are checked against Limits
+// before they run, and per user lookups are batched per request.
+package graphqlapi
+
+import (
+	"encoding/json"
+	"gin-boilerplate/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"net/http"
+)
+
+// Request is a GraphQL request, sent as a JSON body or as query parameters.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler executes GraphQL requests against the transaction repository.
+type Handler struct {
+	repo   repository.TransactionRepository
+	limits Limits
+	schema graphql.Schema
+}
+
+// NewHandler builds the schema. Zero limits fall back to DefaultLimits.
+func NewHandler(repo repository.TransactionRepository, limits Limits) (*Handler, error) {
+	limits = limits.withDefaults()
+	schema, err := newSchema(repo, limits)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{repo: repo, limits: limits, schema: schema}, nil
+}
+
+// Serve handles GET and POST /graphql. Requests that cannot run at all
+// (malformed, invalid or over the limits) get a 400; once execution starts
+// the response is 200 with any resolver errors in "errors".
+func (h *Handler) Serve(ctx *gin.Context) {
+	var req Request
+	if ctx.Request.Method == http.MethodGet {
+		req.Query = ctx.Query("query")
+		req.OperationName = ctx.Query("operationName")
+		if raw := ctx.Query("variables"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &req.Variables); err != nil {
+				badRequest(ctx, "invalid_variables", "Invalid variables")
+				return
+			}
+		}
+	} else if err := json.NewDecoder(ctx.Request.Body).Decode(&req); err != nil {
+		badRequest(ctx, "invalid_body", "Invalid request body")
+		return
+	}
+	if req.Query == "" {
+		badRequest(ctx, "query_required", "Query is required")
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})})
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+	if validation := graphql.ValidateDocument(&h.schema, doc, nil); !validation.IsValid {
+		ctx.JSON(http.StatusBadRequest, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+	if err := checkLimits(doc, req.OperationName, req.Variables, h.limits); err != nil {
+		ctx.JSON(http.StatusBadRequest, &graphql.Result{Errors: []gqlerrors.FormattedError{formatError(err)}})
+		return
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoaders(ctx.Request.Context(), h.repo.WithContext(ctx.Request.Context())),
+	})
+	ctx.JSON(http.StatusOK, result)
+}
+
+func formatError(err *limitError) gqlerrors.FormattedError {
+	formatted := gqlerrors.FormatError(err)
+	formatted.Extensions = err.Extensions()
+	return formatted
+}
+
+func badRequest(ctx *gin.Context, code, message string) {
+	formatted := gqlerrors.NewFormattedError(message)
+	formatted.Extensions = map[string]interface{}{"code": code}
+	ctx.JSON(http.StatusBadRequest, &graphql.Result{Errors: []gqlerrors.FormattedError{formatted}})
+}
@@ -0,0 +1,171 @@
+package graphqlapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gin-boilerplate/models"
+	"gin-boilerplate/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRepo serves a fixed set of transactions and records the calls the
+// resolvers make. Unused methods are left to the embedded nil interface.
+type fakeRepo struct {
+	repository.TransactionRepository
+	transactions  []models.Transaction
+	sort          repository.TransactionSort
+	userStatCalls [][]int
+}
+
+func (r *fakeRepo) WithContext(ctx context.Context) repository.TransactionRepository {
+	return r
+}
+
+func (r *fakeRepo) GetSortedTransactionsWithFilters(transactions *[]models.Transaction, pageNumber, pageSize int, status string, userID int, includeDeleted bool, sort repository.TransactionSort) (int64, error) {
+	r.sort = sort
+	*transactions = append(*transactions, r.transactions...)
+	return int64(len(r.transactions)), nil
+}
+
+func (r *fakeRepo) GetTransactionByID(id int) (*models.Transaction, error) {
+	for i := range r.transactions {
+		if int(r.transactions[i].ID) == id {
+			return &r.transactions[i], nil
+		}
+	}
+	return nil, repository.ErrTransactionNotFound
+}
+
+func (r *fakeRepo) GetUserStats(userIDs []int) ([]repository.UserStats, error) {
+	r.userStatCalls = append(r.userStatCalls, userIDs)
+	var stats []repository.UserStats
+	for _, userID := range userIDs {
+		if userID == 3 {
+			continue
+		}
+		stats = append(stats, repository.UserStats{UserID: userID, TotalTransactions: userID * 2, TotalAmount: int64(userID * 100)})
+	}
+	return stats, nil
+}
+
+func newFakeRepo() *fakeRepo {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return &fakeRepo{transactions: []models.Transaction{
+		{ID: 1, UserID: 1, Amount: 10, Status: "pending", Version: 1, CreatedAt: now, UpdatedAt: now},
+		{ID: 2, UserID: 2, Amount: 20, Status: "success", Version: 1, CreatedAt: now, UpdatedAt: now, Metadata: models.JSONMap{"channel": "web"}},
+		{ID: 3, UserID: 1, Amount: 30, Status: "failed", Version: 2, CreatedAt: now, UpdatedAt: now},
+	}}
+}
+
+type response struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func query(t *testing.T, h *Handler, body string) (int, response) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/graphql", h.Serve)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/graphql", bytes.NewBufferString(body))
+	router.ServeHTTP(w, req)
+
+	var res response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res), w.Body.String())
+	return w.Code, res
+}
+
+func TestTransactions_BatchesUserStats(t *testing.T) {
+	repo := newFakeRepo()
+	h, err := NewHandler(repo, Limits{})
+	require.NoError(t, err)
+
+	code, res := query(t, h, `{"query": "{ transactions(sortBy: AMOUNT, sortOrder: DESC) { totalRecordCount items { id amount metadata user { userId totalTransactions totalAmount } } } }"}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, res.Errors)
+
+	assert.Equal(t, repository.TransactionSort{Field: "amount", Desc: true}, repo.sort)
+	assert.Equal(t, [][]int{{1, 2}}, repo.userStatCalls, "user stats should be loaded in one batch")
+
+	page := res.Data["transactions"].(map[string]interface{})
+	assert.Equal(t, float64(3), page["totalRecordCount"])
+	items := page["items"].([]interface{})
+	require.Len(t, items, 3)
+	second := items[1].(map[string]interface{})
+	assert.Equal(t, "2", second["id"])
+	assert.Equal(t, map[string]interface{}{"channel": "web"}, second["metadata"])
+	assert.Equal(t, map[string]interface{}{"userId": float64(2), "totalTransactions": float64(4), "totalAmount": float64(200)}, second["user"])
+}
+
+func TestUserStats_FillsMissingUsers(t *testing.T) {
+	repo := newFakeRepo()
+	h, err := NewHandler(repo, Limits{})
+	require.NoError(t, err)
+
+	code, res := query(t, h, `{"query": "query($ids: [Int!]!) { userStats(userIds: $ids) { userId totalTransactions } transaction(id: \"9\") { id } }", "variables": {"ids": [2, 3]}}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, res.Errors)
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"userId": float64(2), "totalTransactions": float64(4)},
+		map[string]interface{}{"userId": float64(3), "totalTransactions": float64(0)},
+	}, res.Data["userStats"])
+	assert.Nil(t, res.Data["transaction"])
+	assert.Len(t, repo.userStatCalls, 1)
+}
+
+func TestLimits(t *testing.T) {
+	h, err := NewHandler(newFakeRepo(), Limits{MaxComplexity: 50, MaxDepth: 3})
+	require.NoError(t, err)
+
+	code, res := query(t, h, `{"query": "query($size: Int) { transactions(pageSize: $size) { items { id amount } } }", "variables": {"size": 30}}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, "query_too_complex", res.Errors[0].Extensions["code"])
+	assert.Equal(t, float64(91), res.Errors[0].Extensions["value"])
+
+	code, res = query(t, h, `{"query": "{ transactions { items { user { userId } } } }"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "query_too_deep", res.Errors[0].Extensions["code"])
+
+	code, res = query(t, h, `{"query": "{ transactions(pageSize: 5) { items { id amount } } }"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, res.Errors)
+}
+
+func TestInvalidRequests(t *testing.T) {
+	h, err := NewHandler(newFakeRepo(), Limits{})
+	require.NoError(t, err)
+
+	code, res := query(t, h, `{"query": "{ transactions { nope } }"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.NotEmpty(t, res.Errors)
+
+	code, res = query(t, h, `{"query": "{ transactions(pageSize: 500) { totalRecordCount } }"}`)
+	assert.Equal(t, http.StatusOK, code)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, "invalid_argument", res.Errors[0].Extensions["code"])
+
+	code, res = query(t, h, `{"query": "{ transactions(includeDeleted: true) { totalRecordCount } }"}`)
+	assert.Equal(t, http.StatusOK, code)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, "invalid_argument", res.Errors[0].Extensions["code"])
+	assert.Contains(t, fmt.Sprint(res.Errors[0].Extensions["fields"]), "code:disabled")
+
+	code, res = query(t, h, `not json`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "invalid_body", res.Errors[0].Extensions["code"])
+}
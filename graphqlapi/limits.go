@@ -0,0 +1,187 @@
+package graphqlapi
+
+import (
+	"fmt"
+	"github.com/graphql-go/graphql/language/ast"
+	"strconv"
+	"strings"
+)
+
+// Limits bound what a single query may ask for.
+type Limits struct {
+	// MaxComplexity caps the estimated number of resolved fields.
+	MaxComplexity int
+	// MaxDepth caps how deeply selections may nest.
+	MaxDepth int
+	// MaxPageSize caps pageSize of the transactions field.
+	MaxPageSize int
+	// IncludeDeleted allows includeDeleted: true on the transactions field.
+	IncludeDeleted bool
+}
+
+// DefaultLimits are used for any limit left at zero.
+var DefaultLimits = Limits{
+	MaxComplexity: 1000,
+	MaxDepth:      8,
+	MaxPageSize:   100,
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxComplexity <= 0 {
+		l.MaxComplexity = DefaultLimits.MaxComplexity
+	}
+	if l.MaxDepth <= 0 {
+		l.MaxDepth = DefaultLimits.MaxDepth
+	}
+	if l.MaxPageSize <= 0 {
+		l.MaxPageSize = DefaultLimits.MaxPageSize
+	}
+	return l
+}
+
+// limitError is returned when a query exceeds Limits.
+type limitError struct {
+	code    string
+	message string
+	value   int
+	limit   int
+}
+
+func (e *limitError) Error() string {
+	return e.message
+}
+
+func (e *limitError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code, "value": e.value, "limit": e.limit}
+}
+
+// complexity estimates the cost of an operation before it runs. Every field
+// costs 1 plus the cost of its selections, and list fields multiply the cost
+// of their selections by the number of items they may return: pageSize for
+// transactions and the number of userIds for userStats. Introspection
+// fields are free so tools can load the schema.
+type complexity struct {
+	fragments   map[string]*ast.FragmentDefinition
+	variables   map[string]interface{}
+	maxPageSize int
+}
+
+// checkLimits returns an error when the selected operation is too deep
+// or too expensive. doc must already be validated.
+func checkLimits(doc *ast.Document, operationName string, variables map[string]interface{}, limits Limits) *limitError {
+	c := &complexity{
+		fragments:   map[string]*ast.FragmentDefinition{},
+		variables:   variables,
+		maxPageSize: limits.MaxPageSize,
+	}
+
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			c.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				operation = def
+			}
+		}
+	}
+	if operation == nil {
+		return nil
+	}
+
+	cost, depth := c.selections(operation.SelectionSet)
+	if depth > limits.MaxDepth {
+		return &limitError{code: "query_too_deep", message: fmt.Sprintf("Query depth %d exceeds the limit of %d", depth, limits.MaxDepth), value: depth, limit: limits.MaxDepth}
+	}
+	if cost > limits.MaxComplexity {
+		return &limitError{code: "query_too_complex", message: fmt.Sprintf("Query complexity %d exceeds the limit of %d", cost, limits.MaxComplexity), value: cost, limit: limits.MaxComplexity}
+	}
+	return nil
+}
+
+// selections returns the cost and depth of a selection set.
+func (c *complexity) selections(set *ast.SelectionSet) (int, int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	cost, depth := 0, 0
+	for _, selection := range set.Selections {
+		var selCost, selDepth int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+			childCost, childDepth := c.selections(selection.SelectionSet)
+			selCost = 1 + c.multiplier(selection)*childCost
+			selDepth = 1 + childDepth
+		case *ast.InlineFragment:
+			selCost, selDepth = c.selections(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			if fragment, ok := c.fragments[selection.Name.Value]; ok {
+				selCost, selDepth = c.selections(fragment.SelectionSet)
+			}
+		}
+		cost += selCost
+		if selDepth > depth {
+			depth = selDepth
+		}
+	}
+	return cost, depth
+}
+
+// multiplier is how many times the selections of field are resolved.
+func (c *complexity) multiplier(field *ast.Field) int {
+	switch field.Name.Value {
+	case "transactions":
+		size, ok := c.intArgument(field, "pageSize")
+		if !ok {
+			return defaultPageSize
+		}
+		if size > c.maxPageSize {
+			return c.maxPageSize
+		}
+		return size
+	case "userStats":
+		return c.listArgumentLen(field, "userIds")
+	}
+	return 1
+}
+
+func (c *complexity) argument(field *ast.Field, name string) interface{} {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != name {
+			continue
+		}
+		if variable, ok := arg.Value.(*ast.Variable); ok {
+			return c.variables[variable.Name.Value]
+		}
+		return arg.Value
+	}
+	return nil
+}
+
+func (c *complexity) intArgument(field *ast.Field, name string) (int, bool) {
+	switch value := c.argument(field, name).(type) {
+	case *ast.IntValue:
+		n, err := strconv.Atoi(value.Value)
+		return n, err == nil
+	case float64:
+		return int(value), true
+	case int:
+		return value, true
+	}
+	return 0, false
+}
+
+func (c *complexity) listArgumentLen(field *ast.Field, name string) int {
+	switch value := c.argument(field, name).(type) {
+	case *ast.ListValue:
+		return len(value.Values)
+	case []interface{}:
+		return len(value)
+	}
+	return 1
+}
@@ -0,0 +1,124 @@
+package graphqlapi
+
+import (
+	"context"
+	"gin-boilerplate/repository"
+	"sync"
+)
+
+type loadersKey struct{}
+
+// loaders holds the per request batch loaders. They live for one request so
+// cached results never outlast it.
+type loaders struct {
+	userStats *userStatsLoader
+}
+
+func withLoaders(ctx context.Context, repo repository.TransactionRepository) context.Context {
+	return context.WithValue(ctx, loadersKey{}, &loaders{
+		userStats: &userStatsLoader{repo: repo, cache: map[int]*repository.UserStats{}},
+	})
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// userStatsLoader batches user stats lookups. Load only queues the user and
+// returns a thunk; the executor resolves all thunks of a level after the
+// level is walked, so the first thunk fetches every queued user in one
+// GetUserStats call instead of one query per transaction.
+type userStatsLoader struct {
+	repo repository.TransactionRepository
+
+	mu      sync.Mutex
+	pending []int
+	cache   map[int]*repository.UserStats
+	errs    map[int]error
+}
+
+// Load returns a thunk resolving to the stats of userID.
+func (l *userStatsLoader) Load(userID int) func() (interface{}, error) {
+	l.mu.Lock()
+	if _, ok := l.cache[userID]; !ok && !containsInt(l.pending, userID) {
+		l.pending = append(l.pending, userID)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		stats, err := l.get(userID)
+		if err != nil {
+			return nil, resolveError(err)
+		}
+		return stats, nil
+	}
+}
+
+// LoadMany is Load for several users, resolving to their stats in order.
+func (l *userStatsLoader) LoadMany(userIDs []int) func() (interface{}, error) {
+	thunks := make([]func() (interface{}, error), len(userIDs))
+	for i, userID := range userIDs {
+		thunks[i] = l.Load(userID)
+	}
+
+	return func() (interface{}, error) {
+		stats := make([]*repository.UserStats, len(thunks))
+		for i, thunk := range thunks {
+			value, err := thunk()
+			if err != nil {
+				return nil, err
+			}
+			stats[i] = value.(*repository.UserStats)
+		}
+		return stats, nil
+	}
+}
+
+func (l *userStatsLoader) get(userID int) (*repository.UserStats, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.pending) > 0 {
+		l.fetch()
+	}
+	if err := l.errs[userID]; err != nil {
+		return nil, err
+	}
+	return l.cache[userID], nil
+}
+
+// fetch loads every pending user. Users without transactions get empty
+// stats so they are not looked up again.
+func (l *userStatsLoader) fetch() {
+	batch := l.pending
+	l.pending = nil
+
+	stats, err := l.repo.GetUserStats(batch)
+	if err != nil {
+		if l.errs == nil {
+			l.errs = map[int]error{}
+		}
+		for _, userID := range batch {
+			l.errs[userID] = err
+		}
+		return
+	}
+
+	for i := range stats {
+		l.cache[stats[i].UserID] = &stats[i]
+	}
+	for _, userID := range batch {
+		if _, ok := l.cache[userID]; !ok {
+			l.cache[userID] = &repository.UserStats{UserID: userID}
+		}
+	}
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,318 @@
+package graphqlapi
+
+import (
+	"errors"
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/infra/logger"
+	"gin-boilerplate/models"
+	"gin-boilerplate/repository"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"strconv"
+)
+
+const defaultPageSize = 10
+
+// resolverError exposes the code and rejected fields of an apperror in the
+// GraphQL error extensions.
+type resolverError struct {
+	err *apperror.Error
+}
+
+func (e *resolverError) Error() string {
+	return e.err.Message
+}
+
+func (e *resolverError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.err.Code}
+	if len(e.err.Fields) > 0 {
+		extensions["fields"] = e.err.Fields
+	}
+	return extensions
+}
+
+// resolveError hides internal errors from clients and logs them instead.
+func resolveError(err error) error {
+	appErr := apperror.From(err)
+	if appErr.Kind == apperror.KindInternal {
+		logger.Errorf("graphql: %v", err)
+		appErr = apperror.Internal(appErr.Code, "Internal server error")
+	}
+	return &resolverError{err: appErr}
+}
+
+func invalidArgument(field, code, message string) error {
+	return resolveError(apperror.Validation("invalid_argument", "Invalid argument", apperror.FieldError{Field: field, Code: code, Message: message}))
+}
+
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "Free-form JSON object.",
+	Serialize: func(value interface{}) interface{} {
+		if m, ok := value.(models.JSONMap); ok {
+			return map[string]interface{}(m)
+		}
+		return value
+	},
+	ParseValue: func(value interface{}) interface{} {
+		return value
+	},
+	ParseLiteral: func(value ast.Value) interface{} {
+		return nil
+	},
+})
+
+var sortFieldEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "TransactionSortField",
+	Values: graphql.EnumValueConfigMap{
+		"ID":         {Value: "id"},
+		"USER_ID":    {Value: "user_id"},
+		"AMOUNT":     {Value: "amount"},
+		"STATUS":     {Value: "status"},
+		"CREATED_AT": {Value: "created_at"},
+		"UPDATED_AT": {Value: "updated_at"},
+	},
+})
+
+var sortOrderEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "SortOrder",
+	Values: graphql.EnumValueConfigMap{
+		"ASC":  {Value: "asc"},
+		"DESC": {Value: "desc"},
+	},
+})
+
+// transactionField resolves a field of a *models.Transaction source.
+func transactionField(typ graphql.Output, get func(t *models.Transaction) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: typ,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(*models.Transaction)), nil
+		},
+	}
+}
+
+// userStatsField resolves a field of a *repository.UserStats source.
+func userStatsField(typ graphql.Output, get func(s *repository.UserStats) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: typ,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(*repository.UserStats)), nil
+		},
+	}
+}
+
+// summaryField resolves a field of a repository.TransactionSummary source.
+func summaryField(typ graphql.Output, get func(s repository.TransactionSummary) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: typ,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(repository.TransactionSummary)), nil
+		},
+	}
+}
+
+var userStatsType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "UserStats",
+	Description: "Aggregates over the live transactions of one user.",
+	Fields: graphql.Fields{
+		"userId":            userStatsField(graphql.NewNonNull(graphql.Int), func(s *repository.UserStats) interface{} { return s.UserID }),
+		"totalTransactions": userStatsField(graphql.NewNonNull(graphql.Int), func(s *repository.UserStats) interface{} { return s.TotalTransactions }),
+		// Float because sums quickly exceed the 32 bit GraphQL Int.
+		"totalAmount": userStatsField(graphql.NewNonNull(graphql.Float), func(s *repository.UserStats) interface{} { return float64(s.TotalAmount) }),
+		"averageAmount": userStatsField(graphql.NewNonNull(graphql.Float), func(s *repository.UserStats) interface{} {
+			if s.TotalTransactions == 0 {
+				return 0.0
+			}
+			return float64(s.TotalAmount) / float64(s.TotalTransactions)
+		}),
+		"totalPendingTransactions": userStatsField(graphql.NewNonNull(graphql.Int), func(s *repository.UserStats) interface{} { return s.TotalPendingTransactions }),
+		"totalSuccessTransactions": userStatsField(graphql.NewNonNull(graphql.Int), func(s *repository.UserStats) interface{} { return s.TotalSuccessTransactions }),
+		"totalFailedTransactions":  userStatsField(graphql.NewNonNull(graphql.Int), func(s *repository.UserStats) interface{} { return s.TotalFailedTransactions }),
+		"lastTransactionAt": userStatsField(graphql.DateTime, func(s *repository.UserStats) interface{} {
+			if s.LastTransactionAt == nil {
+				return nil
+			}
+			return *s.LastTransactionAt
+		}),
+	},
+})
+
+var transactionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Transaction",
+	Fields: graphql.Fields{
+		"id":          transactionField(graphql.NewNonNull(graphql.ID), func(t *models.Transaction) interface{} { return strconv.FormatUint(uint64(t.ID), 10) }),
+		"userId":      transactionField(graphql.NewNonNull(graphql.Int), func(t *models.Transaction) interface{} { return t.UserID }),
+		"amount":      transactionField(graphql.NewNonNull(graphql.Int), func(t *models.Transaction) interface{} { return t.Amount }),
+		"status":      transactionField(graphql.NewNonNull(graphql.String), func(t *models.Transaction) interface{} { return t.Status }),
+		"description": transactionField(graphql.NewNonNull(graphql.String), func(t *models.Transaction) interface{} { return t.Description }),
+		"reference":   transactionField(graphql.NewNonNull(graphql.String), func(t *models.Transaction) interface{} { return t.Reference }),
+		"metadata":    transactionField(jsonScalar, func(t *models.Transaction) interface{} { return t.Metadata }),
+		"version":     transactionField(graphql.NewNonNull(graphql.Int), func(t *models.Transaction) interface{} { return t.Version }),
+		"createdAt":   transactionField(graphql.NewNonNull(graphql.DateTime), func(t *models.Transaction) interface{} { return t.CreatedAt }),
+		"updatedAt":   transactionField(graphql.NewNonNull(graphql.DateTime), func(t *models.Transaction) interface{} { return t.UpdatedAt }),
+		"deletedAt": transactionField(graphql.DateTime, func(t *models.Transaction) interface{} {
+			if !t.DeletedAt.Valid {
+				return nil
+			}
+			return t.DeletedAt.Time
+		}),
+		"user": {
+			Type:        graphql.NewNonNull(userStatsType),
+			Description: "Stats of the transaction's user, loaded in one query for the whole result.",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				t := p.Source.(*models.Transaction)
+				return loadersFrom(p.Context).userStats.Load(t.UserID), nil
+			},
+		},
+	},
+})
+
+var transactionPageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "TransactionPage",
+	Fields: graphql.Fields{
+		"items":            &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(transactionType)))},
+		"pageNumber":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"pageSize":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"totalRecordCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var summaryType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "Summary",
+	Description: "Dashboard aggregates, as returned by GET /dashboard/summary.",
+	Fields: graphql.Fields{
+		"totalTransactionsToday":    summaryField(graphql.NewNonNull(graphql.Int), func(s repository.TransactionSummary) interface{} { return s.TotalTransactionsToday }),
+		"averageTransactionPerUser": summaryField(graphql.NewNonNull(graphql.Float), func(s repository.TransactionSummary) interface{} { return s.AverageTransactionPerUser }),
+		"totalTransactions":         summaryField(graphql.NewNonNull(graphql.Int), func(s repository.TransactionSummary) interface{} { return s.TotalTransactions }),
+		"uniqueUsers":               summaryField(graphql.NewNonNull(graphql.Int), func(s repository.TransactionSummary) interface{} { return s.UniqueUsers }),
+		"totalPendingTransactions":  summaryField(graphql.NewNonNull(graphql.Int), func(s repository.TransactionSummary) interface{} { return s.TotalPendingTransactions }),
+		"totalSuccessTransactions":  summaryField(graphql.NewNonNull(graphql.Int), func(s repository.TransactionSummary) interface{} { return s.TotalSuccessTransactions }),
+		"totalFailedTransactions":   summaryField(graphql.NewNonNull(graphql.Int), func(s repository.TransactionSummary) interface{} { return s.TotalFailedTransactions }),
+	},
+})
+
+// newSchema builds the read-only schema served on /graphql.
+func newSchema(repo repository.TransactionRepository, limits Limits) (graphql.Schema, error) {
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"transactions": &graphql.Field{
+				Type:        graphql.NewNonNull(transactionPageType),
+				Description: "Transactions matching the filters, one page at a time.",
+				Args: graphql.FieldConfigArgument{
+					"status":         {Type: graphql.String},
+					"userId":         {Type: graphql.Int},
+					"includeDeleted": {Type: graphql.Boolean, DefaultValue: false},
+					"pageNumber":     {Type: graphql.Int, DefaultValue: 1},
+					"pageSize":       {Type: graphql.Int, DefaultValue: defaultPageSize},
+					"sortBy":         {Type: sortFieldEnum},
+					"sortOrder":      {Type: sortOrderEnum, DefaultValue: "asc"},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					pageNumber, _ := p.Args["pageNumber"].(int)
+					pageSize, _ := p.Args["pageSize"].(int)
+					status, _ := p.Args["status"].(string)
+					userID, _ := p.Args["userId"].(int)
+					includeDeleted, _ := p.Args["includeDeleted"].(bool)
+					sortBy, _ := p.Args["sortBy"].(string)
+					sortOrder, _ := p.Args["sortOrder"].(string)
+
+					if pageNumber < 1 {
+						return nil, invalidArgument("pageNumber", "integer", "pageNumber must be a positive integer")
+					}
+					if pageSize < 1 || pageSize > limits.MaxPageSize {
+						return nil, invalidArgument("pageSize", "lte", "pageSize must be between 1 and "+strconv.Itoa(limits.MaxPageSize))
+					}
+					if userID < 0 {
+						return nil, invalidArgument("userId", "integer", "userId must be a positive integer")
+					}
+					if includeDeleted && !limits.IncludeDeleted {
+						return nil, invalidArgument("includeDeleted", "disabled", "includeDeleted is disabled on this server")
+					}
+
+					var transactions []models.Transaction
+					sort := repository.TransactionSort{Field: sortBy, Desc: sortOrder == "desc"}
+					total, err := repo.WithContext(p.Context).GetSortedTransactionsWithFilters(&transactions, pageNumber, pageSize, status, userID, includeDeleted, sort)
+					if err != nil {
+						return nil, resolveError(err)
+					}
+
+					items := make([]*models.Transaction, len(transactions))
+					for i := range transactions {
+						items[i] = &transactions[i]
+					}
+					return map[string]interface{}{
+						"items":            items,
+						"pageNumber":       pageNumber,
+						"pageSize":         pageSize,
+						"totalRecordCount": total,
+					}, nil
+				},
+			},
+			"transaction": &graphql.Field{
+				Type:        transactionType,
+				Description: "A live transaction by id, or null when there is none.",
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := strconv.Atoi(p.Args["id"].(string))
+					if err != nil || id <= 0 {
+						return nil, invalidArgument("id", "integer", "id must be a positive integer")
+					}
+					transaction, err := repo.WithContext(p.Context).GetTransactionByID(id)
+					if errors.Is(err, repository.ErrTransactionNotFound) {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, resolveError(err)
+					}
+					return transaction, nil
+				},
+			},
+			"summary": &graphql.Field{
+				Type:        graphql.NewNonNull(summaryType),
+				Description: "Dashboard aggregates over all transactions, or one user's when userId is set.",
+				Args: graphql.FieldConfigArgument{
+					"userId": {Type: graphql.Int},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var (
+						summary repository.TransactionSummary
+						err     error
+					)
+					if userID, ok := p.Args["userId"].(int); ok {
+						summary, err = repo.WithContext(p.Context).GetUserTransactionSummary(userID)
+					} else {
+						summary, err = repo.WithContext(p.Context).GetTransactionSummary()
+					}
+					if err != nil {
+						return nil, resolveError(err)
+					}
+					return summary, nil
+				},
+			},
+			"userStats": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userStatsType))),
+				Description: "Stats per user, in the order of userIds.",
+				Args: graphql.FieldConfigArgument{
+					"userIds": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.Int)))},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					raw, _ := p.Args["userIds"].([]interface{})
+					if len(raw) > limits.MaxPageSize {
+						return nil, invalidArgument("userIds", "lte", "userIds must not contain more than "+strconv.Itoa(limits.MaxPageSize)+" users")
+					}
+					userIDs := make([]int, len(raw))
+					for i, id := range raw {
+						userIDs[i], _ = id.(int)
+					}
+					return loadersFrom(p.Context).userStats.LoadMany(userIDs), nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
@@ -0,0 +1,73 @@
+package grpcapi
+
+import (
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/models"
+	transactionv1 "gin-boilerplate/proto/transaction/v1"
+	"gin-boilerplate/repository"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"math"
+)
+
+var errInvalidMetadata = apperror.Internal("invalid_metadata", "Transaction metadata cannot be encoded")
+
+// toProto converts a stored transaction into its wire form.
+func toProto(t *models.Transaction) (*transactionv1.Transaction, error) {
+	out := &transactionv1.Transaction{
+		Id:          uint64(t.ID),
+		UserId:      int64(t.UserID),
+		Amount:      int64(t.Amount),
+		Status:      t.Status,
+		Description: t.Description,
+		Reference:   t.Reference,
+		Version:     int64(t.Version),
+		CreatedAt:   timestamppb.New(t.CreatedAt),
+		UpdatedAt:   timestamppb.New(t.UpdatedAt),
+	}
+	if t.Metadata != nil {
+		metadata, err := structpb.NewStruct(t.Metadata)
+		if err != nil {
+			return nil, errInvalidMetadata.Wrap(err)
+		}
+		out.Metadata = metadata
+	}
+	if t.DeletedAt.Valid {
+		out.DeletedAt = timestamppb.New(t.DeletedAt.Time)
+	}
+	return out, nil
+}
+
+// toProtoList converts a page of transactions.
+func toProtoList(transactions []models.Transaction) ([]*transactionv1.Transaction, error) {
+	out := make([]*transactionv1.Transaction, 0, len(transactions))
+	for i := range transactions {
+		t, err := toProto(&transactions[i])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// summaryToProto converts a dashboard summary.
+func summaryToProto(s repository.TransactionSummary) *transactionv1.GetSummaryResponse {
+	return &transactionv1.GetSummaryResponse{
+		TotalTransactionsToday:    int64(s.TotalTransactionsToday),
+		AverageTransactionPerUser: s.AverageTransactionPerUser,
+		TotalTransactions:         int64(s.TotalTransactions),
+		UniqueUsers:               int64(s.UniqueUsers),
+		TotalPendingTransactions:  int64(s.TotalPendingTransactions),
+		TotalSuccessTransactions:  int64(s.TotalSuccessTransactions),
+		TotalFailedTransactions:   int64(s.TotalFailedTransactions),
+	}
+}
+
+// toInt narrows a wire integer, reporting whether it fits in an int.
+func toInt(v int64) (int, bool) {
+	if v > math.MaxInt || v < math.MinInt {
+		return 0, false
+	}
+	return int(v), true
+}
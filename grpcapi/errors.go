@@ -0,0 +1,99 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/infra/logger"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain identifies this service in ErrorInfo details.
+const errorDomain = "transaction.v1"
+
+// statusCodes maps error kinds to gRPC codes the way helpers.Problem maps
+// them to HTTP statuses.
+var statusCodes = map[apperror.Kind]codes.Code{
+	apperror.KindValidation:           codes.InvalidArgument,
+	apperror.KindNotFound:             codes.NotFound,
+	apperror.KindConflict:             codes.AlreadyExists,
+	apperror.KindPreconditionFailed:   codes.Aborted,
+	apperror.KindPreconditionRequired: codes.FailedPrecondition,
+	apperror.KindUnavailable:          codes.Unavailable,
+	apperror.KindInternal:             codes.Internal,
+}
+
+// statusError turns err into a gRPC status. The error code goes into an
+// ErrorInfo reason and rejected fields into BadRequest violations, so
+// clients get the same details as the REST problem body.
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	appErr := apperror.From(err)
+	code, ok := statusCodes[appErr.Kind]
+	if !ok {
+		code = codes.Internal
+	}
+
+	message := appErr.Message
+	if appErr.Kind == apperror.KindInternal {
+		logger.Errorf("grpc: %v", err)
+		message = "Internal server error"
+	}
+
+	st := status.New(code, message)
+	if withInfo, err := st.WithDetails(&errdetails.ErrorInfo{Reason: appErr.Code, Domain: errorDomain}); err == nil {
+		st = withInfo
+	}
+	if len(appErr.Fields) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, field := range appErr.Fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field.Field,
+				Description: field.Message,
+			})
+		}
+		if withFields, err := st.WithDetails(badRequest); err == nil {
+			st = withFields
+		}
+	}
+	return st.Err()
+}
+
+// unaryErrors converts handler errors with statusError and turns panics into
+// Internal errors instead of killing the process.
+func unaryErrors(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("grpc: panic in %s: %v", info.FullMethod, r)
+			resp, err = nil, status.Error(codes.Internal, "Internal server error")
+		}
+	}()
+	resp, err = handler(ctx, req)
+	return resp, statusError(err)
+}
+
+// streamErrors is unaryErrors for streaming calls.
+func streamErrors(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("grpc: panic in %s: %v", info.FullMethod, r)
+			err = status.Error(codes.Internal, "Internal server error")
+		}
+	}()
+	return statusError(handler(srv, stream))
+}
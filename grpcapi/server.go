@@ -0,0 +1,357 @@
+// Package grpcapi serves the transaction API over gRPC for internal services
+// that want typed clients. It runs on its own port next to the REST API and
+// uses the same repository and validation rules, so both APIs behave alike.
+package grpcapi
+
+import (
+	"context"
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/controllers"
+	"gin-boilerplate/models"
+	transactionv1 "gin-boilerplate/proto/transaction/v1"
+	"gin-boilerplate/repository"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxPageSize is the largest page_size ListTransactions accepts when
+// Server.MaxPageSize is not set.
+const DefaultMaxPageSize = 100
+
+var (
+	errInvalidID          = apperror.Validation("invalid_id", "Invalid transaction ID", apperror.FieldError{Field: "id", Code: "integer", Message: "id must be a positive integer"})
+	errInvalidStatus      = apperror.Validation("invalid_status", "Invalid status value. Allowed values: success, pending, failed", apperror.FieldError{Field: "status", Code: "oneof", Message: "status must be one of success, pending, failed"})
+	errInvalidVersion     = apperror.Validation("invalid_version", "Invalid version", apperror.FieldError{Field: "version", Code: "integer", Message: "version must be a positive integer"})
+	errVersionRequired    = apperror.PreconditionRequired("version_required", "version required")
+	errInvalidRequestBody = apperror.Validation("invalid_body", "Invalid request body")
+)
+
+// validStatuses are the statuses UpdateTransactionStatus accepts.
+var validStatuses = map[string]bool{
+	"success": true,
+	"pending": true,
+	"failed":  true,
+}
+
+// Server implements transactionv1.TransactionServiceServer.
+type Server struct {
+	transactionv1.UnimplementedTransactionServiceServer
+
+	Repo        repository.TransactionRepository
+	CreateRules controllers.CreateTransactionRules
+	// ExportMaxRows caps the rows sent by StreamTransactions.
+	ExportMaxRows int
+	// RequireVersion rejects updates and deletes without a version, like
+	// REQUIRE_IF_MATCH does for the REST API.
+	RequireVersion bool
+	// MaxPageSize caps page_size of ListTransactions.
+	MaxPageSize int
+	// IncludeDeletedEnabled allows include_deleted on list and stream.
+	IncludeDeletedEnabled bool
+	// Reflection registers the reflection service for tools like grpcurl.
+	// It describes every method to anyone who can connect.
+	Reflection bool
+}
+
+// NewServer returns a gRPC server with srv registered, error mapping and
+// panic recovery installed, and reflection enabled if srv asks for it.
+func NewServer(srv *Server, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryErrors),
+		grpc.ChainStreamInterceptor(streamErrors),
+	}, opts...)
+	server := grpc.NewServer(opts...)
+	transactionv1.RegisterTransactionServiceServer(server, srv)
+	if srv.Reflection {
+		reflection.Register(server)
+	}
+	return server
+}
+
+// repo binds the repository to the call, so its queries are cancelled with it.
+func (s *Server) repo(ctx context.Context) repository.TransactionRepository {
+	return s.Repo.WithContext(ctx)
+}
+
+func (s *Server) createRules() controllers.CreateTransactionRules {
+	rules := s.CreateRules
+	if len(rules.InitialStatuses) == 0 {
+		rules.InitialStatuses = controllers.DefaultCreateTransactionRules.InitialStatuses
+	}
+	return rules
+}
+
+func (s *Server) exportMaxRows() int {
+	if s.ExportMaxRows > 0 {
+		return s.ExportMaxRows
+	}
+	return controllers.DefaultExportMaxRows
+}
+
+func (s *Server) maxPageSize() int {
+	if s.MaxPageSize > 0 {
+		return s.MaxPageSize
+	}
+	return DefaultMaxPageSize
+}
+
+// language reads the message language from accept-language metadata.
+func language(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return controllers.Language(strings.Join(md.Get("accept-language"), ","))
+}
+
+// transactionID validates a transaction ID from a request.
+func transactionID(id uint64) (int, error) {
+	if id == 0 || id > math.MaxInt {
+		return 0, errInvalidID
+	}
+	return int(id), nil
+}
+
+// version validates the expected version of a conditional write; 0 means the
+// write is unconditional.
+func (s *Server) version(v int64) (int, error) {
+	if v == 0 && s.RequireVersion {
+		return 0, errVersionRequired
+	}
+	version, ok := toInt(v)
+	if !ok || version < 0 {
+		return 0, errInvalidVersion
+	}
+	return version, nil
+}
+
+// filters validates the user_id filter shared by list, stream and summary.
+func filters(userID int64) (int, *apperror.FieldError) {
+	id, ok := toInt(userID)
+	if !ok || id < 0 {
+		return 0, &apperror.FieldError{Field: "user_id", Code: "integer", Message: "user_id must be a positive integer"}
+	}
+	return id, nil
+}
+
+// statusFilter validates the status filter shared by list and stream, where
+// empty means any status.
+func statusFilter(status string) *apperror.FieldError {
+	if status != "" && !validStatuses[status] {
+		return &apperror.FieldError{Field: "status", Code: "oneof", Message: "status must be one of success, pending, failed"}
+	}
+	return nil
+}
+
+// includeDeleted refuses include_deleted on list and stream unless enabled.
+func (s *Server) includeDeleted(includeDeleted bool) *apperror.FieldError {
+	if includeDeleted && !s.IncludeDeletedEnabled {
+		return &apperror.FieldError{Field: "include_deleted", Code: "disabled", Message: "include_deleted is disabled on this server"}
+	}
+	return nil
+}
+
+func (s *Server) CreateTransaction(ctx context.Context, req *transactionv1.CreateTransactionRequest) (*transactionv1.CreateTransactionResponse, error) {
+	userID, userOK := toInt(req.GetUserId())
+	amount, amountOK := toInt(req.GetAmount())
+	if !userOK || !amountOK {
+		return nil, errInvalidRequestBody
+	}
+
+	body := controllers.CreateTransactionRequest{
+		UserID:      userID,
+		Amount:      amount,
+		Status:      req.GetStatus(),
+		Description: req.GetDescription(),
+		Reference:   req.GetReference(),
+	}
+	if req.GetMetadata() != nil {
+		body.Metadata = models.JSONMap(req.GetMetadata().AsMap())
+	}
+
+	rules := s.createRules()
+	if err := body.Check(rules, language(ctx)); err != nil {
+		return nil, err
+	}
+
+	transaction := body.ToModel(rules)
+	if err := s.repo(ctx).Save(transaction); err != nil {
+		return nil, err
+	}
+
+	out, err := toProto(transaction)
+	if err != nil {
+		return nil, err
+	}
+	return &transactionv1.CreateTransactionResponse{Transaction: out}, nil
+}
+
+func (s *Server) GetTransaction(ctx context.Context, req *transactionv1.GetTransactionRequest) (*transactionv1.GetTransactionResponse, error) {
+	id, err := transactionID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := s.repo(ctx).GetTransactionByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := toProto(transaction)
+	if err != nil {
+		return nil, err
+	}
+	return &transactionv1.GetTransactionResponse{Transaction: out}, nil
+}
+
+func (s *Server) ListTransactions(ctx context.Context, req *transactionv1.ListTransactionsRequest) (*transactionv1.ListTransactionsResponse, error) {
+	var fieldErrs []apperror.FieldError
+
+	pageNumber := int(req.GetPageNumber())
+	if pageNumber == 0 {
+		pageNumber = 1
+	} else if pageNumber < 0 {
+		fieldErrs = append(fieldErrs, apperror.FieldError{Field: "page_number", Code: "integer", Message: "page_number must be a positive integer"})
+	}
+	pageSize := int(req.GetPageSize())
+	if pageSize == 0 {
+		pageSize = 10
+	} else if pageSize < 0 {
+		fieldErrs = append(fieldErrs, apperror.FieldError{Field: "page_size", Code: "integer", Message: "page_size must be a positive integer"})
+	} else if pageSize > s.maxPageSize() {
+		fieldErrs = append(fieldErrs, apperror.FieldError{Field: "page_size", Code: "lte", Message: "page_size must be between 1 and " + strconv.Itoa(s.maxPageSize())})
+	}
+	if fieldErr := statusFilter(req.GetStatus()); fieldErr != nil {
+		fieldErrs = append(fieldErrs, *fieldErr)
+	}
+	if fieldErr := s.includeDeleted(req.GetIncludeDeleted()); fieldErr != nil {
+		fieldErrs = append(fieldErrs, *fieldErr)
+	}
+	userID, fieldErr := filters(req.GetUserId())
+	if fieldErr != nil {
+		fieldErrs = append(fieldErrs, *fieldErr)
+	}
+	if len(fieldErrs) > 0 {
+		return nil, apperror.Validation("invalid_query", "Invalid query parameters", fieldErrs...)
+	}
+
+	var transactions []models.Transaction
+	total, err := s.repo(ctx).GetTransactionsWithFilters(&transactions, pageNumber, pageSize, req.GetStatus(), userID, req.GetIncludeDeleted())
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := toProtoList(transactions)
+	if err != nil {
+		return nil, err
+	}
+	return &transactionv1.ListTransactionsResponse{
+		Transactions:     out,
+		PageNumber:       int32(pageNumber),
+		PageSize:         int32(pageSize),
+		TotalRecordCount: total,
+	}, nil
+}
+
+// StreamTransactions sends every matching transaction as its own message,
+// capped at ExportMaxRows, without loading them all into memory.
+func (s *Server) StreamTransactions(req *transactionv1.StreamTransactionsRequest, stream transactionv1.TransactionService_StreamTransactionsServer) error {
+	var fieldErrs []apperror.FieldError
+
+	if fieldErr := statusFilter(req.GetStatus()); fieldErr != nil {
+		fieldErrs = append(fieldErrs, *fieldErr)
+	}
+	if fieldErr := s.includeDeleted(req.GetIncludeDeleted()); fieldErr != nil {
+		fieldErrs = append(fieldErrs, *fieldErr)
+	}
+	userID, fieldErr := filters(req.GetUserId())
+	if fieldErr != nil {
+		fieldErrs = append(fieldErrs, *fieldErr)
+	}
+	limit := int(req.GetLimit())
+	if limit < 0 {
+		fieldErrs = append(fieldErrs, apperror.FieldError{Field: "limit", Code: "integer", Message: "limit must be a positive integer"})
+	}
+	if len(fieldErrs) > 0 {
+		return apperror.Validation("invalid_query", "Invalid query parameters", fieldErrs...)
+	}
+	if limit == 0 || limit > s.exportMaxRows() {
+		limit = s.exportMaxRows()
+	}
+
+	ctx := stream.Context()
+	return s.repo(stream.Context()).StreamTransactionsWithFilters(req.GetStatus(), userID, req.GetIncludeDeleted(), limit, func(t *models.Transaction) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		out, err := toProto(t)
+		if err != nil {
+			return err
+		}
+		return stream.Send(&transactionv1.StreamTransactionsResponse{Transaction: out})
+	})
+}
+
+func (s *Server) UpdateTransactionStatus(ctx context.Context, req *transactionv1.UpdateTransactionStatusRequest) (*transactionv1.UpdateTransactionStatusResponse, error) {
+	id, err := transactionID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if !validStatuses[req.GetStatus()] {
+		return nil, errInvalidStatus
+	}
+	version, err := s.version(req.GetVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := s.repo(ctx).UpdateTransactionStatus(id, req.GetStatus(), version)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := toProto(transaction)
+	if err != nil {
+		return nil, err
+	}
+	return &transactionv1.UpdateTransactionStatusResponse{Transaction: out}, nil
+}
+
+func (s *Server) DeleteTransaction(ctx context.Context, req *transactionv1.DeleteTransactionRequest) (*transactionv1.DeleteTransactionResponse, error) {
+	id, err := transactionID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.version(req.GetVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo(ctx).DeleteTransactionByID(id, version); err != nil {
+		return nil, err
+	}
+	return &transactionv1.DeleteTransactionResponse{}, nil
+}
+
+// GetSummary returns the dashboard summary, for one user when user_id is set.
+func (s *Server) GetSummary(ctx context.Context, req *transactionv1.GetSummaryRequest) (*transactionv1.GetSummaryResponse, error) {
+	userID, fieldErr := filters(req.GetUserId())
+	if fieldErr != nil {
+		return nil, apperror.Validation("invalid_query", "Invalid query parameters", *fieldErr)
+	}
+
+	var (
+		summary repository.TransactionSummary
+		err     error
+	)
+	if userID > 0 {
+		summary, err = s.repo(ctx).GetUserTransactionSummary(userID)
+	} else {
+		summary, err = s.repo(ctx).GetTransactionSummary()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return summaryToProto(summary), nil
+}
@@ -0,0 +1,226 @@
+package grpcapi
+
+import (
+	"context"
+	"gin-boilerplate/models"
+	transactionv1 "gin-boilerplate/proto/transaction/v1"
+	"gin-boilerplate/repository"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakeRepo keeps transactions in memory. Methods the server does not call
+// are left to the embedded nil interface.
+type fakeRepo struct {
+	repository.TransactionRepository
+	transactions []*models.Transaction
+}
+
+func (r *fakeRepo) WithContext(ctx context.Context) repository.TransactionRepository {
+	return r
+}
+
+func (r *fakeRepo) Save(t *models.Transaction) error {
+	t.ID = uint(len(r.transactions) + 1)
+	t.Version = 1
+	t.CreatedAt = time.Now()
+	t.UpdatedAt = t.CreatedAt
+	r.transactions = append(r.transactions, t)
+	return nil
+}
+
+func (r *fakeRepo) GetTransactionByID(id int) (*models.Transaction, error) {
+	if id > len(r.transactions) {
+		return nil, repository.ErrTransactionNotFound
+	}
+	return r.transactions[id-1], nil
+}
+
+func (r *fakeRepo) UpdateTransactionStatus(id int, status string, version int) (*models.Transaction, error) {
+	t, err := r.GetTransactionByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && version != t.Version {
+		return nil, repository.ErrVersionConflict
+	}
+	t.Status = status
+	t.Version++
+	return t, nil
+}
+
+func (r *fakeRepo) StreamTransactionsWithFilters(status string, userID int, includeDeleted bool, limit int, fn func(*models.Transaction) error) error {
+	sent := 0
+	for _, t := range r.transactions {
+		if sent == limit {
+			break
+		}
+		if (status != "" && t.Status != status) || (userID != 0 && t.UserID != userID) {
+			continue
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+		sent++
+	}
+	return nil
+}
+
+func dial(t *testing.T, srv *Server) transactionv1.TransactionServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	server := NewServer(srv)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return transactionv1.NewTransactionServiceClient(conn)
+}
+
+func TestCreateAndGetTransaction(t *testing.T) {
+	client := dial(t, &Server{Repo: &fakeRepo{}})
+	ctx := context.Background()
+
+	meta, err := structpb.NewStruct(map[string]interface{}{"channel": "mobile"})
+	require.NoError(t, err)
+
+	created, err := client.CreateTransaction(ctx, &transactionv1.CreateTransactionRequest{UserId: 7, Amount: 1500, Metadata: meta})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), created.Transaction.Id)
+	assert.Equal(t, "pending", created.Transaction.Status)
+	assert.Equal(t, int64(1), created.Transaction.Version)
+
+	got, err := client.GetTransaction(ctx, &transactionv1.GetTransactionRequest{Id: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1500), got.Transaction.Amount)
+	assert.Equal(t, "mobile", got.Transaction.Metadata.AsMap()["channel"])
+	assert.Nil(t, got.Transaction.DeletedAt)
+
+	_, err = client.GetTransaction(ctx, &transactionv1.GetTransactionRequest{Id: 9})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestCreateTransaction_ValidationDetails(t *testing.T) {
+	client := dial(t, &Server{Repo: &fakeRepo{}})
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "accept-language", "id")
+
+	_, err := client.CreateTransaction(ctx, &transactionv1.CreateTransactionRequest{UserId: 7, Status: "success"})
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+
+	var reason string
+	var fields []string
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			reason = d.Reason
+		case *errdetails.BadRequest:
+			for _, violation := range d.FieldViolations {
+				fields = append(fields, violation.Field)
+				assert.Contains(t, violation.Description, "wajib")
+			}
+		}
+	}
+	assert.Equal(t, "invalid_body", reason)
+	assert.Equal(t, []string{"amount"}, fields)
+}
+
+func TestUpdateTransactionStatus(t *testing.T) {
+	repo := &fakeRepo{}
+	client := dial(t, &Server{Repo: repo, RequireVersion: true})
+	ctx := context.Background()
+	require.NoError(t, repo.Save(&models.Transaction{UserID: 1, Amount: 10, Status: "pending"}))
+
+	_, err := client.UpdateTransactionStatus(ctx, &transactionv1.UpdateTransactionStatusRequest{Id: 1, Status: "done", Version: 1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.UpdateTransactionStatus(ctx, &transactionv1.UpdateTransactionStatusRequest{Id: 1, Status: "success"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = client.UpdateTransactionStatus(ctx, &transactionv1.UpdateTransactionStatusRequest{Id: 1, Status: "success", Version: 3})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	updated, err := client.UpdateTransactionStatus(ctx, &transactionv1.UpdateTransactionStatusRequest{Id: 1, Status: "success", Version: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "success", updated.Transaction.Status)
+	assert.Equal(t, int64(2), updated.Transaction.Version)
+}
+
+func TestStreamTransactions_FiltersAndLimit(t *testing.T) {
+	repo := &fakeRepo{}
+	client := dial(t, &Server{Repo: repo, ExportMaxRows: 2})
+	for i := 0; i < 4; i++ {
+		require.NoError(t, repo.Save(&models.Transaction{UserID: 5, Amount: 10 + i, Status: "pending"}))
+	}
+	require.NoError(t, repo.Save(&models.Transaction{UserID: 6, Amount: 99, Status: "pending"}))
+
+	stream, err := client.StreamTransactions(context.Background(), &transactionv1.StreamTransactionsRequest{UserId: 5, Limit: 10})
+	require.NoError(t, err)
+
+	var amounts []int64
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		amounts = append(amounts, msg.Transaction.Amount)
+	}
+	assert.Equal(t, []int64{10, 11}, amounts)
+}
+
+func TestListAndStream_RejectInvalidFilters(t *testing.T) {
+	client := dial(t, &Server{Repo: &fakeRepo{}, MaxPageSize: 50})
+	ctx := context.Background()
+
+	_, err := client.ListTransactions(ctx, &transactionv1.ListTransactionsRequest{PageSize: 51, Status: "done", IncludeDeleted: true})
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	var fields []string
+	for _, detail := range st.Details() {
+		if d, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range d.FieldViolations {
+				fields = append(fields, violation.Field+": "+violation.Description)
+			}
+		}
+	}
+	assert.Equal(t, []string{
+		"page_size: page_size must be between 1 and 50",
+		"status: status must be one of success, pending, failed",
+		"include_deleted: include_deleted is disabled on this server",
+	}, fields)
+
+	for _, req := range []*transactionv1.StreamTransactionsRequest{{Status: "done"}, {IncludeDeleted: true}} {
+		stream, err := client.StreamTransactions(ctx, req)
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
+
+func TestNewServer_ReflectionIsOptIn(t *testing.T) {
+	_, ok := NewServer(&Server{}).GetServiceInfo()["grpc.reflection.v1alpha.ServerReflection"]
+	assert.False(t, ok)
+
+	_, ok = NewServer(&Server{Reflection: true}).GetServiceInfo()["grpc.reflection.v1alpha.ServerReflection"]
+	assert.True(t, ok)
+}
@@ -15,11 +15,3 @@ func Success(ctx *gin.Context, message string, data interface{}) {
 		Data:    data,
 	})
 }
-
-func Error(ctx *gin.Context, message string, data interface{}) {
-	ctx.JSON(400, APIResponse{
-		Status:  "error",
-		Message: message,
-		Data:    data,
-	})
-}
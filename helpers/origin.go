@@ -0,0 +1,25 @@
+package helpers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OriginAllowed reports whether origin, the value of an Origin header, is
+// one of allowed, ignoring case. "*" in allowed allows every origin.
+func OriginAllowed(allowed []string, origin string) bool {
+	for _, candidate := range allowed {
+		if candidate == "*" || strings.EqualFold(strings.TrimRight(candidate, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// SameOrigin reports whether the Origin header of r names the host r was
+// sent to.
+func SameOrigin(r *http.Request) bool {
+	parsed, err := url.Parse(r.Header.Get("Origin"))
+	return err == nil && strings.EqualFold(parsed.Host, r.Host)
+}
@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/infra/logger"
+	"gin-boilerplate/infra/requestid"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// ProblemDetails is an RFC 7807 error body extended with a stable error code,
+// field level validation errors and the request ID.
+type ProblemDetails struct {
+	Type      string                `json:"type"`
+	Title     string                `json:"title"`
+	Status    int                   `json:"status"`
+	Detail    string                `json:"detail,omitempty"`
+	Instance  string                `json:"instance,omitempty"`
+	Code      string                `json:"code"`
+	Errors    []apperror.FieldError `json:"errors,omitempty"`
+	RequestID string                `json:"request_id,omitempty"`
+}
+
+var problemStatus = map[apperror.Kind]int{
+	apperror.KindValidation:           http.StatusBadRequest,
+	apperror.KindNotFound:             http.StatusNotFound,
+	apperror.KindConflict:             http.StatusConflict,
+	apperror.KindPreconditionFailed:   http.StatusPreconditionFailed,
+	apperror.KindPreconditionRequired: http.StatusPreconditionRequired,
+	apperror.KindUnavailable:          http.StatusServiceUnavailable,
+	apperror.KindInternal:             http.StatusInternalServerError,
+}
+
+// ProblemStatus returns the HTTP status code for an error kind.
+func ProblemStatus(kind apperror.Kind) int {
+	if status, ok := problemStatus[kind]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Problem writes err as an application/problem+json response and aborts the
+// request. Untyped errors are reported as internal without leaking details.
+func Problem(ctx *gin.Context, err error) {
+	appErr := apperror.From(err)
+	status := ProblemStatus(appErr.Kind)
+
+	var instance string
+	if ctx.Request != nil {
+		instance = ctx.Request.URL.Path
+	}
+	if status >= http.StatusInternalServerError {
+		if ctx.Request != nil {
+			logger.FromContext(ctx.Request.Context()).Errorf("%s: %v", instance, err)
+		} else {
+			logger.Errorf("%s: %v", instance, err)
+		}
+	}
+
+	ctx.Header("Content-Type", ProblemContentType)
+	ctx.AbortWithStatusJSON(status, ProblemDetails{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    appErr.Message,
+		Instance:  instance,
+		Code:      appErr.Code,
+		Errors:    appErr.Fields,
+		RequestID: requestID(ctx),
+	})
+}
+
+// requestID returns the ID the RequestID middleware put in the request
+// context.
+func requestID(ctx *gin.Context) string {
+	if ctx.Request == nil {
+		return ""
+	}
+	return requestid.FromContext(ctx.Request.Context())
+}
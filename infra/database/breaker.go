@@ -0,0 +1,234 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"gin-boilerplate/infra/logger"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Breaker fails fast while the database is down. It opens after Threshold
+// unavailable errors in a row; once Cooldown has passed a single statement
+// is let through as a probe, whose success closes it again.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+	// Name tells the pools apart in the log, e.g. "master".
+	Name string
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+	now      func() time.Time
+}
+
+// NewBreaker returns a closed breaker. A threshold of 0 never opens.
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{Name: name, Threshold: threshold, Cooldown: cooldown, now: time.Now}
+}
+
+// Allow reports whether a statement may run. Every allowed statement must be
+// followed by Success, Failure or Cancel.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.Threshold <= 0 || b.failures < b.Threshold:
+		return true
+	case b.probing || b.now().Sub(b.openedAt) < b.Cooldown:
+		return false
+	default:
+		b.probing = true
+		return true
+	}
+}
+
+// Success records a statement that reached the database.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.Threshold > 0 && b.failures >= b.Threshold {
+		logger.Infof("database circuit breaker of the %s closed", b.Name)
+	}
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure records a statement that found the database unavailable.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.Threshold > 0 && b.failures >= b.Threshold {
+		if b.failures == b.Threshold {
+			logger.Warnf("database circuit breaker of the %s open for %s after %d failures", b.Name, b.Cooldown, b.failures)
+		}
+		b.openedAt = b.now()
+	}
+}
+
+// Cancel records a statement that tells nothing about the database, such as
+// one whose request went away.
+func (b *Breaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// Open reports whether statements are currently rejected.
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.Threshold > 0 && b.failures >= b.Threshold
+}
+
+// record calls Cancel, Failure or Success for the outcome err of a statement
+// run with ctx.
+func (b *Breaker) record(ctx context.Context, err error) {
+	switch {
+	case ctx.Err() != nil:
+		b.Cancel()
+	case IsUnavailable(err):
+		b.Failure()
+	default:
+		b.Success()
+	}
+}
+
+// guardedPool runs every statement and BEGIN of one connection pool through
+// the pool's own breaker, so a down replica never rejects writes to the
+// master and transactions fail fast too.
+type guardedPool struct {
+	db      *sql.DB
+	breaker *Breaker
+}
+
+func newGuardedPool(db *sql.DB, breaker *Breaker) *guardedPool {
+	return &guardedPool{db: db, breaker: breaker}
+}
+
+// GetDBConn lets gorm's DB() return the pool.
+func (p *guardedPool) GetDBConn() (*sql.DB, error) {
+	return p.db, nil
+}
+
+func (p *guardedPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	if !p.breaker.Allow() {
+		return nil, ErrCircuitOpen
+	}
+	stmt, err := p.db.PrepareContext(ctx, query)
+	p.breaker.record(ctx, err)
+	return stmt, err
+}
+
+func (p *guardedPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if !p.breaker.Allow() {
+		return nil, ErrCircuitOpen
+	}
+	result, err := p.db.ExecContext(ctx, query, args...)
+	p.breaker.record(ctx, err)
+	return result, err
+}
+
+func (p *guardedPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if !p.breaker.Allow() {
+		return nil, ErrCircuitOpen
+	}
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	p.breaker.record(ctx, err)
+	return rows, err
+}
+
+func (p *guardedPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if !p.breaker.Allow() {
+		// A *sql.Row can only carry an error that the pool returned, so
+		// hand it a context that is already done with ErrCircuitOpen.
+		return p.db.QueryRowContext(rejectedContext{ctx}, query, args...)
+	}
+	row := p.db.QueryRowContext(ctx, query, args...)
+	p.breaker.record(ctx, row.Err())
+	return row
+}
+
+// BeginTx makes gorm's Begin, and so Transaction, go through the breaker.
+func (p *guardedPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	if !p.breaker.Allow() {
+		return nil, ErrCircuitOpen
+	}
+	tx, err := p.db.BeginTx(ctx, opts)
+	p.breaker.record(ctx, err)
+	if err != nil {
+		return nil, err
+	}
+	return &guardedTx{tx: tx, breaker: p.breaker}, nil
+}
+
+// guardedTx records the statements of a transaction in the breaker of its
+// pool. They are never rejected: the transaction already has a connection.
+type guardedTx struct {
+	tx      *sql.Tx
+	breaker *Breaker
+}
+
+func (t *guardedTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	stmt, err := t.tx.PrepareContext(ctx, query)
+	t.breaker.record(ctx, err)
+	return stmt, err
+}
+
+func (t *guardedTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := t.tx.ExecContext(ctx, query, args...)
+	t.breaker.record(ctx, err)
+	return result, err
+}
+
+func (t *guardedTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := t.tx.QueryContext(ctx, query, args...)
+	t.breaker.record(ctx, err)
+	return rows, err
+}
+
+func (t *guardedTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	row := t.tx.QueryRowContext(ctx, query, args...)
+	t.breaker.record(ctx, row.Err())
+	return row
+}
+
+func (t *guardedTx) Commit() error {
+	err := t.tx.Commit()
+	if IsUnavailable(err) {
+		t.breaker.Failure()
+	}
+	return err
+}
+
+func (t *guardedTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+var closed = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// rejectedContext is done, with ErrCircuitOpen as its error.
+type rejectedContext struct {
+	context.Context
+}
+
+func (rejectedContext) Done() <-chan struct{} {
+	return closed
+}
+
+func (rejectedContext) Err() error {
+	return ErrCircuitOpen
+}
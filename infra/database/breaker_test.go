@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// unreachable is a pool whose connections are refused.
+func unreachable(t *testing.T) *sql.DB {
+	pool, err := sql.Open("pgx", "host=127.0.0.1 port=1 user=app dbname=app sslmode=disable connect_timeout=1")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = pool.Close() })
+	return pool
+}
+
+func openGuarded(t *testing.T, breaker *Breaker, resilience Resilience) *gorm.DB {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: newGuardedPool(unreachable(t), breaker)}), &gorm.Config{DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(resilience))
+	return db
+}
+
+func TestGuardedPool_FailsFastWhileOpen(t *testing.T) {
+	breaker := NewBreaker("master", 2, time.Minute)
+	db := openGuarded(t, breaker, Resilience{})
+
+	for i := 0; i < 2; i++ {
+		err := db.Find(&[]subscription{}).Error
+		assert.True(t, IsUnavailable(err), "got %v", err)
+	}
+	err := db.Find(&[]subscription{}).Error
+
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.True(t, breaker.Open())
+}
+
+func TestGuardedPool_GuardsTransactions(t *testing.T) {
+	breaker := NewBreaker("master", 2, time.Minute)
+	db := openGuarded(t, breaker, Resilience{})
+	write := func() error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			return tx.Create(&subscription{Secret: "whsec-123"}).Error
+		})
+	}
+
+	for i := 0; i < 2; i++ {
+		err := write()
+		assert.True(t, IsUnavailable(err), "got %v", err)
+	}
+	assert.True(t, breaker.Open(), "failed BEGINs open the breaker")
+	assert.ErrorIs(t, write(), ErrCircuitOpen)
+}
+
+func TestGuardedPool_RetriesGoThroughTheBreaker(t *testing.T) {
+	breaker := NewBreaker("master", 2, time.Minute)
+	db := openGuarded(t, breaker, Resilience{ReadRetries: 5, RetryBackoff: time.Millisecond})
+
+	err := db.Find(&[]subscription{}).Error
+
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+}
+
+func TestGuardedPool_QueryRowWhileOpen(t *testing.T) {
+	breaker := NewBreaker("master", 1, time.Minute)
+	breaker.Failure()
+	pool := newGuardedPool(unreachable(t), breaker)
+
+	row := pool.QueryRowContext(context.Background(), "SELECT 1")
+
+	assert.ErrorIs(t, row.Err(), ErrCircuitOpen)
+}
+
+func TestBreaker_ProbesAfterCooldown(t *testing.T) {
+	now := time.Now()
+	breaker := NewBreaker("master", 1, time.Second)
+	breaker.now = func() time.Time { return now }
+
+	require.True(t, breaker.Allow())
+	breaker.Failure()
+	assert.False(t, breaker.Allow())
+
+	now = now.Add(time.Second)
+	assert.True(t, breaker.Allow(), "probe")
+	assert.False(t, breaker.Allow(), "second statement during the probe")
+
+	breaker.Failure()
+	assert.False(t, breaker.Allow(), "failed probe restarts the cooldown")
+
+	now = now.Add(time.Second)
+	require.True(t, breaker.Allow())
+	breaker.Success()
+	assert.False(t, breaker.Open())
+	assert.True(t, breaker.Allow())
+}
+
+func TestBreaker_CancelledProbeLetsAnotherThrough(t *testing.T) {
+	now := time.Now()
+	breaker := NewBreaker("master", 1, time.Second)
+	breaker.now = func() time.Time { return now }
+	breaker.Failure()
+
+	now = now.Add(time.Second)
+	require.True(t, breaker.Allow())
+	breaker.Cancel()
+
+	assert.True(t, breaker.Allow())
+}
+
+func TestBreaker_ZeroThresholdNeverOpens(t *testing.T) {
+	breaker := NewBreaker("master", 0, time.Second)
+	for i := 0; i < 10; i++ {
+		breaker.Failure()
+	}
+
+	assert.False(t, breaker.Open())
+	assert.True(t, breaker.Allow())
+}
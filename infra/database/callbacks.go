@@ -0,0 +1,51 @@
+package database
+
+import "gorm.io/gorm"
+
+// Operations are the gorm operations plugins hook into, named after their
+// processor and main callback (gorm:create, gorm:query, ...).
+var Operations = []string{"create", "query", "update", "delete", "row", "raw"}
+
+// RegisterAround registers before and after around the main callback of
+// every operation, as "<name>:before_<operation>" and
+// "<name>:after_<operation>". Either may be nil. After query runs before
+// gorm:preload, so it still sees the statement of the main query.
+func RegisterAround(db *gorm.DB, name string, before, after func(db *gorm.DB, operation string)) error {
+	callbacks := db.Callback()
+	for _, operation := range Operations {
+		operation := operation
+		// gorm does not export the processor type, so it can only be picked
+		// by assignment.
+		processor := callbacks.Create()
+		switch operation {
+		case "query":
+			processor = callbacks.Query()
+		case "update":
+			processor = callbacks.Update()
+		case "delete":
+			processor = callbacks.Delete()
+		case "row":
+			processor = callbacks.Row()
+		case "raw":
+			processor = callbacks.Raw()
+		}
+		main := "gorm:" + operation
+
+		if before != nil {
+			err := processor.Before(main).Register(name+":before_"+operation, func(db *gorm.DB) { before(db, operation) })
+			if err != nil {
+				return err
+			}
+		}
+		if after != nil {
+			callback := processor.After(main)
+			if operation == "query" {
+				callback = callback.Before("gorm:preload")
+			}
+			if err := callback.Register(name+":after_"+operation, func(db *gorm.DB) { after(db, operation) }); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -1,51 +1,197 @@
 package database
 
 import (
-	"github.com/spf13/viper"
+	"database/sql"
+	"fmt"
+	"gin-boilerplate/infra/logger"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+	gormlogger "gorm.io/gorm/logger"
 	"gorm.io/plugin/dbresolver"
-	"log"
+	"time"
 )
 
 var (
-	DB  *gorm.DB
-	err error
+	DB *gorm.DB
+	// replicas are the replica pools, kept so Close can close them; DB only
+	// exposes the master.
+	replicas []*sql.DB
 )
 
-// DbConnection create database connection
-func DbConnection(masterDSN, replicaDSN string) error {
-	var db = DB
+// maxConnectBackoff caps the wait between two connect attempts.
+const maxConnectBackoff = 30 * time.Second
+
+// pause waits between connect attempts; tests replace it.
+var pause = time.Sleep
+
+// Config is where and how DbConnection connects. Reads go to the replica
+// only when UseReplica is set.
+type Config struct {
+	MasterDSN  string
+	ReplicaDSN string
+	UseReplica bool
+	// LogMode logs every statement, without its values (see QueryLog).
+	LogMode bool
+
+	// Pool settings of the master and replica pools; zero keeps the
+	// database/sql default (unlimited open connections, 2 idle ones, no
+	// lifetime).
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// ConnectAttempts is how many times the master is tried at startup, with
+	// ConnectBackoff doubling between attempts.
+	ConnectAttempts int
+	ConnectBackoff  time.Duration
+
+	// The master and the replica each get a breaker that opens after
+	// BreakerThreshold unavailable errors in a row and rejects statements for
+	// BreakerCooldown; 0 disables them.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
 
-	logMode := viper.GetBool("DB_LOG_MODE")
-	debug := viper.GetBool("DEBUG")
+	Resilience Resilience
+}
 
-	loglevel := logger.Silent
-	if logMode {
-		loglevel = logger.Info
+// DbConnection create database connection
+func DbConnection(config Config) error {
+	master, err := connect(config)
+	if err != nil {
+		return err
 	}
+	configurePool(master, config)
 
-	db, err = gorm.Open(postgres.Open(masterDSN), &gorm.Config{
-		Logger: logger.Default.LogMode(loglevel),
+	// The gorm logger writes statements with their values, secrets included.
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: newGuardedPool(master, NewBreaker("master", config.BreakerThreshold, config.BreakerCooldown)),
+	}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
 	})
-	if !debug {
-		db.Use(dbresolver.Register(dbresolver.Config{
+	if err != nil {
+		_ = master.Close()
+		return err
+	}
+
+	if err := db.Use(config.Resilience); err != nil {
+		return err
+	}
+	if config.LogMode {
+		if err := db.Use(QueryLog{}); err != nil {
+			return err
+		}
+	}
+
+	if config.UseReplica {
+		replica, err := open(config.ReplicaDSN)
+		if err != nil {
+			return err
+		}
+		configurePool(replica, config)
+		replicas = append(replicas, replica)
+		err = db.Use(dbresolver.Register(dbresolver.Config{
 			Replicas: []gorm.Dialector{
-				postgres.Open(replicaDSN),
+				postgres.New(postgres.Config{
+					Conn: newGuardedPool(replica, NewBreaker("replica", config.BreakerThreshold, config.BreakerCooldown)),
+				}),
 			},
 			Policy: dbresolver.RandomPolicy{},
 		}))
-	}
-	if err != nil {
-		log.Fatalf("Db connection error")
-		return err
+		if err != nil {
+			return err
+		}
 	}
 	DB = db
 	return nil
 }
 
+// open returns a pool that sends statements with the simple protocol. The
+// request ID comment makes almost every statement text unique, so pgx's
+// prepared statement cache, keyed by text, would only add a round trip to
+// prepare each statement and another to evict an older one.
+func open(dsn string) (*sql.DB, error) {
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	config.PreferSimpleProtocol = true
+	return stdlib.OpenDB(*config), nil
+}
+
+// connect opens the master, retrying with exponential backoff while it
+// cannot be reached, e.g. when the app starts before Postgres.
+func connect(config Config) (*sql.DB, error) {
+	attempts := config.ConnectAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	wait := config.ConnectBackoff
+
+	master, err := open(config.MasterDSN)
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 1; ; attempt++ {
+		err := master.Ping()
+		if err == nil {
+			return master, nil
+		}
+		if attempt >= attempts {
+			_ = master.Close()
+			return nil, fmt.Errorf("connect after %d attempts: %w", attempt, err)
+		}
+
+		logger.Warnf("database connect attempt %d of %d failed, retrying in %s: %s", attempt, attempts, wait, err)
+		pause(wait)
+		if wait *= 2; wait > maxConnectBackoff {
+			wait = maxConnectBackoff
+		}
+	}
+}
+
+func configurePool(pool *sql.DB, config Config) {
+	if config.MaxOpenConns > 0 {
+		pool.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		pool.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	pool.SetConnMaxLifetime(config.ConnMaxLifetime)
+	pool.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+}
+
 // GetDB connection
 func GetDB() *gorm.DB {
 	return DB
 }
+
+// Close closes the master and replica connection pools.
+func Close() error {
+	var firstErr error
+	if DB != nil {
+		if master, err := DB.DB(); err == nil {
+			firstErr = master.Close()
+		}
+	}
+	for _, replica := range replicas {
+		if err := replica.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	replicas = nil
+	return firstErr
+}
+
+// Master returns the master connection pool.
+func Master() (*sql.DB, error) {
+	return DB.DB()
+}
+
+// Replicas returns the replica connection pools; none unless
+// Config.UseReplica was set.
+func Replicas() []*sql.DB {
+	return replicas
+}
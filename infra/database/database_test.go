@@ -0,0 +1,26 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnect_RetriesWithBackoff(t *testing.T) {
+	var waits []time.Duration
+	pause = func(d time.Duration) { waits = append(waits, d) }
+	defer func() { pause = time.Sleep }()
+
+	_, err := connect(Config{
+		MasterDSN:       "host=127.0.0.1 port=1 user=app dbname=app sslmode=disable connect_timeout=1",
+		ConnectAttempts: 4,
+		ConnectBackoff:  20 * time.Second,
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connect after 4 attempts")
+	assert.True(t, IsUnavailable(err), "got %v", err)
+	assert.Equal(t, []time.Duration{20 * time.Second, maxConnectBackoff, maxConnectBackoff}, waits)
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"errors"
+	"gin-boilerplate/infra/logger"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const queryStartKey = "querylog:start"
+
+// QueryLog logs every gorm operation with its statement, which only has
+// placeholders, so turning on Config.LogMode never writes passwords, webhook
+// secrets or other values to the log. Entries carry the request fields of
+// the statement context.
+type QueryLog struct{}
+
+func (QueryLog) Name() string {
+	return "querylog"
+}
+
+func (QueryLog) Initialize(db *gorm.DB) error {
+	return RegisterAround(db, "querylog", startQuery, logQuery)
+}
+
+func startQuery(db *gorm.DB, _ string) {
+	db.InstanceSet(queryStartKey, time.Now())
+}
+
+func logQuery(db *gorm.DB, _ string) {
+	value, ok := db.InstanceGet(queryStartKey)
+	if !ok {
+		return
+	}
+	start, ok := value.(time.Time)
+	if !ok {
+		return
+	}
+
+	entry := logger.FromContext(db.Statement.Context).WithFields(map[string]interface{}{
+		"sql":         db.Statement.SQL.String(),
+		"rows":        db.Statement.RowsAffected,
+		"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+	})
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		entry.WithError(db.Error).Warn("query")
+		return
+	}
+	entry.Info("query")
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"gin-boilerplate/infra/logger"
+	"gin-boilerplate/infra/logger/loggertest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type subscription struct {
+	ID     uint
+	Secret string
+}
+
+func TestQueryLog_LeavesOutValues(t *testing.T) {
+	out := loggertest.Capture(t)
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(QueryLog{}))
+
+	ctx := logger.WithFields(context.Background(), logger.Fields{"request_id": "req-1"})
+	db.WithContext(ctx).Create(&subscription{Secret: "whsec-123"})
+
+	assert.NotContains(t, out.String(), "whsec-123")
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "query", entry["msg"])
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.Contains(t, entry["sql"], `INSERT INTO "subscriptions" ("secret") VALUES ($1)`)
+}
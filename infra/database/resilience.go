@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+)
+
+// ErrCircuitOpen is returned, without touching the database, while the
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("database circuit breaker is open")
+
+// IsUnavailable reports whether err means the database could not be reached
+// or refused work, as opposed to rejecting the statement itself.
+func IsUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "53") || strings.HasPrefix(pgErr.Code, "57P")
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, driver.ErrBadConn) || pgconn.Timeout(err)
+}
+
+// Resilience is a gorm plugin that retries reads on transient errors. Only
+// plain SELECTs outside a database transaction are retried, up to
+// ReadRetries times with RetryBackoff doubling in between; anything else may
+// have had an effect already. Retries go through the breaker of the pool
+// like any other statement.
+type Resilience struct {
+	ReadRetries  int
+	RetryBackoff time.Duration
+}
+
+func (Resilience) Name() string {
+	return "resilience"
+}
+
+func (p Resilience) Initialize(db *gorm.DB) error {
+	return db.Callback().Query().After("gorm:query").Before("gorm:preload").Register("resilience:retry_read", p.retryRead)
+}
+
+// retryRead runs a failed SELECT again, the way gorm:query does.
+func (p Resilience) retryRead(db *gorm.DB) {
+	wait := p.RetryBackoff
+	for attempt := 1; attempt <= p.ReadRetries && p.retryable(db); attempt++ {
+		if !sleep(db.Statement.Context, wait) {
+			return
+		}
+		wait *= 2
+
+		db.Error = nil
+		rows, err := db.Statement.ConnPool.QueryContext(db.Statement.Context, db.Statement.SQL.String(), db.Statement.Vars...)
+		if err != nil {
+			_ = db.AddError(err)
+			continue
+		}
+		gorm.Scan(rows, db, 0)
+		_ = db.AddError(rows.Close())
+	}
+}
+
+func (p Resilience) retryable(db *gorm.DB) bool {
+	if db.Error == nil || db.DryRun || errors.Is(db.Error, ErrCircuitOpen) || !IsUnavailable(db.Error) {
+		return false
+	}
+	if db.Statement.Context.Err() != nil {
+		return false
+	}
+	if _, inTransaction := db.Statement.ConnPool.(gorm.TxCommitter); inTransaction {
+		return false
+	}
+	return isSelect(db.Statement.SQL.String())
+}
+
+// isSelect reports whether sql only reads, skipping a leading comment such
+// as the request ID.
+func isSelect(sql string) bool {
+	sql = strings.TrimSpace(sql)
+	if strings.HasPrefix(sql, "/*") {
+		if end := strings.Index(sql, "*/"); end >= 0 {
+			sql = strings.TrimSpace(sql[end+2:])
+		}
+	}
+	upper := strings.ToUpper(sql)
+	return strings.HasPrefix(upper, "SELECT") && !strings.Contains(upper, "FOR UPDATE")
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
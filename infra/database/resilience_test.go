@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// downPool is a connection pool whose database can not be reached.
+type downPool struct {
+	queries int
+	execs   int
+}
+
+func (p *downPool) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, driver.ErrBadConn
+}
+
+func (p *downPool) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	p.execs++
+	return nil, driver.ErrBadConn
+}
+
+func (p *downPool) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	p.queries++
+	return nil, driver.ErrBadConn
+}
+
+func (p *downPool) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+func openDown(t *testing.T, resilience Resilience) (*gorm.DB, *downPool) {
+	pool := &downPool{}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: pool}), &gorm.Config{DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(resilience))
+	return db, pool
+}
+
+func TestResilience_RetriesReads(t *testing.T) {
+	db, pool := openDown(t, Resilience{ReadRetries: 2, RetryBackoff: time.Millisecond})
+
+	err := db.Find(&[]subscription{}).Error
+
+	assert.ErrorIs(t, err, driver.ErrBadConn)
+	assert.Equal(t, 3, pool.queries)
+}
+
+func TestResilience_DoesNotRetryWrites(t *testing.T) {
+	db, pool := openDown(t, Resilience{ReadRetries: 2, RetryBackoff: time.Millisecond})
+
+	err := db.Model(&subscription{ID: 1}).Update("secret", "whsec-123").Error
+
+	assert.ErrorIs(t, err, driver.ErrBadConn)
+	assert.Equal(t, 1, pool.execs)
+}
+
+func TestIsUnavailable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("boom"), false},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{&pgconn.PgError{Code: "08006"}, true},
+		{&pgconn.PgError{Code: "53300"}, true},
+		{&pgconn.PgError{Code: "57P01"}, true},
+		{&pgconn.PgError{Code: "57014"}, false},
+		{fmt.Errorf("query: %w", driver.ErrBadConn), true},
+		{ErrCircuitOpen, true},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, IsUnavailable(c.err), "%v", c.err)
+	}
+}
+
+func TestIsSelect(t *testing.T) {
+	assert.True(t, isSelect(`SELECT * FROM "transactions"`))
+	assert.True(t, isSelect(`/* request_id=abc */ select 1`))
+	assert.False(t, isSelect(`SELECT * FROM "transactions" WHERE id = $1 FOR UPDATE`))
+	assert.False(t, isSelect(`UPDATE "transactions" SET status = $1`))
+	assert.False(t, isSelect(`/* select */ DELETE FROM "transactions"`))
+}
@@ -0,0 +1,33 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	report := Run(context.Background(), time.Second, []Check{{Name: "a", Run: ok}, {Name: "b", Run: ok}})
+	assert.True(t, report.OK())
+	assert.Len(t, report.Checks, 2)
+
+	report = Run(context.Background(), 10*time.Millisecond, []Check{
+		{Name: "a", Run: ok},
+		{Name: "slow", Run: slow},
+		{Name: "broken", Run: func(ctx context.Context) error { return errors.New("connection refused") }},
+	})
+	assert.False(t, report.OK())
+	assert.Equal(t, StatusOK, report.Checks["a"].Status)
+	assert.Equal(t, Result{Status: StatusFail, LatencyMS: report.Checks["broken"].LatencyMS, Error: ErrorUnavailable}, report.Checks["broken"])
+	assert.Equal(t, ErrorUnavailable, report.Checks["slow"].Error)
+	assert.GreaterOrEqual(t, report.Checks["slow"].LatencyMS, float64(10))
+}
@@ -0,0 +1,51 @@
+// Package lifecycle tracks whether the process should receive traffic and
+// tells long lived connections when the server starts shutting down.
+package lifecycle
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// State is the readiness of the process. A nil *State is always ready and
+// never closing, so handlers built without one keep working.
+type State struct {
+	ready   int32
+	closing chan struct{}
+	once    sync.Once
+}
+
+// New returns a State that is not ready yet.
+func New() *State {
+	return &State{closing: make(chan struct{})}
+}
+
+// SetReady marks the process ready or not ready to receive traffic.
+func (s *State) SetReady(ready bool) {
+	var v int32
+	if ready {
+		v = 1
+	}
+	atomic.StoreInt32(&s.ready, v)
+}
+
+// Ready reports whether the process should receive traffic.
+func (s *State) Ready() bool {
+	return s == nil || atomic.LoadInt32(&s.ready) == 1
+}
+
+// Closing is closed by Close. Streams and sockets select on it to end before
+// the server waits for in-flight requests.
+func (s *State) Closing() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	return s.closing
+}
+
+// Close marks the process not ready and closes Closing. It is safe to call
+// more than once.
+func (s *State) Close() {
+	s.SetReady(false)
+	s.once.Do(func() { close(s.closing) })
+}
@@ -0,0 +1,27 @@
+package lifecycle
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestState(t *testing.T) {
+	var none *State
+	assert.True(t, none.Ready())
+	assert.Nil(t, none.Closing())
+
+	s := New()
+	assert.False(t, s.Ready())
+	s.SetReady(true)
+	assert.True(t, s.Ready())
+
+	s.Close()
+	s.Close()
+	assert.False(t, s.Ready())
+	select {
+	case <-s.Closing():
+	default:
+		t.Fatal("Closing should be closed")
+	}
+}
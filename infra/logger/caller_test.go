@@ -0,0 +1,22 @@
+package logger_test
+
+import (
+	"encoding/json"
+	"gin-boilerplate/infra/logger"
+	"gin-boilerplate/infra/logger/loggertest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfof_ReportsItsCaller(t *testing.T) {
+	out := loggertest.Capture(t)
+
+	logger.Infof("started")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "gin-boilerplate/infra/logger_test.TestInfof_ReportsItsCaller", entry["func"])
+	assert.Regexp(t, `/infra/logger/caller_test\.go:\d+$`, entry["file"])
+}
@@ -1,17 +1,24 @@
 package logger
 
 import (
-	"bytes"
+	"context"
+	"fmt"
 	"github.com/sirupsen/logrus"
+	"io"
+	"reflect"
+	"runtime"
 	"strings"
-	"time"
 )
 
 var logger = logrus.New()
 
+// packagePath is the import path of this package, whose frames are skipped
+// when reporting the caller.
+var packagePath = reflect.TypeOf(formatter{}).PkgPath()
+
 func init() {
 	logger.Level = logrus.InfoLevel
-	logger.Formatter = &formatter{}
+	logger.Formatter = newFormatter("logfmt", nil)
 
 	logger.SetReportCaller(true)
 }
@@ -20,8 +27,62 @@ func SetLogLevel(level logrus.Level) {
 	logger.Level = level
 }
 
+// Configure sets the level ("debug", "info", "warn", "error"), the format
+// ("json" or "logfmt") and extra field names to redact on top of
+// DefaultRedactedFields.
+func Configure(level, format string, redact []string) error {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	if format != "json" && format != "logfmt" {
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	logger.Level = parsed
+	logger.Formatter = newFormatter(format, redact)
+	return nil
+}
+
+// SetOutput sets where entries are written, stderr by default.
+func SetOutput(out io.Writer) {
+	logger.SetOutput(out)
+}
+
 type Fields logrus.Fields
 
+// AddHook runs hook for every entry, e.g. to add fields from its context.
+func AddHook(hook logrus.Hook) {
+	logger.AddHook(hook)
+}
+
+type fieldsKey struct{}
+
+// WithFields returns a copy of ctx whose FromContext entries carry fields on
+// top of the ones ctx already had.
+func WithFields(ctx context.Context, fields Fields) context.Context {
+	merged := Fields{}
+	if parent, ok := ctx.Value(fieldsKey{}).(Fields); ok {
+		for key, value := range parent {
+			merged[key] = value
+		}
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// FromContext returns an entry with the fields added to ctx by WithFields,
+// such as the request ID and route, and ctx itself so hooks can add more,
+// such as the trace ID.
+func FromContext(ctx context.Context) *logrus.Entry {
+	entry := logger.WithContext(ctx)
+	if fields, ok := ctx.Value(fieldsKey{}).(Fields); ok {
+		entry = entry.WithFields(logrus.Fields(fields))
+	}
+	return entry
+}
+
 // Debugf logs a message at level Debug on the standard logger.
 func Debugf(format string, args ...interface{}) {
 	if logger.Level >= logrus.DebugLevel {
@@ -62,21 +123,83 @@ func Fatalf(format string, args ...interface{}) {
 	}
 }
 
-// Formatter implements logrus.Formatter interface.
+// DefaultRedactedFields are always redacted. A field is redacted when its
+// name contains one of them, ignoring case.
+var DefaultRedactedFields = []string{"password", "secret", "token", "authorization", "cookie", "api_key", "dsn"}
+
+const redacted = "[REDACTED]"
+
+// formatter redacts sensitive fields before handing the entry to the JSON
+// or logfmt formatter.
 type formatter struct {
-	prefix string
+	inner  logrus.Formatter
+	redact []string
+}
+
+func newFormatter(format string, redact []string) *formatter {
+	var inner logrus.Formatter = &logrus.TextFormatter{DisableColors: true, FullTimestamp: true, CallerPrettyfier: caller}
+	if format == "json" {
+		inner = &logrus.JSONFormatter{CallerPrettyfier: caller}
+	}
+
+	keys := append([]string{}, DefaultRedactedFields...)
+	for _, key := range redact {
+		if key = strings.ToLower(strings.TrimSpace(key)); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return &formatter{inner: inner, redact: keys}
 }
 
 // Format building log message.
 func (f *formatter) Format(entry *logrus.Entry) ([]byte, error) {
-	var sb bytes.Buffer
+	var data logrus.Fields
+	for key := range entry.Data {
+		if !f.sensitive(key) {
+			continue
+		}
+		if data == nil {
+			data = make(logrus.Fields, len(entry.Data))
+			for k, v := range entry.Data {
+				data[k] = v
+			}
+		}
+		data[key] = redacted
+	}
+	if data == nil {
+		return f.inner.Format(entry)
+	}
 
-	sb.WriteString(strings.ToUpper(entry.Level.String()))
-	sb.WriteString(" ")
-	sb.WriteString(entry.Time.Format(time.RFC3339))
-	sb.WriteString(" ")
-	sb.WriteString(f.prefix)
-	sb.WriteString(entry.Message)
+	copied := *entry
+	copied.Data = data
+	return f.inner.Format(&copied)
+}
 
-	return sb.Bytes(), nil
+// caller reports the first frame outside logrus and this package. logrus
+// only skips its own frames, so it would report Infof and the other
+// wrappers here as the caller of every entry logged through them.
+func caller(frame *runtime.Frame) (function, file string) {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		next, more := frames.Next()
+		if !strings.HasPrefix(next.Function, "github.com/sirupsen/logrus.") && !strings.HasPrefix(next.Function, packagePath+".") {
+			frame = &next
+			break
+		}
+		if !more {
+			break
+		}
+	}
+	return frame.Function, fmt.Sprintf("%s:%d", frame.File, frame.Line)
+}
+
+func (f *formatter) sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, name := range f.redact {
+		if strings.Contains(key, name) {
+			return true
+		}
+	}
+	return false
 }
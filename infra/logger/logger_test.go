@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func capture(t *testing.T, format string, redact []string) *bytes.Buffer {
+	t.Helper()
+	var out bytes.Buffer
+	require.NoError(t, Configure("debug", format, redact))
+	SetOutput(&out)
+	t.Cleanup(func() {
+		SetOutput(os.Stderr)
+		logger.Level = logrus.InfoLevel
+		logger.Formatter = newFormatter("logfmt", nil)
+	})
+	return &out
+}
+
+func TestConfigure_RejectsUnknownValues(t *testing.T) {
+	assert.Error(t, Configure("loud", "json", nil))
+	assert.Error(t, Configure("info", "xml", nil))
+}
+
+func TestFromContext_JSON(t *testing.T) {
+	out := capture(t, "json", []string{"card_number"})
+
+	ctx := WithFields(context.Background(), Fields{"request_id": "abc"})
+	ctx = WithFields(ctx, Fields{"route": "/api/v1/transaction"})
+	FromContext(ctx).WithFields(logrus.Fields{
+		"Authorization": "Bearer x",
+		"card_number":   "4111",
+		"status":        200,
+	}).Info("request")
+
+	require.True(t, strings.HasSuffix(out.String(), "\n"))
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "request", entry["msg"])
+	assert.Equal(t, "abc", entry["request_id"])
+	assert.Equal(t, "/api/v1/transaction", entry["route"])
+	assert.Equal(t, float64(200), entry["status"])
+	assert.Equal(t, "[REDACTED]", entry["Authorization"])
+	assert.Equal(t, "[REDACTED]", entry["card_number"])
+}
+
+func TestInfof_Logfmt(t *testing.T) {
+	out := capture(t, "logfmt", nil)
+
+	FromContext(context.Background()).WithField("password", "hunter2").Infof("started on %d", 8000)
+
+	line := out.String()
+	assert.Contains(t, line, `msg="started on 8000"`)
+	assert.Contains(t, line, `password="[REDACTED]"`)
+	assert.NotContains(t, line, "hunter2")
+	assert.True(t, strings.HasSuffix(line, "\n"))
+}
@@ -0,0 +1,25 @@
+// Package loggertest captures what the logger writes in tests.
+package loggertest
+
+import (
+	"bytes"
+	"gin-boilerplate/infra/logger"
+	"os"
+	"testing"
+)
+
+// Capture switches the logger to JSON at level Info, writing into the
+// returned buffer until the test ends.
+func Capture(t testing.TB) *bytes.Buffer {
+	t.Helper()
+	var out bytes.Buffer
+	if err := logger.Configure("info", "json", nil); err != nil {
+		t.Fatal(err)
+	}
+	logger.SetOutput(&out)
+	t.Cleanup(func() {
+		logger.SetOutput(os.Stderr)
+		_ = logger.Configure("info", "logfmt", nil)
+	})
+	return &out
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"gin-boilerplate/infra/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const startKey = "metrics:start"
+
+// GormPlugin times every gorm operation into db_query_duration_seconds.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "metrics"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	return database.RegisterAround(db, "metrics", startTimer, observe)
+}
+
+func startTimer(db *gorm.DB, _ string) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func observe(db *gorm.DB, operation string) {
+	value, ok := db.InstanceGet(startKey)
+	if !ok {
+		return
+	}
+	start, ok := value.(time.Time)
+	if !ok {
+		return
+	}
+	dbQueryDuration.WithLabelValues(operation, db.Statement.Table).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware_LabelsByRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/transaction/:id", func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+	router.GET("/metrics", gin.WrapH(Handler()))
+
+	for _, path := range []string{"/transaction/1", "/transaction/2", "/nope/1", "/nope/2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/transaction/:id", "204")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(httpRequests.WithLabelValues("GET", unmatchedRoute, "404")))
+
+	StatusTransitions.WithLabelValues("pending", "success").Inc()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `http_request_duration_seconds_count{method="GET",route="/transaction/:id"} 2`)
+	assert.Contains(t, w.Body.String(), `transaction_status_transitions_total{from="pending",to="success"} 1`)
+}
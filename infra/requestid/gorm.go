@@ -0,0 +1,60 @@
+package requestid
+
+import (
+	"gin-boilerplate/infra/database"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const commentPrefix = "/* request_id="
+
+// GormPlugin prefixes every statement whose context carries an ID (see
+// repository WithContext) with a /* request_id=... */ comment, so a slow or
+// failing query in pg_stat_activity or the database log can be traced back
+// to the request.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "requestid"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	return database.RegisterAround(db, "requestid", comment, nil)
+}
+
+// firstClauses are the clauses each operation builds its statement from;
+// raw statements have none.
+var firstClauses = map[string]string{
+	"create": "INSERT",
+	"query":  "SELECT",
+	"update": "UPDATE",
+	"delete": "DELETE",
+	"row":    "SELECT",
+}
+
+// comment adds the comment in front of the first clause of the statement,
+// or in front of the SQL when it was given raw.
+func comment(db *gorm.DB, operation string) {
+	id := FromContext(db.Statement.Context)
+	if !Valid(id) {
+		return
+	}
+	text := commentPrefix + id + " */"
+
+	firstClause := firstClauses[operation]
+	if db.Statement.SQL.Len() > 0 || firstClause == "" {
+		sql := db.Statement.SQL.String()
+		if strings.HasPrefix(sql, commentPrefix) {
+			return
+		}
+		db.Statement.SQL.Reset()
+		db.Statement.SQL.WriteString(text + " " + sql)
+		return
+	}
+
+	c := db.Statement.Clauses[firstClause]
+	c.BeforeExpression = clause.Expr{SQL: text}
+	db.Statement.Clauses[firstClause] = c
+}
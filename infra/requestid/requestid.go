@@ -0,0 +1,65 @@
+// Package requestid carries the ID that correlates a client request with
+// the logs, queries and outbound calls it caused.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// Header is the request and response header holding the ID.
+const Header = "X-Request-ID"
+
+// maxLength bounds the IDs accepted from clients.
+const maxLength = 128
+
+type contextKey struct{}
+
+// New returns a random 32 character hex ID.
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Valid reports whether id can be used as is: 1 to 128 letters, digits,
+// '.', '_' or '-'. Anything else is replaced, so IDs are safe to put in
+// logs, headers and SQL comments.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the ID carried by ctx, or "" when there is none.
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// SetHeader forwards id on an outbound request. An empty id is left out.
+func SetHeader(req *http.Request, id string) {
+	if id != "" {
+		req.Header.Set(Header, id)
+	}
+}
@@ -0,0 +1,52 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid(New()))
+	assert.True(t, Valid("req-1.a_B"))
+	assert.False(t, Valid(""))
+	assert.False(t, Valid("a */ DROP TABLE transactions; /*"))
+	assert.False(t, Valid(string(make([]byte, maxLength+1))))
+}
+
+func TestSetHeader(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com", nil)
+	SetHeader(req, "")
+	assert.Empty(t, req.Header.Get(Header))
+	SetHeader(req, "abc")
+	assert.Equal(t, "abc", req.Header.Get(Header))
+}
+
+type row struct {
+	ID     int
+	Status string
+}
+
+func TestGormPlugin(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(GormPlugin{}))
+
+	ctx := NewContext(context.Background(), "abc")
+	stmt := db.WithContext(ctx).Where("status = ?", "pending").Find(&[]row{}).Statement
+	assert.Equal(t, `/* request_id=abc */ SELECT * FROM "rows" WHERE status = $1`, stmt.SQL.String())
+
+	stmt = db.WithContext(ctx).Model(&row{}).Where("id = ?", 1).Update("status", "success").Statement
+	assert.Equal(t, `/* request_id=abc */ UPDATE "rows" SET "status"=$1 WHERE id = $2`, stmt.SQL.String())
+
+	stmt = db.WithContext(ctx).Exec("DELETE FROM rows").Statement
+	assert.Equal(t, `/* request_id=abc */ DELETE FROM rows`, stmt.SQL.String())
+
+	stmt = db.WithContext(context.Background()).Find(&[]row{}).Statement
+	assert.Equal(t, `SELECT * FROM "rows"`, stmt.SQL.String())
+}
@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"errors"
+	"gin-boilerplate/infra/database"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+// GormPlugin adds a client span per gorm operation, as a child of the span
+// in the statement context (see repository WithContext). Queries without a
+// span in their context, such as the background jobs' polling, are not
+// traced.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	return database.RegisterAround(db, "tracing", startSpan, endSpan)
+}
+
+func startSpan(db *gorm.DB, operation string) {
+	if !trace.SpanContextFromContext(db.Statement.Context).IsValid() {
+		return
+	}
+	_, span := tracer.Start(db.Statement.Context, "gorm."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL),
+	)
+	db.InstanceSet(spanKey, span)
+}
+
+func endSpan(db *gorm.DB, _ string) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	// The statement has placeholders only, never the values.
+	span.SetAttributes(
+		semconv.DBSQLTableKey.String(db.Statement.Table),
+		semconv.DBStatementKey.String(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
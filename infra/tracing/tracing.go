@@ -0,0 +1,73 @@
+// Package tracing sets up OpenTelemetry tracing and instruments gin, gorm
+// and the logger with it.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+const instrumentationName = "gin-boilerplate"
+
+// tracer goes through the global provider, so spans started before Setup are
+// no-ops and later ones use the configured exporter.
+var tracer = otel.Tracer(instrumentationName)
+
+type Config struct {
+	// Exporter is "otlp", "stdout" or "none".
+	Exporter    string
+	ServiceName string
+	// SampleRatio is the share of new traces recorded; traces continued from
+	// a caller follow the caller's decision.
+	SampleRatio float64
+	// OTLPEndpoint is the host:port of an OTLP/HTTP collector. Empty uses
+	// OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318.
+	OTLPEndpoint string
+	OTLPInsecure bool
+}
+
+// Setup installs the W3C trace-context propagator and a tracer provider
+// exporting to cfg.Exporter. With "none" no spans are recorded, but trace IDs
+// sent by callers still reach the logs. The returned function flushes pending
+// spans and stops the exporter.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New()
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.OTLPEndpoint))
+		}
+		if cfg.OTLPInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,135 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gin-boilerplate/events"
+	"gin-boilerplate/infra/logger"
+	"gin-boilerplate/models"
+	"gin-boilerplate/repository"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	outboxBaseBackoff = time.Second
+	outboxMaxBackoff  = 5 * time.Minute
+	// outboxLease must outlast publishing a whole batch.
+	outboxLease = 5 * time.Minute
+)
+
+// OutboxPublishers are the publishers outbox events are relayed to, by name.
+// The name is recorded on an event once it is published there, so a retry
+// only goes to the publishers that failed.
+type OutboxPublishers map[string]events.Publisher
+
+// StartOutboxRelay publishes outbox events every interval until ctx is
+// cancelled. Events are published at least once: one that was published but
+// not yet recorded as such when the process stopped is published again.
+func StartOutboxRelay(ctx context.Context, repo repository.OutboxRepository, publishers OutboxPublishers, interval time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Keep draining while there are full batches.
+		for {
+			relayed, err := RelayOutbox(ctx, repo, publishers, time.Now(), batchSize)
+			if err != nil {
+				logger.Errorf("outbox relay error: %s", err)
+			}
+			if err != nil || relayed < batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOutbox publishes up to batchSize events due at now, oldest first. An
+// event is removed once every publisher has it; otherwise a retry is
+// scheduled with exponential backoff. It returns the number of events
+// claimed.
+func RelayOutbox(ctx context.Context, repo repository.OutboxRepository, publishers OutboxPublishers, now time.Time, batchSize int) (int, error) {
+	rows, err := repo.ClaimOutbox(now, batchSize, outboxLease)
+	if err != nil {
+		return 0, err
+	}
+
+	names := make([]string, 0, len(publishers))
+	for name := range publishers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, row := range rows {
+		if ctx.Err() != nil {
+			return len(rows), ctx.Err()
+		}
+
+		event := outboxEvent(row)
+		publishedTo := append([]string(nil), row.PublishedTo...)
+		var failures []string
+		for _, name := range names {
+			if contains(publishedTo, name) {
+				continue
+			}
+			if err := publishers[name].Publish(ctx, event); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %s", name, err))
+				continue
+			}
+			publishedTo = append(publishedTo, name)
+		}
+
+		if len(failures) == 0 {
+			if err := repo.DeleteOutbox(row.ID); err != nil {
+				return len(rows), err
+			}
+			continue
+		}
+
+		attempts := row.Attempts + 1
+		lastError := strings.Join(failures, "; ")
+		logger.Warnf("outbox publish %s %s attempt %d error: %s", row.Type, row.EventID, attempts, lastError)
+		if err := repo.RetryOutbox(row.ID, publishedTo, attempts, lastError, now.Add(outboxBackoff(attempts))); err != nil {
+			return len(rows), err
+		}
+	}
+	return len(rows), nil
+}
+
+func outboxEvent(row models.OutboxEvent) events.Event {
+	return events.Event{
+		ID:         row.EventID,
+		Type:       row.Type,
+		OccurredAt: row.OccurredAt,
+		Data:       json.RawMessage(row.Payload),
+		RequestID:  row.RequestID,
+	}
+}
+
+func outboxBackoff(attempts int) time.Duration {
+	wait := outboxBaseBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= outboxMaxBackoff {
+			return outboxMaxBackoff
+		}
+	}
+	return wait
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"gin-boilerplate/events"
+	"gin-boilerplate/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryOutbox is an in-memory OutboxRepository.
+type memoryOutbox struct {
+	rows []models.OutboxEvent
+}
+
+func (m *memoryOutbox) ClaimOutbox(now time.Time, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	var due []models.OutboxEvent
+	for i := range m.rows {
+		if len(due) < limit && !m.rows[i].NextAttemptAt.After(now) {
+			m.rows[i].NextAttemptAt = now.Add(lease)
+			due = append(due, m.rows[i])
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryOutbox) DeleteOutbox(id uint) error {
+	for i := range m.rows {
+		if m.rows[i].ID == id {
+			m.rows = append(m.rows[:i], m.rows[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *memoryOutbox) RetryOutbox(id uint, publishedTo []string, attempts int, lastError string, nextAttemptAt time.Time) error {
+	for i := range m.rows {
+		if m.rows[i].ID == id {
+			m.rows[i].PublishedTo = publishedTo
+			m.rows[i].Attempts = attempts
+			m.rows[i].LastError = lastError
+			m.rows[i].NextAttemptAt = nextAttemptAt
+		}
+	}
+	return nil
+}
+
+// flakyPublisher fails its first failures calls.
+type flakyPublisher struct {
+	failures int
+	events.MemoryPublisher
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, event events.Event) error {
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("unavailable")
+	}
+	return p.MemoryPublisher.Publish(ctx, event)
+}
+
+func TestRelayOutbox(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := &memoryOutbox{rows: []models.OutboxEvent{
+		{ID: 1, EventID: "a", Type: events.TransactionCreated, Payload: models.JSONRaw(`{"id":1}`), NextAttemptAt: now},
+		{ID: 2, EventID: "b", Type: events.TransactionDeleted, Payload: models.JSONRaw(`{"id":1}`), NextAttemptAt: now},
+	}}
+	memory := &events.MemoryPublisher{}
+	flaky := &flakyPublisher{failures: 1}
+	publishers := OutboxPublishers{"memory": memory, "flaky": flaky}
+
+	relayed, err := RelayOutbox(context.Background(), repo, publishers, now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, relayed)
+
+	// The first event failed on flaky only and is kept for a retry.
+	require.Len(t, repo.rows, 1)
+	row := repo.rows[0]
+	assert.Equal(t, "a", row.EventID)
+	assert.Equal(t, models.StringList{"memory"}, row.PublishedTo)
+	assert.Equal(t, 1, row.Attempts)
+	assert.Contains(t, row.LastError, "flaky: unavailable")
+	assert.Equal(t, now.Add(outboxBaseBackoff), row.NextAttemptAt)
+
+	// Not due yet.
+	relayed, err = RelayOutbox(context.Background(), repo, publishers, now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, relayed)
+
+	_, err = RelayOutbox(context.Background(), repo, publishers, now.Add(outboxBaseBackoff), 10)
+	require.NoError(t, err)
+	assert.Empty(t, repo.rows)
+
+	// The retry only went to the publisher that failed.
+	published := memory.Events()
+	require.Len(t, published, 2)
+	assert.Equal(t, "a", published[0].ID)
+	assert.Equal(t, "b", published[1].ID)
+	assert.Equal(t, events.TransactionDeleted, published[1].Type)
+	assert.Len(t, flaky.Events(), 2)
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, outboxBackoff(1))
+	assert.Equal(t, 4*time.Second, outboxBackoff(3))
+	assert.Equal(t, outboxMaxBackoff, outboxBackoff(20))
+}
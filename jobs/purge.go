@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"context"
+	"gin-boilerplate/infra/logger"
+	"gin-boilerplate/repository"
+	"time"
+)
+
+// StartPurgeDeleted periodically hard deletes transactions that have been
+// soft deleted for longer than retention. It returns once ctx is cancelled.
+func StartPurgeDeleted(ctx context.Context, repo repository.TransactionRepository, retention, interval time.Duration) {
+	if retention <= 0 || interval <= 0 {
+		logger.Infof("purge job disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeDeleted(repo, retention)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func purgeDeleted(repo repository.TransactionRepository, retention time.Duration) {
+	cutoff := time.Now().Add(-retention)
+	purged, err := repo.PurgeDeletedBefore(cutoff)
+	if err != nil {
+		logger.Errorf("purge deleted transactions error: %s", err)
+		return
+	}
+	if purged > 0 {
+		logger.Infof("purged %d transactions deleted before %s", purged, cutoff.Format(time.RFC3339))
+	}
+}
@@ -1,36 +1,341 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"gin-boilerplate/config"
+	"gin-boilerplate/controllers"
+	"gin-boilerplate/dashboard"
+	"gin-boilerplate/events"
+	"gin-boilerplate/grpcapi"
 	"gin-boilerplate/infra/database"
+	"gin-boilerplate/infra/health"
+	"gin-boilerplate/infra/lifecycle"
 	"gin-boilerplate/infra/logger"
+	"gin-boilerplate/infra/metrics"
+	"gin-boilerplate/infra/requestid"
+	"gin-boilerplate/infra/tracing"
+	"gin-boilerplate/jobs"
 	"gin-boilerplate/migrations"
-	"gin-boilerplate/routers"
-	"gin-boilerplate/controllers"
 	"gin-boilerplate/repository"
-	"github.com/spf13/viper"
+	"gin-boilerplate/routers"
+	"gin-boilerplate/webhooks"
+	"google.golang.org/grpc"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
-	"log"
 )
 
+// streamBuffer is how many live events a slow stream or dashboard client may
+// lag behind before it is disconnected or resynced.
+const streamBuffer = 64
+
 func main() {
 
-	viper.SetDefault("SERVER_TIMEZONE", "Asia/Dhaka")
-	loc, _ := time.LoadLocation(viper.GetString("SERVER_TIMEZONE"))
-	time.Local = loc
+	cfg, err := config.Load(os.Args[1:])
+	if config.IsHelp(err) {
+		return
+	}
+	if err != nil {
+		logger.Fatalf("config error: %s", err)
+	}
+	time.Local = cfg.Server.Location
 
-	if err := config.SetupConfig(); err != nil {
-		logger.Fatalf("config SetupConfig() error: %s", err)
+	if err := logger.Configure(cfg.Log.Level, cfg.Log.Format, cfg.Log.Redact); err != nil {
+		logger.Fatalf("logger configure error: %s", err)
 	}
-	masterDSN, replicaDSN := config.DbConfiguration()
+	tracingConfig := cfg.Tracing
+	stopTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:     tracingConfig.Exporter,
+		ServiceName:  tracingConfig.ServiceName,
+		SampleRatio:  tracingConfig.SampleRatio,
+		OTLPEndpoint: tracingConfig.OTLPEndpoint,
+		OTLPInsecure: tracingConfig.OTLPInsecure,
+	})
+	if err != nil {
+		logger.Fatalf("tracing setup error: %s", err)
+	}
+	logger.AddHook(tracing.LogHook{})
+
+	if err := database.DbConnection(database.Config{
+		MasterDSN:  cfg.Database.Master.DSN(),
+		ReplicaDSN: cfg.Database.Replica.DSN(),
+		UseReplica: cfg.Database.UseReplica(cfg.Server.Debug),
+		LogMode:    cfg.Database.LogMode,
 
-	if err := database.DbConnection(masterDSN, replicaDSN); err != nil {
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.Database.ConnMaxIdleTime,
+		ConnectAttempts: cfg.Database.ConnectAttempts,
+		ConnectBackoff:  cfg.Database.ConnectBackoff,
+
+		BreakerThreshold: cfg.Database.BreakerThreshold,
+		BreakerCooldown:  cfg.Database.BreakerCooldown,
+		Resilience: database.Resilience{
+			ReadRetries:  cfg.Database.ReadRetries,
+			RetryBackoff: cfg.Database.ReadRetryBackoff,
+		},
+	}); err != nil {
 		logger.Fatalf("database DbConnection error: %s", err)
 	}
 	//later separate migration
-	migrations.Migrate()
+	if err := migrations.Migrate(); err != nil {
+		logger.Fatalf("database migration error: %s", err)
+	}
+	instrumentDB()
+
+	state := lifecycle.New()
+	workers := newWorkers()
+
+	live := events.NewHub(cfg.Stream.History, streamBuffer)
+	transactionRepo := &repository.TransactionRepositoryImpl{Feed: live}
+
+	dashboardChanges := events.NewHub(1, streamBuffer)
+	tracker := dashboard.NewTracker(transactionRepo, dashboardChanges)
+	workers.Go(func(ctx context.Context) { tracker.Run(ctx, live) })
+
+	webhookConfig := cfg.Webhook
+	webhookService := webhooks.NewService(&repository.WebhookRepositoryImpl{}, webhooks.Config{
+		MaxAttempts:  webhookConfig.MaxAttempts,
+		BaseBackoff:  webhookConfig.BaseBackoff,
+		MaxBackoff:   webhookConfig.MaxBackoff,
+		Timeout:      webhookConfig.Timeout,
+		PollInterval: webhookConfig.PollInterval,
+
+		AllowPrivateAddresses: webhookConfig.AllowPrivateURLs,
+	})
+	workers.Go(webhookService.Run)
+
+	outboxConfig := cfg.Outbox
+	publishers, err := outboxPublishers(outboxConfig, webhookService)
+	if err != nil {
+		logger.Fatalf("outbox publisher error: %s", err)
+	}
+	workers.Go(func(ctx context.Context) {
+		jobs.StartOutboxRelay(ctx, &repository.OutboxRepositoryImpl{}, publishers, outboxConfig.PollInterval, outboxConfig.BatchSize)
+	})
+
+	workers.Go(func(ctx context.Context) {
+		jobs.StartPurgeDeleted(ctx, transactionRepo, cfg.Purge.Retention, cfg.Purge.Interval)
+	})
+
+	var grpcServer *grpc.Server
+	if addr := cfg.GRPC.Addr(); addr != "" {
+		grpcServer = serveGRPC(addr, cfg, transactionRepo)
+	}
+
+	router := routers.SetupRoute(routers.Services{
+		Config:           cfg,
+		Transactions:     transactionRepo,
+		Webhooks:         webhookService,
+		Live:             live,
+		DashboardChanges: dashboardChanges,
+		Lifecycle:        state,
+		Readiness:        readinessChecks(),
+	})
+
+	serverConfig := cfg.Server
+	server := &http.Server{
+		Addr:              serverConfig.Addr(),
+		Handler:           router,
+		ReadTimeout:       serverConfig.ReadTimeout,
+		ReadHeaderTimeout: serverConfig.ReadHeaderTimeout,
+		WriteTimeout:      serverConfig.WriteTimeout,
+		IdleTimeout:       serverConfig.IdleTimeout,
+	}
+	// Shutdown does not wait for streams and hijacked WebSockets, so they are
+	// told to end through the lifecycle instead.
+	server.RegisterOnShutdown(state.Close)
+
+	listener, err := net.Listen("tcp", serverConfig.Addr())
+	if err != nil {
+		logger.Fatalf("http listen error: %s", err)
+	}
+	logger.Infof("Server Running at :%s", serverConfig.Addr())
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Serve(listener) }()
+	state.SetReady(true)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case sig := <-signals:
+		// Sinyal kedua langsung menghentikan proses
+		signal.Stop(signals)
+		logger.Infof("received %s, shutting down", sig)
+	case err := <-serveErr:
+		logger.Fatalf("%v", err)
+	}
+
+	shutdown(serverConfig, state, server, grpcServer, workers, stopTracing)
+}
+
+// instrumentDB tags, traces and times every query and exports the master
+// and replica pool stats.
+func instrumentDB() {
+	if err := database.DB.Use(requestid.GormPlugin{}); err != nil {
+		logger.Errorf("db request id error: %s", err)
+	}
+	if err := database.DB.Use(tracing.GormPlugin{}); err != nil {
+		logger.Errorf("db tracing error: %s", err)
+	}
+	if err := database.DB.Use(metrics.GormPlugin{}); err != nil {
+		logger.Errorf("db metrics error: %s", err)
+	}
+	if master, err := database.Master(); err == nil {
+		_ = metrics.RegisterDB("master", master)
+	}
+	for i, replica := range database.Replicas() {
+		_ = metrics.RegisterDB(fmt.Sprintf("replica_%d", i+1), replica)
+	}
+}
+
+// readinessChecks ping the master and every replica and check the master
+// was migrated to the schema this build expects.
+func readinessChecks() []health.Check {
+	checks := []health.Check{{Name: "migrations", Run: migrations.CheckVersion}}
+	if master, err := database.Master(); err == nil {
+		checks = append(checks, health.Check{Name: "master", Run: master.PingContext})
+	}
+	for i, replica := range database.Replicas() {
+		checks = append(checks, health.Check{Name: fmt.Sprintf("replica_%d", i+1), Run: replica.PingContext})
+	}
+	return checks
+}
+
+// shutdown turns readiness off, keeps serving for the drain delay so load
+// balancers move traffic away, then waits up to the shutdown timeout for
+// in-flight HTTP requests, gRPC calls and background workers before closing
+// the database and flushing the remaining spans.
+func shutdown(cfg config.ServerConfiguration, state *lifecycle.State, server *http.Server, grpcServer *grpc.Server, workers *workers, stopTracing func(context.Context) error) {
+	state.SetReady(false)
+	logger.Infof("not ready, draining for %s", cfg.DrainDelay)
+	time.Sleep(cfg.DrainDelay)
 
-	router := routers.SetupRoute()
-	logger.Fatalf("%v", router.Run(config.ServerConfig()))
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Errorf("http shutdown error: %s", err)
+	}
+	if grpcServer != nil {
+		stopGRPC(ctx, grpcServer)
+	}
+	if !workers.Stop(ctx) {
+		logger.Errorf("background workers did not stop within %s", cfg.ShutdownTimeout)
+	}
+	if err := database.Close(); err != nil {
+		logger.Errorf("database close error: %s", err)
+	}
+	if err := stopTracing(ctx); err != nil {
+		logger.Errorf("tracing flush error: %s", err)
+	}
+	logger.Infof("shutdown complete")
+}
+
+// workers runs the background jobs with a context that Stop cancels.
+type workers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWorkers() *workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workers{ctx: ctx, cancel: cancel}
+}
+
+// Go runs job in a goroutine until its context is cancelled.
+func (w *workers) Go(job func(ctx context.Context)) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		job(w.ctx)
+	}()
+}
+
+// Stop cancels the jobs and reports whether they all returned before ctx
+// was done.
+func (w *workers) Stop(ctx context.Context) bool {
+	w.cancel()
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// stopGRPC lets running calls finish, cancelling them when ctx is done.
+func stopGRPC(ctx context.Context, server *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		server.Stop()
+	}
+}
+
+// serveGRPC starts the gRPC API on addr with the same rules as the REST API.
+func serveGRPC(addr string, cfg config.Configuration, repo repository.TransactionRepository) *grpc.Server {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Fatalf("grpc listen error: %s", err)
+	}
+
+	server := grpcapi.NewServer(&grpcapi.Server{
+		Repo: repo,
+		CreateRules: controllers.CreateTransactionRules{
+			MaxAmount:       cfg.Transaction.MaxAmount,
+			InitialStatuses: cfg.Transaction.InitialStatuses,
+		},
+		ExportMaxRows:         cfg.Transaction.ExportMaxRows,
+		RequireVersion:        cfg.Server.RequireIfMatch,
+		MaxPageSize:           cfg.GRPC.MaxPageSize,
+		Reflection:            cfg.Server.Debug,
+		IncludeDeletedEnabled: cfg.Transaction.IncludeDeletedEnabled,
+	})
+	logger.Infof("gRPC Running at :%s", addr)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			logger.Fatalf("%v", err)
+		}
+	}()
+	return server
+}
+
+// outboxPublishers builds the publishers the outbox relay hands events to:
+// the webhook service plus the configured external publishers.
+func outboxPublishers(cfg config.OutboxConfiguration, webhookService *webhooks.Service) (jobs.OutboxPublishers, error) {
+	publishers := jobs.OutboxPublishers{"webhooks": webhookService}
+	for _, name := range cfg.Publishers {
+		switch name {
+		case "log":
+			publishers[name] = events.LogPublisher{}
+		case "http":
+			if cfg.HTTPURL == "" {
+				return nil, fmt.Errorf("OUTBOX_HTTP_URL is required by the http publisher")
+			}
+			publishers[name] = &events.HTTPPublisher{URL: cfg.HTTPURL, Client: &http.Client{Timeout: 10 * time.Second}}
+		case "memory":
+			publishers[name] = &events.MemoryPublisher{}
+		default:
+			return nil, fmt.Errorf("unknown outbox publisher %q", name)
+		}
+	}
+	return publishers, nil
 }
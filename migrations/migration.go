@@ -1,16 +1,48 @@
 package migrations
 
 import (
+	"context"
+	"fmt"
 	"gin-boilerplate/infra/database"
 	"gin-boilerplate/models"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+	"time"
 )
 
+// Version is the schema version this build expects. Bump it whenever the
+// migration models change, so readiness fails until the new schema is
+// migrated.
+const Version = 2
+
+// SchemaMigration records each schema version that was migrated.
+type SchemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	AppliedAt time.Time
+}
+
 // Migrate Add list of model add for migrations
 // TODO later separate migration each models
-func Migrate() {
-	var migrationModels = []interface{}{&models.Transaction{}}
+func Migrate() error {
+	var migrationModels = []interface{}{&models.Transaction{}, &models.TransactionAudit{}, &models.WebhookSubscription{}, &models.WebhookDelivery{}, &models.OutboxEvent{}, &SchemaMigration{}}
 	err := database.DB.AutoMigrate(migrationModels...)
 	if err != nil {
-		return
+		return err
+	}
+	return database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&SchemaMigration{Version: Version, AppliedAt: time.Now()}).Error
+}
+
+// CheckVersion fails when the master database has not been migrated to
+// Version yet.
+func CheckVersion(ctx context.Context) error {
+	var version int
+	err := database.DB.WithContext(ctx).Clauses(dbresolver.Write).Model(&SchemaMigration{}).
+		Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	if err != nil {
+		return err
+	}
+	if version < Version {
+		return fmt.Errorf("schema version %d, want %d", version, Version)
 	}
+	return nil
 }
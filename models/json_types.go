@@ -0,0 +1,84 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// StringList is a list of strings stored as a JSON array in a jsonb column.
+type StringList []string
+
+// Value implements driver.Valuer.
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		l = StringList{}
+	}
+	b, err := json.Marshal([]string(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (l *StringList) Scan(value interface{}) error {
+	data, err := scanBytes(value, "StringList")
+	if err != nil || data == nil {
+		*l = nil
+		return err
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+// JSONRaw is an already encoded JSON document stored in a jsonb column and
+// written out unchanged.
+type JSONRaw json.RawMessage
+
+// Value implements driver.Valuer.
+func (r JSONRaw) Value() (driver.Value, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return string(r), nil
+}
+
+// Scan implements sql.Scanner.
+func (r *JSONRaw) Scan(value interface{}) error {
+	data, err := scanBytes(value, "JSONRaw")
+	if err != nil {
+		return err
+	}
+	*r = append((*r)[:0], data...)
+	if data == nil {
+		*r = nil
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r JSONRaw) MarshalJSON() ([]byte, error) {
+	if r == nil {
+		return []byte("null"), nil
+	}
+	return r, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *JSONRaw) UnmarshalJSON(data []byte) error {
+	*r = append((*r)[:0], data...)
+	return nil
+}
+
+func scanBytes(value interface{}, name string) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("models: cannot scan %T into %s", value, name)
+	}
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboxEvent is an event recorded in the same database transaction as the
+// change it describes, waiting to be published by the relay. PublishedTo
+// names the publishers that already have it; the row is deleted once all of
+// them do and retried at NextAttemptAt otherwise. RequestID is the request
+// that made the change.
+type OutboxEvent struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	EventID       string     `json:"event_id" gorm:"uniqueIndex"`
+	Type          string     `json:"type"`
+	Payload       JSONRaw    `json:"payload" gorm:"type:jsonb"`
+	OccurredAt    time.Time  `json:"occurred_at"`
+	PublishedTo   StringList `json:"published_to" gorm:"type:jsonb"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index"`
+	LastError     string     `json:"last_error"`
+	RequestID     string     `json:"request_id"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (e *OutboxEvent) TableName() string {
+	return "outbox_events"
+}
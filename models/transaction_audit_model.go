@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// TransactionAudit records a change made to a transaction. Changes maps each
+// changed field to its {"from", "to"} values.
+type TransactionAudit struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	TransactionID uint      `json:"transaction_id" gorm:"index"`
+	Action        string    `json:"action"`
+	Changes       JSONMap   `json:"changes" gorm:"type:jsonb"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (e *TransactionAudit) TableName() string {
+	return "transaction_audits"
+}
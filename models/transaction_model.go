@@ -2,15 +2,22 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Transaction struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	UserID    int       `json:"user_id"`
-	Amount    int       `json:"amount"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	UserID      int            `json:"user_id"`
+	Amount      int            `json:"amount"`
+	Status      string         `json:"status"`
+	Description string         `json:"description"`
+	Reference   string         `json:"reference" gorm:"index"`
+	Metadata    JSONMap        `json:"metadata" gorm:"type:jsonb"`
+	Version     int            `json:"version" gorm:"not null;default:1"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
 
 func (e *Transaction) TableName() string {
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+)
+
+// WebhookSubscription is an endpoint that receives transaction events. An
+// empty Events list subscribes to every event type. Secret signs the
+// payloads and is only returned when the subscription is created.
+type WebhookSubscription struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	URL       string     `json:"url" gorm:"not null"`
+	Secret    string     `json:"secret,omitempty" gorm:"not null"`
+	Events    StringList `json:"events" gorm:"type:jsonb"`
+	Active    bool       `json:"active" gorm:"not null;default:true"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func (e *WebhookSubscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// Matches reports whether the subscription wants events of eventType.
+func (e *WebhookSubscription) Matches(eventType string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, event := range e.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is one event sent, or still to be sent, to a
+// subscription. Attempts counts the requests made so far; a pending delivery
+// is retried at NextAttemptAt. RequestID is the request that caused the
+// event, sent as X-Request-ID.
+type WebhookDelivery struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	SubscriptionID uint       `json:"subscription_id" gorm:"index"`
+	EventID        string     `json:"event_id" gorm:"index"`
+	Event          string     `json:"event"`
+	Payload        JSONRaw    `json:"payload" gorm:"type:jsonb"`
+	Status         string     `json:"status" gorm:"index"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index"`
+	ResponseStatus int        `json:"response_status"`
+	LastError      string     `json:"last_error"`
+	RequestID      string     `json:"request_id,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (e *WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
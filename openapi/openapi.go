@@ -0,0 +1,204 @@
+// Package openapi builds an OpenAPI 3 document from the routes registered on
+// a gin engine and the Go types of their request and response bodies. The
+// routes decide which paths exist; callers only describe each operation.
+package openapi
+
+import (
+	"sort"
+	"strings"
+)
+
+const Version = "3.0.3"
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// PathItem maps lower case HTTP methods to operations.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string              `json:"operationId,omitempty"`
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+	Deprecated  bool                `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+}
+
+// Route is a registered method and gin path, e.g. GET /transaction/:id.
+type Route struct {
+	Method string
+	Path   string
+}
+
+// Key identifies a route in the operations passed to Build.
+func (r Route) Key() string {
+	return r.Method + " " + r.Path
+}
+
+// Build documents every route with the operation keyed by Route.Key. Gin path
+// parameters become OpenAPI ones and are declared on the operation when it
+// does not declare them itself. Routes without an operation are still listed
+// and returned in undocumented; operations without a route are returned in
+// unused.
+func Build(info Info, routes []Route, operations map[string]*Operation, g *Generator) (doc *Document, undocumented, unused []string) {
+	doc = &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      map[string]PathItem{},
+		Components: Components{Schemas: g.Schemas()},
+	}
+
+	seen := map[string]bool{}
+	for _, route := range routes {
+		key := route.Key()
+		seen[key] = true
+
+		op, ok := operations[key]
+		if !ok {
+			undocumented = append(undocumented, key)
+			op = &Operation{Summary: "Undocumented", Responses: map[string]Response{"default": {Description: "Undocumented"}}}
+		}
+		op = withPathParameters(op, route.Path)
+
+		path := Path(route.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+	}
+
+	for key := range operations {
+		if !seen[key] {
+			unused = append(unused, key)
+		}
+	}
+	sort.Strings(undocumented)
+	sort.Strings(unused)
+	return doc, undocumented, unused
+}
+
+// Path converts a gin path such as /transaction/:id into /transaction/{id}.
+func Path(ginPath string) string {
+	segments := strings.Split(ginPath, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// withPathParameters returns op with a parameter for every path segment it
+// does not already describe. IDs are positive integers; other segments are
+// strings.
+func withPathParameters(op *Operation, ginPath string) *Operation {
+	var missing []Parameter
+	for _, segment := range strings.Split(ginPath, "/") {
+		if !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			continue
+		}
+		name := segment[1:]
+		if hasParameter(op, name, "path") {
+			continue
+		}
+		schema := &Schema{Type: "string"}
+		if name == "id" || strings.HasSuffix(name, "_id") {
+			schema = &Schema{Type: "integer", Minimum: Float(1)}
+		}
+		missing = append(missing, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	if len(missing) == 0 {
+		return op
+	}
+
+	copied := *op
+	copied.Parameters = append(missing, op.Parameters...)
+	return &copied
+}
+
+func hasParameter(op *Operation, name, in string) bool {
+	for _, p := range op.Parameters {
+		if p.Name == name && p.In == in {
+			return true
+		}
+	}
+	return false
+}
+
+// Float returns a pointer to f, for Minimum and Maximum.
+func Float(f float64) *float64 {
+	return &f
+}
+
+// Int returns a pointer to n, for the length and item limits.
+func Int(n int) *int {
+	return &n
+}
@@ -0,0 +1,54 @@
+package openapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type node struct {
+	Name     string     `json:"name" binding:"required,min=2"`
+	Tags     []string   `json:"tags" binding:"omitempty,max=5,dive,oneof=a b"`
+	Parent   *node      `json:"parent,omitempty"`
+	SeenAt   *time.Time `json:"seen_at"`
+	internal int
+}
+
+func TestGenerator_StructsAndBindings(t *testing.T) {
+	g := NewGenerator()
+	ref := g.Schema(node{})
+	assert.Equal(t, "#/components/schemas/node", ref.Ref)
+
+	schema := g.Schemas()["node"]
+	require.NotNil(t, schema)
+	assert.Equal(t, []string{"name"}, schema.Required)
+	assert.Equal(t, 2, *schema.Properties["name"].MinLength)
+	assert.Equal(t, 5, *schema.Properties["tags"].MaxItems)
+	assert.Equal(t, []interface{}{"a", "b"}, schema.Properties["tags"].Items.Enum)
+	assert.Equal(t, ref.Ref, schema.Properties["parent"].Ref)
+	assert.Equal(t, "date-time", schema.Properties["seen_at"].Format)
+	assert.True(t, schema.Properties["seen_at"].Nullable)
+	assert.NotContains(t, schema.Properties, "internal")
+}
+
+func TestBuild(t *testing.T) {
+	routes := []Route{
+		{Method: "GET", Path: "/items/:id"},
+		{Method: "DELETE", Path: "/items/:id"},
+	}
+	operations := map[string]*Operation{
+		"GET /items/:id":  {OperationID: "getItem"},
+		"POST /items/:id": {OperationID: "stale"},
+	}
+
+	doc, undocumented, unused := Build(Info{Title: "t", Version: "1"}, routes, operations, NewGenerator())
+	assert.Equal(t, []string{"DELETE /items/:id"}, undocumented)
+	assert.Equal(t, []string{"POST /items/:id"}, unused)
+
+	get := doc.Paths["/items/{id}"]["get"]
+	require.Len(t, get.Parameters, 1)
+	assert.Equal(t, Parameter{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "integer", Minimum: Float(1)}}, get.Parameters[0])
+	assert.Empty(t, operations["GET /items/:id"].Parameters, "Build must not modify the operations it is given")
+}
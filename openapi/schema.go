@@ -0,0 +1,246 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// Generator derives schemas from Go types. Named structs are added to the
+// document components once and referenced with $ref. Field names come from
+// json tags and constraints from gin binding tags (required, gt, gte, lt,
+// lte, min, max, oneof, http_url and dive).
+type Generator struct {
+	schemas   map[string]*Schema
+	names     map[reflect.Type]string
+	overrides map[reflect.Type]*Schema
+}
+
+func NewGenerator() *Generator {
+	return &Generator{
+		schemas:   map[string]*Schema{},
+		names:     map[reflect.Type]string{},
+		overrides: map[reflect.Type]*Schema{},
+	}
+}
+
+// Define sets the schema of the type of v, for types whose JSON form cannot
+// be derived from their Go structure, such as custom marshalers.
+func (g *Generator) Define(v interface{}, schema *Schema) {
+	g.overrides[reflect.TypeOf(v)] = schema
+}
+
+// Name sets the component name of the struct type of v, instead of its Go
+// type name.
+func (g *Generator) Name(v interface{}, name string) {
+	g.names[reflect.TypeOf(v)] = name
+}
+
+// Schemas returns the named schemas generated so far.
+func (g *Generator) Schemas() map[string]*Schema {
+	return g.schemas
+}
+
+// Schema returns the schema of the type of v.
+func (g *Generator) Schema(v interface{}) *Schema {
+	return g.schemaOf(reflect.TypeOf(v))
+}
+
+func (g *Generator) schemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	if override, ok := g.overrides[t]; ok {
+		copied := *override
+		return &copied
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() != reflect.Ptr && t.Implements(marshalerType):
+		// The Go structure says nothing about a custom JSON form.
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := g.schemaOf(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: Float(0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return g.structRef(t)
+	}
+	return &Schema{}
+}
+
+// structRef registers a named struct in the components and returns a
+// reference to it. Anonymous structs are inlined.
+func (g *Generator) structRef(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return g.structSchema(t)
+	}
+
+	name, ok := g.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := g.schemas[name]; taken {
+			pkg := pkgName(t)
+			name = strings.ToUpper(pkg[:1]) + pkg[1:] + t.Name()
+		}
+		g.names[t] = name
+	}
+	if _, ok := g.schemas[name]; !ok {
+		// Reserve the name first so recursive types terminate.
+		g.schemas[name] = &Schema{}
+		*g.schemas[name] = *g.structSchema(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func pkgName(t reflect.Type) string {
+	path := t.PkgPath()
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.addFields(schema, t)
+	return schema
+}
+
+func (g *Generator) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name := strings.SplitN(tag, ",", 2)[0]
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs without a json name are flattened like
+		// encoding/json does.
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(schema, embedded)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schemaOf(field.Type)
+		if applyBinding(property, field.Tag.Get("binding")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// applyBinding copies the validator rules of a binding tag onto schema and
+// reports whether the field is required. Rules after dive apply to the items
+// of a list.
+func applyBinding(schema *Schema, tag string) bool {
+	if tag == "" {
+		return false
+	}
+
+	required := false
+	target := schema
+	for _, rule := range strings.Split(tag, ",") {
+		name, param := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+
+		switch name {
+		case "required":
+			if target == schema {
+				required = true
+			}
+		case "dive":
+			if target.Items != nil {
+				target = target.Items
+			}
+		case "gt", "gte", "min":
+			applyLower(target, param, name == "gt")
+		case "lt", "lte", "max":
+			applyUpper(target, param, name == "lt")
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				target.Enum = append(target.Enum, value)
+			}
+		case "http_url", "url":
+			target.Format = "uri"
+		}
+	}
+	return required
+}
+
+func applyLower(schema *Schema, param string, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch schema.Type {
+	case "string":
+		schema.MinLength = Int(int(n))
+	case "array":
+		schema.MinItems = Int(int(n))
+	default:
+		schema.Minimum = Float(n)
+		schema.ExclusiveMinimum = exclusive
+	}
+}
+
+func applyUpper(schema *Schema, param string, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch schema.Type {
+	case "string":
+		schema.MaxLength = Int(int(n))
+	case "array":
+		schema.MaxItems = Int(int(n))
+	default:
+		schema.Maximum = Float(n)
+		schema.ExclusiveMaximum = exclusive
+	}
+}
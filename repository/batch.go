@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"errors"
+	"gin-boilerplate/events"
+	"gin-boilerplate/models"
+	"gorm.io/gorm"
+)
+
+// BatchOperation is a single create or status update inside ApplyBatch.
+// Exactly one of Create or UpdateID is set; a non-zero Version makes the
+// status update conditional.
+type BatchOperation struct {
+	Create   *models.Transaction
+	UpdateID int
+	Status   string
+	Version  int
+}
+
+// BatchResult is the outcome of the operation at the same index.
+// PreviousStatus is only set for status updates.
+type BatchResult struct {
+	Transaction    *models.Transaction
+	PreviousStatus string
+	Err            error
+}
+
+// ErrBatchRolledBack marks operations of an atomic batch that succeeded but
+// were rolled back because another operation failed.
+var ErrBatchRolledBack = errors.New("rolled back")
+
+// ApplyBatch runs ops in order. When atomic is true they share a single
+// database transaction: the first failure rolls everything back, is returned
+// as the error and the operations before it report ErrBatchRolledBack.
+// Otherwise every operation is committed in its own database transaction and
+// failures are only reported in the results.
+func (r *TransactionRepositoryImpl) ApplyBatch(ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ops))
+
+	if !atomic {
+		for i, op := range ops {
+			err := r.db().Transaction(func(tx *gorm.DB) error {
+				results[i] = applyBatchOperation(tx, op)
+				return results[i].Err
+			})
+			if err != nil && results[i].Err == nil {
+				// The commit itself failed.
+				results[i] = BatchResult{Err: dbError(err)}
+			}
+			r.notifyBatchResult(op, results[i])
+		}
+		return results, nil
+	}
+
+	err := r.db().Transaction(func(tx *gorm.DB) error {
+		for i, op := range ops {
+			results[i] = applyBatchOperation(tx, op)
+			if results[i].Err != nil {
+				for j := 0; j < i; j++ {
+					results[j] = BatchResult{Err: ErrBatchRolledBack}
+				}
+				return results[i].Err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return results, err
+	}
+	for i, result := range results {
+		r.notifyBatchResult(ops[i], result)
+	}
+	return results, nil
+}
+
+func (r *TransactionRepositoryImpl) notifyBatchResult(op BatchOperation, result BatchResult) {
+	switch {
+	case result.Err != nil:
+	case op.Create != nil:
+		r.notify(events.TransactionCreated, result.Transaction)
+	default:
+		r.notifyStatusChanged(result.Transaction, result.PreviousStatus)
+	}
+}
+
+func applyBatchOperation(db *gorm.DB, op BatchOperation) BatchResult {
+	if op.Create != nil {
+		if err := createTransactions(db, op.Create); err != nil {
+			return BatchResult{Err: err}
+		}
+		return BatchResult{Transaction: op.Create}
+	}
+
+	transaction, previous, err := updateStatus(db, op.UpdateID, op.Status, op.Version)
+	return BatchResult{Transaction: transaction, PreviousStatus: previous, Err: err}
+}
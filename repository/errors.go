@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"errors"
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/infra/database"
+
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrTransactionNotFound is returned when no live transaction has the given ID.
+	ErrTransactionNotFound = apperror.NotFound("transaction_not_found", "Transaction not found")
+
+	// ErrVersionConflict is returned when a conditional write does not match the
+	// transaction's current version.
+	ErrVersionConflict = apperror.PreconditionFailed("version_conflict", "Transaction was modified, reload and retry")
+
+	// ErrInvalidSort is returned when a listing is sorted by an unknown field.
+	ErrInvalidSort = apperror.Validation("invalid_sort", "Invalid sort field")
+
+	errDatabaseUnavailable = apperror.Unavailable("database_unavailable", "Database is unavailable")
+	errDatabase            = apperror.Internal("database_error", "Database error")
+)
+
+// dbError translates gorm and driver errors into domain errors so callers can
+// tell a missing row from a conflict or an outage.
+func dbError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		return err
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTransactionNotFound.Wrap(err)
+	}
+
+	if isUniqueViolation(err) {
+		return apperror.Conflict("duplicate_transaction", "Transaction already exists").Wrap(err)
+	}
+	// Also covers the open circuit breaker, which rejects statements while
+	// the database is down.
+	if database.IsUnavailable(err) {
+		return errDatabaseUnavailable.Wrap(err)
+	}
+	return errDatabase.Wrap(err)
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"encoding/json"
+	"gin-boilerplate/events"
+	"gin-boilerplate/infra/database"
+	"gin-boilerplate/infra/requestid"
+	"gin-boilerplate/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OutboxRepository interface {
+	ClaimOutbox(now time.Time, limit int, lease time.Duration) ([]models.OutboxEvent, error)
+	DeleteOutbox(id uint) error
+	RetryOutbox(id uint, publishedTo []string, attempts int, lastError string, nextAttemptAt time.Time) error
+}
+
+type OutboxRepositoryImpl struct{}
+
+// recordEvent adds an event to the outbox using tx, so it is committed or
+// rolled back together with the change it describes. The request ID of the
+// tx context is kept for the publishers.
+func recordEvent(tx *gorm.DB, eventType string, data interface{}) error {
+	event := events.New(eventType, data)
+	payload, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	return dbError(tx.Create(&models.OutboxEvent{
+		EventID:       event.ID,
+		Type:          event.Type,
+		Payload:       payload,
+		OccurredAt:    event.OccurredAt,
+		NextAttemptAt: event.OccurredAt,
+		RequestID:     requestid.FromContext(tx.Statement.Context),
+	}).Error)
+}
+
+// ClaimOutbox picks up to limit events due at now, oldest first, and pushes
+// their next attempt lease into the future so a concurrent relay skips them.
+// An event whose relay dies before deleting it is published again once the
+// lease runs out.
+func (r *OutboxRepositoryImpl) ClaimOutbox(now time.Time, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	var rows []models.OutboxEvent
+	err := database.DB.Raw(`UPDATE outbox_events SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE next_attempt_at <= ?
+			ORDER BY id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, now.Add(lease), now, limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, dbError(err)
+	}
+	// RETURNING does not keep the order of the subquery.
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+	return rows, nil
+}
+
+// DeleteOutbox removes a published event.
+func (r *OutboxRepositoryImpl) DeleteOutbox(id uint) error {
+	return dbError(database.DB.Delete(&models.OutboxEvent{}, id).Error)
+}
+
+// RetryOutbox records a failed publish, the publishers that did succeed and
+// when to try again.
+func (r *OutboxRepositoryImpl) RetryOutbox(id uint, publishedTo []string, attempts int, lastError string, nextAttemptAt time.Time) error {
+	return dbError(database.DB.Model(&models.OutboxEvent{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"published_to":    models.StringList(publishedTo),
+		"attempts":        attempts,
+		"last_error":      lastError,
+		"next_attempt_at": nextAttemptAt,
+	}).Error)
+}
@@ -1,9 +1,13 @@
 package repository
 
 import (
+	"context"
+	"gin-boilerplate/events"
 	"gin-boilerplate/infra/database"
+	"gin-boilerplate/infra/metrics"
 	"gin-boilerplate/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"time"
 )
 
@@ -17,6 +21,23 @@ type TransactionSummary struct {
 	TotalFailedTransactions   int     `json:"total_failed_transactions"`
 }
 
+// TransactionSort orders a transaction listing by one of
+// SortableTransactionFields.
+type TransactionSort struct {
+	Field string
+	Desc  bool
+}
+
+// SortableTransactionFields maps the sort fields clients may use to columns.
+var SortableTransactionFields = map[string]string{
+	"id":         "id",
+	"user_id":    "user_id",
+	"amount":     "amount",
+	"status":     "status",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
 type TransactionRepository interface {
 	GetTransactionByID(id int) (*models.Transaction, error)
 	CountSuccessToday() (int, error)
@@ -24,19 +45,85 @@ type TransactionRepository interface {
 	CountUniqueUsers() (int, error)
 	GetLatestTransactions(limit int) ([]models.Transaction, error)
 	GetTransactionSummary() (TransactionSummary, error)
-	UpdateTransactionStatus(id int, status string) (*models.Transaction, error)
-	DeleteTransactionByID(id int) error
+	GetUserTransactionSummary(userID int) (TransactionSummary, error)
+	UpdateTransactionStatus(id int, status string, version int) (*models.Transaction, error)
+	PatchTransaction(id int, version int, changes map[string]interface{}, audit *models.TransactionAudit) (*models.Transaction, error)
+	DeleteTransactionByID(id int, version int) error
+	RestoreTransactionByID(id int) (*models.Transaction, error)
+	PurgeDeletedBefore(cutoff time.Time) (int64, error)
 	Save(transaction *models.Transaction) error
-	GetTransactionsWithFilters(transactions *[]models.Transaction, pageNumber, pageSize int, status string, userID int) (int64, error)
+	SaveBatch(transactions []*models.Transaction) error
+	ApplyBatch(ops []BatchOperation, atomic bool) ([]BatchResult, error)
+	GetTransactionsWithFilters(transactions *[]models.Transaction, pageNumber, pageSize int, status string, userID int, includeDeleted bool) (int64, error)
+	GetSortedTransactionsWithFilters(transactions *[]models.Transaction, pageNumber, pageSize int, status string, userID int, includeDeleted bool, sort TransactionSort) (int64, error)
+	GetUserStats(userIDs []int) ([]UserStats, error)
+	StreamTransactionsWithFilters(status string, userID int, includeDeleted bool, limit int, fn func(*models.Transaction) error) error
+	// WithContext returns the repository with its queries bound to ctx, so
+	// they are traced under the caller's span and cancelled with it.
+	WithContext(ctx context.Context) TransactionRepository
 }
 
-type TransactionRepositoryImpl struct{}
+// TransactionRepositoryImpl stores transactions in the database. Every
+// create, status change and delete also records an event in the outbox, in
+// the same database transaction.
+//
+// Feed, when set, is handed every created, status changed or otherwise
+// updated transaction once committed. It is best effort and meant for live
+// views; the outbox is the durable record.
+type TransactionRepositoryImpl struct {
+	Feed events.Publisher
+
+	ctx context.Context
+}
+
+func (r *TransactionRepositoryImpl) WithContext(ctx context.Context) TransactionRepository {
+	bound := *r
+	bound.ctx = ctx
+	return &bound
+}
+
+func (r *TransactionRepositoryImpl) db() *gorm.DB {
+	if r.ctx == nil {
+		return database.DB
+	}
+	return database.DB.WithContext(r.ctx)
+}
+
+// notifyStatusChanged and notify run once a change is committed. Besides
+// feeding live views they count the change in the business metrics.
+func (r *TransactionRepositoryImpl) notifyStatusChanged(transaction *models.Transaction, previous string) {
+	// Setting the current status again is not a transition.
+	if previous != transaction.Status {
+		metrics.StatusTransitions.WithLabelValues(previous, transaction.Status).Inc()
+	}
+	if r.Feed == nil {
+		return
+	}
+	published := *transaction
+	_ = r.Feed.Publish(context.Background(), events.New(events.TransactionStatusChanged, events.StatusChanged{Transaction: &published, PreviousStatus: previous}))
+}
+
+func (r *TransactionRepositoryImpl) notify(eventType string, transactions ...*models.Transaction) {
+	if eventType == events.TransactionCreated {
+		for _, transaction := range transactions {
+			metrics.TransactionsCreated.WithLabelValues(transaction.Status).Inc()
+		}
+	}
+	if r.Feed == nil {
+		return
+	}
+	for _, transaction := range transactions {
+		// Subscribers read the copy concurrently with the caller using the original.
+		published := *transaction
+		_ = r.Feed.Publish(context.Background(), events.New(eventType, &published))
+	}
+}
 
 func (r *TransactionRepositoryImpl) GetTransactionByID(id int) (*models.Transaction, error) {
 	var transaction models.Transaction
-	err := database.DB.First(&transaction, id).Error
+	err := r.db().First(&transaction, id).Error
 	if err != nil {
-		return nil, err
+		return nil, dbError(err)
 	}
 	return &transaction, nil
 }
@@ -44,91 +131,319 @@ func (r *TransactionRepositoryImpl) GetTransactionByID(id int) (*models.Transact
 func (r *TransactionRepositoryImpl) CountSuccessToday() (int, error) {
 	var count int64
 	today := time.Now().Format("2006-01-02")
-	err := database.DB.Model(&models.Transaction{}).
+	err := r.db().Model(&models.Transaction{}).
 		Where("status = ? AND DATE(created_at) = ?", "success", today).
 		Count(&count).Error
-	return int(count), err
+	return int(count), dbError(err)
 }
 
 func (r *TransactionRepositoryImpl) CountTotalTransactions() (int, error) {
 	var count int64
-	err := database.DB.Model(&models.Transaction{}).Count(&count).Error
-	return int(count), err
+	err := r.db().Model(&models.Transaction{}).Count(&count).Error
+	return int(count), dbError(err)
 }
 
 func (r *TransactionRepositoryImpl) CountUniqueUsers() (int, error) {
 	var count int64
-	err := database.DB.Model(&models.Transaction{}).Distinct("user_id").Count(&count).Error
-	return int(count), err
+	err := r.db().Model(&models.Transaction{}).Distinct("user_id").Count(&count).Error
+	return int(count), dbError(err)
+}
+
+// HasTransactionsBefore reports whether the user has transactions with an ID
+// below id.
+func (r *TransactionRepositoryImpl) HasTransactionsBefore(userID int, id uint) (bool, error) {
+	return r.hasTransactions("user_id = ? AND id < ?", userID, id)
+}
+
+// HasTransactionsBesides reports whether the user has transactions other than id.
+func (r *TransactionRepositoryImpl) HasTransactionsBesides(userID int, id uint) (bool, error) {
+	return r.hasTransactions("user_id = ? AND id <> ?", userID, id)
+}
+
+func (r *TransactionRepositoryImpl) hasTransactions(query string, args ...interface{}) (bool, error) {
+	var ids []uint
+	err := r.db().Model(&models.Transaction{}).Where(query, args...).Limit(1).Pluck("id", &ids).Error
+	return len(ids) > 0, dbError(err)
 }
 
 func (r *TransactionRepositoryImpl) GetLatestTransactions(limit int) ([]models.Transaction, error) {
 	var transactions []models.Transaction
-	err := database.DB.Order("created_at DESC").Limit(limit).Find(&transactions).Error
-	return transactions, err
+	err := r.db().Order("created_at DESC").Limit(limit).Find(&transactions).Error
+	return transactions, dbError(err)
 }
 
 func (r *TransactionRepositoryImpl) GetTransactionSummary() (TransactionSummary, error) {
+	return transactionSummary(func() *gorm.DB {
+		return r.db().Model(&models.Transaction{})
+	})
+}
+
+// GetUserTransactionSummary is GetTransactionSummary limited to the
+// transactions of one user.
+func (r *TransactionRepositoryImpl) GetUserTransactionSummary(userID int) (TransactionSummary, error) {
+	return transactionSummary(func() *gorm.DB {
+		return r.db().Model(&models.Transaction{}).Where("user_id = ?", userID)
+	})
+}
+
+func transactionSummary(base func() *gorm.DB) (TransactionSummary, error) {
 	var summary TransactionSummary
 	var totalToday, totalTransactions, uniqueUsers, totalPending, totalSuccess, totalFailed int64
 
 	today := time.Now().Format("2006-01-02")
-	database.DB.Model(&models.Transaction{}).Where("DATE(created_at) = ?", today).Count(&totalToday)
-	database.DB.Model(&models.Transaction{}).Count(&totalTransactions)
-	database.DB.Model(&models.Transaction{}).Distinct("user_id").Count(&uniqueUsers)
-	database.DB.Model(&models.Transaction{}).Where("status = ?", "pending").Count(&totalPending)
-	database.DB.Model(&models.Transaction{}).Where("status = ?", "success").Count(&totalSuccess)
-	database.DB.Model(&models.Transaction{}).Where("status = ?", "failed").Count(&totalFailed)
-
-	averageTransactionPerUser := 0.0
-	if uniqueUsers > 0 {
-		averageTransactionPerUser = float64(totalTransactions) / float64(uniqueUsers)
+	counts := []struct {
+		query *gorm.DB
+		dest  *int64
+	}{
+		{base().Where("DATE(created_at) = ?", today), &totalToday},
+		{base(), &totalTransactions},
+		{base().Distinct("user_id"), &uniqueUsers},
+		{base().Where("status = ?", "pending"), &totalPending},
+		{base().Where("status = ?", "success"), &totalSuccess},
+		{base().Where("status = ?", "failed"), &totalFailed},
+	}
+	for _, c := range counts {
+		if err := c.query.Count(c.dest).Error; err != nil {
+			return summary, dbError(err)
+		}
 	}
 
 	summary = TransactionSummary{
-		TotalTransactionsToday:    int(totalToday),
-		AverageTransactionPerUser: averageTransactionPerUser,
-		TotalTransactions:         int(totalTransactions),
-		UniqueUsers:                int(uniqueUsers),
-		TotalPendingTransactions:  int(totalPending),
-		TotalSuccessTransactions:  int(totalSuccess),
-		TotalFailedTransactions:   int(totalFailed),
+		TotalTransactionsToday:   int(totalToday),
+		TotalTransactions:        int(totalTransactions),
+		UniqueUsers:              int(uniqueUsers),
+		TotalPendingTransactions: int(totalPending),
+		TotalSuccessTransactions: int(totalSuccess),
+		TotalFailedTransactions:  int(totalFailed),
 	}
+	summary.AverageTransactionPerUser = summary.averagePerUser()
 
 	return summary, nil
 }
 
-func (r *TransactionRepositoryImpl) UpdateTransactionStatus(id int, status string) (*models.Transaction, error) {
-	var transaction models.Transaction
-	err := database.DB.First(&transaction, id).Error
+// averagePerUser derives AverageTransactionPerUser from the counts.
+func (s TransactionSummary) averagePerUser() float64 {
+	if s.UniqueUsers == 0 {
+		return 0
+	}
+	return float64(s.TotalTransactions) / float64(s.UniqueUsers)
+}
+
+// UpdateTransactionStatus sets the status and bumps the version in a single
+// statement. A non-zero version makes the update conditional on it.
+func (r *TransactionRepositoryImpl) UpdateTransactionStatus(id int, status string, version int) (*models.Transaction, error) {
+	var transaction *models.Transaction
+	var previous string
+	err := r.db().Transaction(func(tx *gorm.DB) error {
+		var err error
+		transaction, previous, err = updateStatus(tx, id, status, version)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	r.notifyStatusChanged(transaction, previous)
+	return transaction, nil
+}
 
-	transaction.Status = status
-	err = database.DB.Save(&transaction).Error
+// statusUpdate is a row returned by updateStatus.
+type statusUpdate struct {
+	models.Transaction
+	PreviousStatus string
+}
+
+// updateStatus updates the transaction and records the status change in the
+// outbox. It also returns the status it replaced; the row is locked by the
+// subquery, so that is the one this update actually overwrote.
+func updateStatus(db *gorm.DB, id int, status string, version int) (*models.Transaction, string, error) {
+	sql := `UPDATE transactions AS t
+		SET status = ?, version = t.version + 1, updated_at = ?
+		FROM (SELECT id, status FROM transactions WHERE id = ? AND deleted_at IS NULL FOR UPDATE) AS old
+		WHERE t.id = old.id`
+	args := []interface{}{status, time.Now(), id}
+	if version != 0 {
+		sql += " AND t.version = ?"
+		args = append(args, version)
+	}
+	sql += " RETURNING t.*, old.status AS previous_status"
+
+	var rows []statusUpdate
+	if err := db.Raw(sql, args...).Scan(&rows).Error; err != nil {
+		return nil, "", dbError(err)
+	}
+	if len(rows) == 0 {
+		return nil, "", missingOrConflict(db, id)
+	}
+
+	transaction, previous := &rows[0].Transaction, rows[0].PreviousStatus
+	err := recordEvent(db, events.TransactionStatusChanged, events.StatusChanged{Transaction: transaction, PreviousStatus: previous})
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	return transaction, previous, nil
+}
+
+// PatchTransaction applies changes to the transaction if it is still at
+// version, recording audit in the same database transaction.
+func (r *TransactionRepositoryImpl) PatchTransaction(id int, version int, changes map[string]interface{}, audit *models.TransactionAudit) (*models.Transaction, error) {
+	var transaction models.Transaction
+	err := r.db().Transaction(func(tx *gorm.DB) error {
+		updates := make(map[string]interface{}, len(changes)+1)
+		for column, value := range changes {
+			updates[column] = value
+		}
+		updates["version"] = gorm.Expr("version + 1")
+
+		result := tx.Model(&transaction).Clauses(clause.Returning{}).
+			Where("id = ? AND version = ?", id, version).
+			Updates(updates)
+		if result.Error != nil {
+			return dbError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return missingOrConflict(tx, id)
+		}
 
+		audit.TransactionID = transaction.ID
+		return dbError(tx.Create(audit).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.notify(events.TransactionUpdated, &transaction)
 	return &transaction, nil
 }
 
-func (r *TransactionRepositoryImpl) DeleteTransactionByID(id int) error {
-	result := database.DB.Delete(&models.Transaction{}, id)
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+// DeleteTransactionByID soft deletes a transaction and bumps its version, so
+// ETags taken before the delete stop matching. A non-zero version makes the
+// delete conditional on it.
+func (r *TransactionRepositoryImpl) DeleteTransactionByID(id int, version int) error {
+	var transaction models.Transaction
+	err := r.db().Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&models.Transaction{}).Where("id = ?", id)
+		if version != 0 {
+			query = query.Where("version = ?", version)
+		}
+
+		result := query.Updates(map[string]interface{}{
+			"deleted_at": time.Now(),
+			"version":    gorm.Expr("version + 1"),
+		})
+		if result.Error != nil {
+			return dbError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return missingOrConflict(tx, id)
+		}
+		if err := tx.Unscoped().First(&transaction, id).Error; err != nil {
+			return dbError(err)
+		}
+		return recordEvent(tx, events.TransactionDeleted, events.Deleted{ID: uint(id)})
+	})
+	if err != nil {
+		return err
 	}
-	return result.Error
+	r.notify(events.TransactionDeleted, &transaction)
+	return nil
+}
+
+// missingOrConflict tells apart a conditional write that matched nothing
+// because the row is gone from one that lost against a newer version.
+func missingOrConflict(db *gorm.DB, id int) error {
+	var count int64
+	if err := db.Model(&models.Transaction{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return dbError(err)
+	}
+	if count == 0 {
+		return ErrTransactionNotFound
+	}
+	return ErrVersionConflict
+}
+
+// RestoreTransactionByID clears deleted_at on a soft deleted transaction and
+// bumps its version.
+func (r *TransactionRepositoryImpl) RestoreTransactionByID(id int) (*models.Transaction, error) {
+	var transaction models.Transaction
+	err := r.db().Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(&models.Transaction{}).
+			Where("id = ? AND deleted_at IS NOT NULL", id).
+			Updates(map[string]interface{}{
+				"deleted_at": nil,
+				"version":    gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return dbError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrTransactionNotFound
+		}
+		if err := tx.First(&transaction, id).Error; err != nil {
+			return dbError(err)
+		}
+		return recordEvent(tx, events.TransactionRestored, &transaction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.notify(events.TransactionRestored, &transaction)
+	return &transaction, nil
+}
+
+// PurgeDeletedBefore permanently removes transactions soft deleted before cutoff.
+func (r *TransactionRepositoryImpl) PurgeDeletedBefore(cutoff time.Time) (int64, error) {
+	result := r.db().Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Delete(&models.Transaction{})
+	return result.RowsAffected, dbError(result.Error)
 }
 
 func (r *TransactionRepositoryImpl) Save(transaction *models.Transaction) error {
-	return database.DB.Create(transaction).Error
+	err := r.db().Transaction(func(tx *gorm.DB) error {
+		return createTransactions(tx, transaction)
+	})
+	if err != nil {
+		return err
+	}
+	r.notify(events.TransactionCreated, transaction)
+	return nil
 }
 
-func (r *TransactionRepositoryImpl) GetTransactionsWithFilters(transactions *[]models.Transaction, pageNumber, pageSize int, status string, userID int) (int64, error) {
-	var totalRecordCount int64
-	query := database.DB.Model(&models.Transaction{})
+// SaveBatch inserts transactions in a single database transaction, so either
+// all of them are stored or none are.
+func (r *TransactionRepositoryImpl) SaveBatch(transactions []*models.Transaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+	err := r.db().Transaction(func(tx *gorm.DB) error {
+		return createTransactions(tx, transactions...)
+	})
+	if err != nil {
+		return err
+	}
+	r.notify(events.TransactionCreated, transactions...)
+	return nil
+}
+
+// createTransactions inserts transactions and records their created events.
+func createTransactions(tx *gorm.DB, transactions ...*models.Transaction) error {
+	if err := tx.Create(&transactions).Error; err != nil {
+		return dbError(err)
+	}
+	for _, transaction := range transactions {
+		if err := recordEvent(tx, events.TransactionCreated, transaction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterQuery builds the query shared by listing and export.
+func filterQuery(db *gorm.DB, status string, userID int, includeDeleted bool) *gorm.DB {
+	query := db.Model(&models.Transaction{})
+
+	if includeDeleted {
+		query = query.Unscoped()
+	}
 
 	if status != "" {
 		query = query.Where("status = ?", status)
@@ -137,12 +452,65 @@ func (r *TransactionRepositoryImpl) GetTransactionsWithFilters(transactions *[]m
 	if userID != 0 {
 		query = query.Where("user_id = ?", userID)
 	}
+	return query
+}
+
+func (r *TransactionRepositoryImpl) GetTransactionsWithFilters(transactions *[]models.Transaction, pageNumber, pageSize int, status string, userID int, includeDeleted bool) (int64, error) {
+	return r.GetSortedTransactionsWithFilters(transactions, pageNumber, pageSize, status, userID, includeDeleted, TransactionSort{})
+}
+
+// GetSortedTransactionsWithFilters is GetTransactionsWithFilters ordered by
+// sort, with id as the tie breaker so pages do not overlap. A zero sort
+// keeps the database order.
+func (r *TransactionRepositoryImpl) GetSortedTransactionsWithFilters(transactions *[]models.Transaction, pageNumber, pageSize int, status string, userID int, includeDeleted bool, sort TransactionSort) (int64, error) {
+	var totalRecordCount int64
+	query := filterQuery(r.db(), status, userID, includeDeleted)
 
 	err := query.Count(&totalRecordCount).Error
 	if err != nil {
-		return 0, err
+		return 0, dbError(err)
+	}
+
+	if sort.Field != "" {
+		column, ok := SortableTransactionFields[sort.Field]
+		if !ok {
+			return 0, ErrInvalidSort
+		}
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: sort.Desc})
+		if column != "id" {
+			query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: sort.Desc})
+		}
 	}
+
 	offset := (pageNumber - 1) * pageSize
 	err = query.Limit(pageSize).Offset(offset).Find(transactions).Error
-	return totalRecordCount, err
+	return totalRecordCount, dbError(err)
+}
+
+// StreamTransactionsWithFilters calls fn for each transaction matching the
+// filters, in id order, reading from a database cursor instead of loading
+// the result set. A limit of 0 means no limit. Iteration stops at the first
+// error returned by fn.
+func (r *TransactionRepositoryImpl) StreamTransactionsWithFilters(status string, userID int, includeDeleted bool, limit int, fn func(*models.Transaction) error) error {
+	query := filterQuery(r.db(), status, userID, includeDeleted).Order("id")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		return dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transaction models.Transaction
+		if err := r.db().ScanRows(rows, &transaction); err != nil {
+			return dbError(err)
+		}
+		if err := fn(&transaction); err != nil {
+			return err
+		}
+	}
+	return dbError(rows.Err())
 }
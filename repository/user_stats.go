@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"gin-boilerplate/models"
+	"time"
+)
+
+// UserStats aggregates the live transactions of one user.
+type UserStats struct {
+	UserID                   int        `json:"user_id"`
+	TotalTransactions        int        `json:"total_transactions"`
+	TotalAmount              int64      `json:"total_amount"`
+	TotalPendingTransactions int        `json:"total_pending_transactions"`
+	TotalSuccessTransactions int        `json:"total_success_transactions"`
+	TotalFailedTransactions  int        `json:"total_failed_transactions"`
+	LastTransactionAt        *time.Time `json:"last_transaction_at"`
+}
+
+// GetUserStats returns the stats of every given user that has transactions,
+// in one grouped query. Users without transactions are left out.
+func (r *TransactionRepositoryImpl) GetUserStats(userIDs []int) ([]UserStats, error) {
+	var stats []UserStats
+	if len(userIDs) == 0 {
+		return stats, nil
+	}
+
+	err := r.db().Model(&models.Transaction{}).
+		Select(`user_id,
+			COUNT(*) AS total_transactions,
+			COALESCE(SUM(amount), 0) AS total_amount,
+			COUNT(*) FILTER (WHERE status = 'pending') AS total_pending_transactions,
+			COUNT(*) FILTER (WHERE status = 'success') AS total_success_transactions,
+			COUNT(*) FILTER (WHERE status = 'failed') AS total_failed_transactions,
+			MAX(created_at) AS last_transaction_at`).
+		Where("user_id IN ?", userIDs).
+		Group("user_id").
+		Order("user_id").
+		Scan(&stats).Error
+	return stats, dbError(err)
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/infra/database"
+	"gin-boilerplate/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrWebhookNotFound is returned when no subscription has the given ID.
+	ErrWebhookNotFound = apperror.NotFound("webhook_not_found", "Webhook subscription not found")
+
+	// ErrDeliveryNotFound is returned when the subscription has no delivery with the given ID.
+	ErrDeliveryNotFound = apperror.NotFound("delivery_not_found", "Webhook delivery not found")
+
+	// ErrDuplicateWebhook is returned when a subscription clashes with a
+	// unique constraint.
+	ErrDuplicateWebhook = apperror.Conflict("duplicate_webhook", "Webhook subscription already exists")
+)
+
+type WebhookRepository interface {
+	CreateSubscription(subscription *models.WebhookSubscription) error
+	GetSubscriptionByID(id int) (*models.WebhookSubscription, error)
+	ListSubscriptions() ([]models.WebhookSubscription, error)
+	ListActiveSubscriptions() ([]models.WebhookSubscription, error)
+	UpdateSubscription(subscription *models.WebhookSubscription) error
+	DeleteSubscriptionByID(id int) error
+	CreateDeliveries(deliveries []*models.WebhookDelivery) error
+	GetDelivery(subscriptionID, id int) (*models.WebhookDelivery, error)
+	ListDeliveries(deliveries *[]models.WebhookDelivery, subscriptionID, pageNumber, pageSize int) (int64, error)
+	ClaimDueDeliveries(now time.Time, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	SaveDelivery(delivery *models.WebhookDelivery) error
+	// WithContext returns the repository with its queries bound to ctx, so
+	// they are traced under the caller's span and cancelled with it.
+	WithContext(ctx context.Context) WebhookRepository
+}
+
+type WebhookRepositoryImpl struct {
+	ctx context.Context
+}
+
+func (r *WebhookRepositoryImpl) WithContext(ctx context.Context) WebhookRepository {
+	bound := *r
+	bound.ctx = ctx
+	return &bound
+}
+
+func (r *WebhookRepositoryImpl) db() *gorm.DB {
+	if r.ctx == nil {
+		return database.DB
+	}
+	return database.DB.WithContext(r.ctx)
+}
+
+// notFound maps a missing row to err and anything else through dbError.
+func notFound(err error, notFoundErr *apperror.Error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return notFoundErr.Wrap(err)
+	}
+	return dbError(err)
+}
+
+// subscriptionError maps a unique violation on a subscription write to
+// ErrDuplicateWebhook and anything else through dbError.
+func subscriptionError(err error) error {
+	if isUniqueViolation(err) {
+		return ErrDuplicateWebhook.Wrap(err)
+	}
+	return dbError(err)
+}
+
+func (r *WebhookRepositoryImpl) CreateSubscription(subscription *models.WebhookSubscription) error {
+	return subscriptionError(r.db().Create(subscription).Error)
+}
+
+func (r *WebhookRepositoryImpl) GetSubscriptionByID(id int) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	if err := r.db().First(&subscription, id).Error; err != nil {
+		return nil, notFound(err, ErrWebhookNotFound)
+	}
+	return &subscription, nil
+}
+
+func (r *WebhookRepositoryImpl) ListSubscriptions() ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	err := r.db().Order("id").Find(&subscriptions).Error
+	return subscriptions, dbError(err)
+}
+
+func (r *WebhookRepositoryImpl) ListActiveSubscriptions() ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	err := r.db().Where("active = ?", true).Order("id").Find(&subscriptions).Error
+	return subscriptions, dbError(err)
+}
+
+// UpdateSubscription saves the url, events and active flag. The secret is
+// never changed after creation.
+func (r *WebhookRepositoryImpl) UpdateSubscription(subscription *models.WebhookSubscription) error {
+	result := r.db().Model(subscription).Clauses(clause.Returning{}).
+		Select("url", "events", "active").
+		Updates(subscription)
+	if result.Error != nil {
+		return subscriptionError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// DeleteSubscriptionByID removes the subscription along with its delivery log.
+func (r *WebhookRepositoryImpl) DeleteSubscriptionByID(id int) error {
+	return r.db().Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.WebhookSubscription{}, id)
+		if result.Error != nil {
+			return dbError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrWebhookNotFound
+		}
+		return dbError(tx.Where("subscription_id = ?", id).Delete(&models.WebhookDelivery{}).Error)
+	})
+}
+
+func (r *WebhookRepositoryImpl) CreateDeliveries(deliveries []*models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return dbError(r.db().Create(&deliveries).Error)
+}
+
+func (r *WebhookRepositoryImpl) GetDelivery(subscriptionID, id int) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := r.db().Where("subscription_id = ?", subscriptionID).First(&delivery, id).Error
+	if err != nil {
+		return nil, notFound(err, ErrDeliveryNotFound)
+	}
+	return &delivery, nil
+}
+
+// ListDeliveries returns a page of the subscription's deliveries, newest first.
+func (r *WebhookRepositoryImpl) ListDeliveries(deliveries *[]models.WebhookDelivery, subscriptionID, pageNumber, pageSize int) (int64, error) {
+	var total int64
+	query := r.db().Model(&models.WebhookDelivery{}).Where("subscription_id = ?", subscriptionID)
+	if err := query.Count(&total).Error; err != nil {
+		return 0, dbError(err)
+	}
+	offset := (pageNumber - 1) * pageSize
+	err := query.Order("id DESC").Limit(pageSize).Offset(offset).Find(deliveries).Error
+	return total, dbError(err)
+}
+
+// ClaimDueDeliveries picks up to limit pending deliveries due at now and
+// pushes their next attempt lease into the future, so another worker does
+// not pick them up while they are being sent. Rows locked by a concurrent
+// claim are skipped.
+func (r *WebhookRepositoryImpl) ClaimDueDeliveries(now time.Time, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := r.db().Raw(`UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, now.Add(lease), models.DeliveryPending, now, limit).
+		Scan(&deliveries).Error
+	return deliveries, dbError(err)
+}
+
+func (r *WebhookRepositoryImpl) SaveDelivery(delivery *models.WebhookDelivery) error {
+	return dbError(r.db().Save(delivery).Error)
+}
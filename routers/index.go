@@ -1,35 +1,81 @@
 package routers
 
 import (
-	"gin-boilerplate/controllers"
+	"context"
+	"errors"
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/config"
+	"gin-boilerplate/events"
+	"gin-boilerplate/helpers"
+	"gin-boilerplate/infra/health"
+	"gin-boilerplate/infra/lifecycle"
+	"gin-boilerplate/infra/metrics"
 	"gin-boilerplate/repository"
+	"gin-boilerplate/routers/middleware"
+	"gin-boilerplate/webhooks"
 
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
 
+// Services are the long lived components shared by the routes and the
+// background workers started in main, and the configuration they run with.
+type Services struct {
+	Config       config.Configuration
+	Transactions repository.TransactionRepository
+	Webhooks     *webhooks.Service
+	// Live is the transaction feed and DashboardChanges the dashboard.Tracker
+	// changes derived from it.
+	Live             *events.Hub
+	DashboardChanges *events.Hub
+	// Lifecycle reports readiness on /readyz and ends streams on shutdown.
+	Lifecycle *lifecycle.State
+	// Readiness are the dependencies /readyz checks besides Lifecycle.
+	Readiness []health.Check
+}
+
+var errNotReady = errors.New("starting or shutting down")
+
+// apiV1Prefix is where API v1 is served. The same routes are also served
+// without a prefix, as deprecated aliases, while LEGACY_ROUTES_ENABLED is set.
+const apiV1Prefix = "/api/v1"
+
 // RegisterRoutes add all routing list here automatically get main router
-func RegisterRoutes(route *gin.Engine) {
+func RegisterRoutes(route *gin.Engine, services Services) {
 	route.NoRoute(func(ctx *gin.Context) {
-		ctx.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "message": "Route Not Found"})
+		helpers.Problem(ctx, apperror.NotFound("route_not_found", "Route Not Found"))
 	})
 
-	// Inisialisasi Repository dan Controller
-	transactionRepo := &repository.TransactionRepositoryImpl{}
-	transactionController := &controllers.TransactionController{
-		Repo: transactionRepo,
+	healthz := func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
 	}
+	route.GET("/healthz", healthz)
+	route.GET("/health", healthz)
 
-	route.GET("/health", func(ctx *gin.Context) {
-		ctx.JSON(http.StatusOK, gin.H{"live": "sip ss sudahh runningg"})
+	readiness := append([]health.Check{{Name: "server", Run: func(context.Context) error {
+		if !services.Lifecycle.Ready() {
+			return errNotReady
+		}
+		return nil
+	}}}, services.Readiness...)
+	readinessTimeout := services.Config.Server.ReadinessTimeout
+	route.GET("/readyz", func(ctx *gin.Context) {
+		report := health.Run(ctx.Request.Context(), readinessTimeout, readiness)
+		status := http.StatusOK
+		if !report.OK() {
+			status = http.StatusServiceUnavailable
+		}
+		ctx.JSON(status, report)
 	})
 
-	// Add All route
-	route.GET("/transaction", transactionController.GetTransactions)
-	route.GET("/transaction/:id", transactionController.GetTransactionByID)
-	route.GET("/dashboard/summary", transactionController.GetDashboardSummary)
-	route.DELETE("/transaction/:id", transactionController.DeleteTransaction)
-	route.PUT("/transaction/:id", transactionController.UpdateTransactionStatus)
-	route.POST("/transaction", transactionController.CreateTransaction)
-	route.GET("/dashboard/report", transactionController.GetDashboardReport)
+	route.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	registerV1 := v1Routes(services)
+	registerV1(route.Group(apiV1Prefix))
+	legacy := services.Config.LegacyRoutes
+	if legacy.Enabled {
+		registerV1(route.Group("/", middleware.Deprecated(apiV1Prefix, legacy.DeprecatedAt, legacy.Sunset)))
+	}
+
+	serveOpenAPI(route, legacy)
 }
@@ -0,0 +1,100 @@
+package routers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gin-boilerplate/config"
+	"gin-boilerplate/infra/health"
+	"gin-boilerplate/infra/lifecycle"
+	"gin-boilerplate/webhooks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegacyRoutes_AreDeprecatedAliases(t *testing.T) {
+	router := newTestRouter()
+
+	// An invalid id is rejected before the repository is used, so both
+	// routes answer without a database.
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/transaction/abc", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/transaction/abc", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "@1792368000", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Fri, 30 Apr 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `</api/v1/transaction/abc>; rel="successor-version"`, w.Header().Get("Link"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestReadyz_ReportsChecks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	state := lifecycle.New()
+	var replicaErr error
+	router := gin.New()
+	RegisterRoutes(router, Services{
+		Config:    config.Default(),
+		Webhooks:  &webhooks.Service{},
+		Lifecycle: state,
+		Readiness: []health.Check{
+			{Name: "master", Run: func(context.Context) error { return nil }},
+			{Name: "replica_1", Run: func(context.Context) error { return replicaErr }},
+		},
+	})
+
+	ready := func() (int, health.Report) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/readyz", nil)
+		router.ServeHTTP(w, req)
+		var report health.Report
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		return w.Code, report
+	}
+
+	code, report := ready()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusFail, report.Checks["server"].Status)
+	assert.Equal(t, health.StatusOK, report.Checks["master"].Status)
+
+	state.SetReady(true)
+	code, report = ready()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, report.Checks, 3)
+
+	replicaErr = errors.New(`failed to connect to host=db.internal user=app database=transactions`)
+	code, report = ready()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.Result{Status: health.StatusFail, LatencyMS: report.Checks["replica_1"].LatencyMS, Error: health.ErrorUnavailable}, report.Checks["replica_1"])
+
+	replicaErr = nil
+	state.Close()
+	code, _ = ready()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+}
+
+func TestHealthz(t *testing.T) {
+	router := newTestRouter()
+	for _, path := range []string{"/healthz", "/health"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.JSONEq(t, `{"status":"ok"}`, w.Body.String(), path)
+	}
+}
@@ -1,17 +1,33 @@
 package middleware
 
 import (
+	"gin-boilerplate/helpers"
 	"github.com/gin-gonic/gin"
 	"log"
 )
 
-func CORSMiddleware() gin.HandlerFunc {
+// CORSMiddleware allows cross-origin requests from allowedOrigins, or from
+// every origin when it is empty or holds "*". Browsers send no credentials
+// to a "*" origin.
+func CORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
+	anyOrigin := len(allowedOrigins) == 0
+	for _, origin := range allowedOrigins {
+		anyOrigin = anyOrigin || origin == "*"
+	}
+
 	return func(ctx *gin.Context) {
-		ctx.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		if anyOrigin {
+			ctx.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			ctx.Writer.Header().Add("Vary", "Origin")
+			if origin := ctx.GetHeader("Origin"); origin != "" && helpers.OriginAllowed(allowedOrigins, origin) {
+				ctx.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+		}
 		ctx.Writer.Header().Set("Access-Control-Max-Age", "86400")
-		ctx.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
-		ctx.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, api_key, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-		ctx.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
+		ctx.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE, UPDATE")
+		ctx.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, api_key, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, If-Match, Last-Event-ID, X-Request-ID")
+		ctx.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length, ETag, Deprecation, Sunset, Link, X-Request-ID")
 		ctx.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		ctx.Writer.Header().Set("Cache-Control", "no-cache")
 
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSMiddleware_AllowedOrigins(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CORSMiddleware([]string{"https://dashboard.example.com"}))
+	router.GET("/ping", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	for origin, want := range map[string]string{
+		"https://dashboard.example.com": "https://dashboard.example.com",
+		"https://evil.example.com":      "",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set("Origin", origin)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, want, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		assert.Equal(t, "Origin", w.Header().Get("Vary"))
+	}
+}
+
+func TestCORSMiddleware_AnyOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CORSMiddleware(nil))
+	router.GET("/ping", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("Origin", "https://anywhere.example.com")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSMiddleware_RequestIDHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CORSMiddleware(nil))
+	router.GET("/ping", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodOptions, "/ping", nil)
+	req.Header.Set("Origin", "https://anywhere.example.com")
+	req.Header.Set("Access-Control-Request-Headers", "X-Request-ID")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "X-Request-ID")
+	assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID")
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// Deprecated marks every route of a group as a deprecated alias of the same
+// path under successorPrefix. Responses, errors included, carry a Deprecation
+// header (RFC 9745), a Sunset header (RFC 8594) when sunset is set and a
+// successor-version Link.
+func Deprecated(successorPrefix string, deprecatedAt, sunset time.Time) gin.HandlerFunc {
+	deprecation := "true"
+	if !deprecatedAt.IsZero() {
+		deprecation = fmt.Sprintf("@%d", deprecatedAt.Unix())
+	}
+	var sunsetHeader string
+	if !sunset.IsZero() {
+		sunsetHeader = sunset.UTC().Format(http.TimeFormat)
+	}
+
+	return func(ctx *gin.Context) {
+		ctx.Header("Deprecation", deprecation)
+		if sunsetHeader != "" {
+			ctx.Header("Sunset", sunsetHeader)
+		}
+		ctx.Header("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", successorPrefix, ctx.Request.URL.EscapedPath()))
+		ctx.Next()
+	}
+}
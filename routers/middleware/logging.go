@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"gin-boilerplate/infra/logger"
+	"gin-boilerplate/infra/requestid"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// quietPaths are probed constantly, so their requests are only logged at
+// debug level.
+var quietPaths = map[string]bool{
+	"/healthz": true,
+	"/health":  true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// RequestLogger logs one structured entry per request with its request ID,
+// route, status and latency. The request ID and route are also added to the
+// request context, so handlers logging with
+// logger.FromContext(ctx.Request.Context()) share them. It must run after
+// RequestID, and after the tracing middleware for entries to carry the trace
+// ID.
+func RequestLogger() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx.Request = ctx.Request.WithContext(logger.WithFields(ctx.Request.Context(), logger.Fields{
+			"request_id": requestid.FromContext(ctx.Request.Context()),
+			"method":     ctx.Request.Method,
+			"route":      route,
+		}))
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		fields := logger.Fields{
+			"status":     status,
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"bytes":      ctx.Writer.Size(),
+			"client_ip":  ctx.ClientIP(),
+			"path":       ctx.Request.URL.Path,
+		}
+		if len(ctx.Errors) > 0 {
+			fields["errors"] = ctx.Errors.String()
+		}
+		entry := logger.FromContext(ctx.Request.Context()).WithFields(map[string]interface{}(fields))
+
+		switch {
+		case status >= http.StatusInternalServerError:
+			entry.Error("request")
+		case status >= http.StatusBadRequest:
+			entry.Warn("request")
+		case quietPaths[ctx.Request.URL.Path]:
+			entry.Debug("request")
+		default:
+			entry.Info("request")
+		}
+	}
+}
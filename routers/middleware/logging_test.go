@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"gin-boilerplate/infra/logger"
+	"gin-boilerplate/infra/logger/loggertest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLogger(t *testing.T) {
+	out := loggertest.Capture(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), RequestLogger())
+	var handlerFields map[string]interface{}
+	router.GET("/items/:id", func(ctx *gin.Context) {
+		handlerFields = logger.FromContext(ctx.Request.Context()).Data
+		ctx.Status(http.StatusNotFound)
+	})
+	router.GET("/healthz", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/items/3?q=secret", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, "req-1", handlerFields["request_id"])
+	assert.Equal(t, "/items/:id", handlerFields["route"])
+
+	// The health probe is only logged at debug level.
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 1)
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(lines[0], &entry))
+	assert.Equal(t, "warning", entry["level"])
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.Equal(t, "GET", entry["method"])
+	assert.Equal(t, "/items/:id", entry["route"])
+	assert.Equal(t, "/items/3", entry["path"])
+	assert.Equal(t, float64(404), entry["status"])
+	assert.Contains(t, entry, "latency_ms")
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"gin-boilerplate/infra/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDKey is the gin context key holding the request ID.
+const RequestIDKey = "request_id"
+
+// RequestID reuses a valid incoming X-Request-ID or generates one, stores it
+// in the gin and request contexts and returns it on the response, errors
+// included. It must run before the other middleware so they see the ID.
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		ctx.Set(RequestIDKey, id)
+		ctx.Request = ctx.Request.WithContext(requestid.NewContext(ctx.Request.Context(), id))
+		ctx.Header(requestid.Header, id)
+		ctx.Next()
+	}
+}
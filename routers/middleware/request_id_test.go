@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"encoding/json"
+	"gin-boilerplate/apperror"
+	"gin-boilerplate/helpers"
+	"gin-boilerplate/infra/requestid"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID())
+	var seen string
+	router.GET("/items/:id", func(ctx *gin.Context) {
+		seen = requestid.FromContext(ctx.Request.Context())
+		assert.Equal(t, seen, ctx.GetString(RequestIDKey))
+		helpers.Problem(ctx, apperror.NotFound("item_not_found", "item not found"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	req.Header.Set(requestid.Header, "req-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, "req-1", seen)
+	assert.Equal(t, "req-1", rec.Header().Get(requestid.Header))
+	var problem helpers.ProblemDetails
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, "req-1", problem.RequestID)
+
+	// An ID that is not safe to log or put in a SQL comment is replaced.
+	req = httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	req.Header.Set(requestid.Header, "x */ DROP TABLE transactions; /*")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.True(t, requestid.Valid(seen))
+	assert.NotEqual(t, "x */ DROP TABLE transactions; /*", seen)
+	assert.Equal(t, seen, rec.Header().Get(requestid.Header))
+}
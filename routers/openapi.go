@@ -0,0 +1,536 @@
+package routers
+
+import (
+	"encoding/json"
+	"gin-boilerplate/config"
+	"gin-boilerplate/controllers"
+	"gin-boilerplate/graphqlapi"
+	"gin-boilerplate/helpers"
+	"gin-boilerplate/infra/health"
+	"gin-boilerplate/infra/logger"
+	"gin-boilerplate/models"
+	"gin-boilerplate/openapi"
+	"gin-boilerplate/repository"
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+)
+
+var apiInfo = openapi.Info{
+	Title:       "Transaction API",
+	Description: "Transactions, dashboards and webhooks. Errors are application/problem+json (RFC 7807).",
+	Version:     "1.0.0",
+}
+
+// problemDescriptions are the responses every error status is documented with.
+var problemDescriptions = map[string]string{
+	"400": "Invalid input",
+	"404": "Not found",
+	"409": "Conflict",
+	"412": "If-Match does not match the current version",
+	"413": "Upload too large",
+	"428": "If-Match required",
+	"503": "Database or feature unavailable",
+}
+
+// BuildOpenAPI documents routes, returning the routes without an operation
+// and the operations without a route. The v1 operations are documented under
+// /api/v1 and, when legacy routes are enabled, as deprecated aliases at the
+// root.
+func BuildOpenAPI(routes gin.RoutesInfo, legacy config.LegacyRoutesConfiguration) (*openapi.Document, []string, []string) {
+	g := openapi.NewGenerator()
+	g.Define(gorm.DeletedAt{}, &openapi.Schema{Type: "string", Format: "date-time", Nullable: true})
+	g.Define(models.JSONRaw{}, &openapi.Schema{Description: "Raw JSON value"})
+	g.Define(models.StringList{}, &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string"}})
+	g.Define(models.JSONMap{}, &openapi.Schema{Type: "object", AdditionalProperties: true})
+	g.Name(graphqlapi.Request{}, "GraphQLRequest")
+
+	list := make([]openapi.Route, 0, len(routes))
+	for _, route := range routes {
+		list = append(list, openapi.Route{Method: route.Method, Path: route.Path})
+	}
+
+	operations := systemOperations(g)
+	for key, op := range v1Operations(g) {
+		method, path := splitKey(key)
+		operations[method+" "+apiV1Prefix+path] = op
+		if legacy.Enabled {
+			operations[key] = legacyAlias(op, method+" "+apiV1Prefix+path, legacy)
+		}
+	}
+	return openapi.Build(apiInfo, list, operations, g)
+}
+
+func splitKey(key string) (method, path string) {
+	i := strings.Index(key, " ")
+	return key[:i], key[i+1:]
+}
+
+// legacyAlias documents an unversioned route as a deprecated copy of its
+// successor operation, with the headers added by middleware.Deprecated.
+func legacyAlias(op *openapi.Operation, successor string, legacy config.LegacyRoutesConfiguration) *openapi.Operation {
+	alias := *op
+	alias.OperationID = op.OperationID + "Legacy"
+	alias.Deprecated = true
+	alias.Description = "Deprecated alias of " + successor + "."
+	if !legacy.Sunset.IsZero() {
+		alias.Description += " Removed after " + legacy.Sunset.Format("2006-01-02") + "."
+	}
+	if op.Description != "" {
+		alias.Description += " " + op.Description
+	}
+
+	headers := map[string]openapi.Header{
+		"Deprecation": {Description: "When this route was deprecated (RFC 9745)", Schema: &openapi.Schema{Type: "string"}},
+		"Link":        {Description: "successor-version link to the /api/v1 route", Schema: &openapi.Schema{Type: "string"}},
+	}
+	if !legacy.Sunset.IsZero() {
+		headers["Sunset"] = openapi.Header{Description: "When this route will be removed (RFC 8594)", Schema: &openapi.Schema{Type: "string"}}
+	}
+
+	alias.Responses = map[string]openapi.Response{}
+	for status, response := range op.Responses {
+		merged := map[string]openapi.Header{}
+		for name, h := range response.Headers {
+			merged[name] = h
+		}
+		for name, h := range headers {
+			merged[name] = h
+		}
+		response.Headers = merged
+		alias.Responses[status] = response
+	}
+	return &alias
+}
+
+// serveOpenAPI registers GET /openapi.json and the Swagger UI on GET /docs.
+// It must be called after every other route is registered.
+func serveOpenAPI(route *gin.Engine, legacy config.LegacyRoutesConfiguration) {
+	var spec []byte
+	route.GET("/openapi.json", func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, "application/json", spec)
+	})
+	route.GET("/docs", func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUI))
+	})
+	route.GET("/docs/swagger-ui.css", func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, "text/css; charset=utf-8", swaggerFiles.FileSwaggerUICSS)
+	})
+	route.GET("/docs/swagger-ui-bundle.js", func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, "text/javascript; charset=utf-8", swaggerFiles.FileSwaggerUIBundleJs)
+	})
+
+	doc, undocumented, _ := BuildOpenAPI(route.Routes(), legacy)
+	if len(undocumented) > 0 {
+		logger.Warnf("routes missing from the OpenAPI document: %v", undocumented)
+	}
+	var err error
+	if spec, err = json.Marshal(doc); err != nil {
+		logger.Fatalf("openapi marshal error: %s", err)
+	}
+}
+
+// swaggerUI loads Swagger UI from this server rather than a CDN. The assets
+// come from github.com/swaggo/files, so their version is pinned, and checked,
+// by go.sum.
+const swaggerUI = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Transaction API</title>
+  <link rel="stylesheet" href="/docs/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="/docs/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+func jsonContent(schema *openapi.Schema) map[string]openapi.MediaType {
+	return map[string]openapi.MediaType{"application/json": {Schema: schema}}
+}
+
+// envelope wraps data in the helpers.APIResponse body of success responses.
+func envelope(data *openapi.Schema) *openapi.Schema {
+	return &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"status":  {Type: "string", Enum: []interface{}{"success"}},
+			"message": {Type: "string"},
+			"data":    data,
+		},
+		Required: []string{"status", "message", "data"},
+	}
+}
+
+// page is the data of paginated list responses.
+func page(item *openapi.Schema) *openapi.Schema {
+	return &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"page_number":        {Type: "integer"},
+			"page_size":          {Type: "integer"},
+			"total_record_count": {Type: "integer", Format: "int64"},
+			"data":               {Type: "array", Items: item},
+		},
+	}
+}
+
+func object(properties map[string]*openapi.Schema) *openapi.Schema {
+	return &openapi.Schema{Type: "object", Properties: properties}
+}
+
+// responses builds the responses of an operation: ok plus the problem
+// response of every listed status.
+func responses(g *openapi.Generator, status string, ok openapi.Response, problems ...string) map[string]openapi.Response {
+	problem := map[string]openapi.MediaType{helpers.ProblemContentType: {Schema: g.Schema(helpers.ProblemDetails{})}}
+
+	out := map[string]openapi.Response{status: ok}
+	for _, code := range problems {
+		out[code] = openapi.Response{Description: problemDescriptions[code], Content: problem}
+	}
+	out["500"] = openapi.Response{Description: "Internal error", Content: problem}
+	return out
+}
+
+func success(description string, data *openapi.Schema) openapi.Response {
+	return openapi.Response{Description: description, Content: jsonContent(envelope(data))}
+}
+
+func withETag(response openapi.Response) openapi.Response {
+	response.Headers = map[string]openapi.Header{
+		"ETag": {Description: "Current version, for If-Match", Schema: &openapi.Schema{Type: "string"}},
+	}
+	return response
+}
+
+func query(name, typ, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: typ}}
+}
+
+func header(name, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "header", Description: description, Schema: &openapi.Schema{Type: "string"}}
+}
+
+func jsonBody(schema *openapi.Schema) *openapi.RequestBody {
+	return &openapi.RequestBody{Required: true, Content: jsonContent(schema)}
+}
+
+var (
+	ifMatch           = header("If-Match", "Expected version (ETag) of the transaction")
+	transactionFilter = []openapi.Parameter{
+		query("status", "string", "Only transactions with this status"),
+		query("user_id", "integer", "Only transactions of this user"),
+		query("include_deleted", "boolean", "Include soft deleted transactions; refused unless INCLUDE_DELETED_ENABLED is set"),
+	}
+	pagination = []openapi.Parameter{
+		query("page_number", "integer", "Page number, default 1"),
+		query("page_size", "integer", "Page size, default 10"),
+	}
+)
+
+func params(groups ...[]openapi.Parameter) []openapi.Parameter {
+	var out []openapi.Parameter
+	for _, group := range groups {
+		out = append(out, group...)
+	}
+	return out
+}
+
+// systemOperations describes the unversioned routes, keyed by method and gin
+// path.
+func systemOperations(g *openapi.Generator) map[string]*openapi.Operation {
+	return map[string]*openapi.Operation{
+		"GET /healthz": {
+			OperationID: "healthz",
+			Tags:        []string{"system"},
+			Summary:     "Liveness check; does not touch dependencies",
+			Responses:   map[string]openapi.Response{"200": {Description: "Running", Content: jsonContent(object(map[string]*openapi.Schema{"status": {Type: "string", Enum: []interface{}{"ok"}}}))}},
+		},
+		"GET /health": {
+			OperationID: "health",
+			Tags:        []string{"system"},
+			Summary:     "Liveness check",
+			Description: "Deprecated alias of GET /healthz.",
+			Deprecated:  true,
+			Responses:   map[string]openapi.Response{"200": {Description: "Running", Content: jsonContent(object(map[string]*openapi.Schema{"status": {Type: "string", Enum: []interface{}{"ok"}}}))}},
+		},
+		"GET /readyz": {
+			OperationID: "ready",
+			Tags:        []string{"system"},
+			Summary:     "Readiness check of the server, the master and replica databases and the schema version",
+			Responses: map[string]openapi.Response{
+				"200": {Description: "Every check passed", Content: jsonContent(g.Schema(health.Report{}))},
+				"503": {Description: "A check failed, or the server is starting or shutting down", Content: jsonContent(g.Schema(health.Report{}))},
+			},
+		},
+		"GET /metrics": {
+			OperationID: "metrics",
+			Tags:        []string{"system"},
+			Summary:     "Prometheus metrics",
+			Responses:   map[string]openapi.Response{"200": {Description: "Prometheus text format", Content: map[string]openapi.MediaType{"text/plain": {Schema: &openapi.Schema{Type: "string"}}}}},
+		},
+		"GET /openapi.json": {
+			OperationID: "openapi",
+			Tags:        []string{"system"},
+			Summary:     "This OpenAPI document",
+			Responses:   map[string]openapi.Response{"200": {Description: "OpenAPI 3 document", Content: jsonContent(&openapi.Schema{Type: "object"})}},
+		},
+		"GET /docs": {
+			OperationID: "docs",
+			Tags:        []string{"system"},
+			Summary:     "Swagger UI for this API",
+			Responses:   map[string]openapi.Response{"200": {Description: "HTML page", Content: map[string]openapi.MediaType{"text/html": {}}}},
+		},
+		"GET /docs/swagger-ui.css": {
+			OperationID: "docsStylesheet",
+			Tags:        []string{"system"},
+			Summary:     "Stylesheet of the Swagger UI",
+			Responses:   map[string]openapi.Response{"200": {Description: "CSS", Content: map[string]openapi.MediaType{"text/css": {}}}},
+		},
+		"GET /docs/swagger-ui-bundle.js": {
+			OperationID: "docsScript",
+			Tags:        []string{"system"},
+			Summary:     "Script of the Swagger UI",
+			Responses:   map[string]openapi.Response{"200": {Description: "JavaScript", Content: map[string]openapi.MediaType{"text/javascript": {}}}},
+		},
+	}
+}
+
+// v1Operations describes the routes of API v1, keyed by method and gin path
+// relative to the version prefix.
+func v1Operations(g *openapi.Generator) map[string]*openapi.Operation {
+	transaction := g.Schema(models.Transaction{})
+	webhook := g.Schema(models.WebhookSubscription{})
+	delivery := g.Schema(models.WebhookDelivery{})
+
+	return map[string]*openapi.Operation{
+		"GET /transaction": {
+			OperationID: "listTransactions",
+			Tags:        []string{"transactions"},
+			Summary:     "List transactions",
+			Parameters:  params(pagination, transactionFilter),
+			Responses:   responses(g, "200", success("A page of transactions", page(transaction)), "400", "503"),
+		},
+		"GET /transaction/export": {
+			OperationID: "exportTransactions",
+			Tags:        []string{"transactions"},
+			Summary:     "Export transactions as CSV, XLSX or NDJSON",
+			Description: "Streams every matching transaction. The row count is sent in the X-Export-Rows trailer, or X-Export-Error when the export failed after it started.",
+			Parameters: params([]openapi.Parameter{
+				{Name: "format", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []interface{}{"csv", "xlsx", "ndjson"}, Default: "csv"}},
+				query("columns", "string", "Comma separated columns"),
+				query("tz", "string", "IANA time zone of the time columns"),
+				query("limit", "integer", "Maximum rows, capped by EXPORT_MAX_ROWS"),
+			}, transactionFilter),
+			Responses: responses(g, "200", openapi.Response{
+				Description: "Export file",
+				Content: map[string]openapi.MediaType{
+					"text/csv":             {Schema: &openapi.Schema{Type: "string"}},
+					"application/x-ndjson": {Schema: &openapi.Schema{Type: "string"}},
+					"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+				},
+			}, "400", "503"),
+		},
+		"GET /transaction/stream": {
+			OperationID: "streamTransactions",
+			Tags:        []string{"transactions"},
+			Summary:     "Live transaction events (Server-Sent Events)",
+			Parameters: []openapi.Parameter{
+				query("status", "string", "Only transactions with this status"),
+				query("user_id", "integer", "Only transactions of this user"),
+				header("Last-Event-ID", "Resume after this event"),
+				query("last_event_id", "integer", "Resume after this event, for clients that cannot send headers"),
+			},
+			Responses: responses(g, "200", openapi.Response{
+				Description: "Event stream",
+				Content:     map[string]openapi.MediaType{"text/event-stream": {Schema: &openapi.Schema{Type: "string"}}},
+			}, "400", "503"),
+		},
+		"GET /transaction/:id": {
+			OperationID: "getTransaction",
+			Tags:        []string{"transactions"},
+			Summary:     "Get a transaction",
+			Responses:   responses(g, "200", withETag(success("The transaction", transaction)), "400", "404", "503"),
+		},
+		"POST /transaction": {
+			OperationID: "createTransaction",
+			Tags:        []string{"transactions"},
+			Summary:     "Create a transaction",
+			RequestBody: jsonBody(g.Schema(controllers.CreateTransactionRequest{})),
+			Responses:   responses(g, "200", success("The created transaction", transaction), "400", "409", "503"),
+		},
+		"PUT /transaction/:id": {
+			OperationID: "updateTransactionStatus",
+			Tags:        []string{"transactions"},
+			Summary:     "Change the status of a transaction",
+			Parameters:  []openapi.Parameter{ifMatch},
+			RequestBody: jsonBody(g.Schema(controllers.UpdateStatusRequest{})),
+			Responses:   responses(g, "200", withETag(success("The updated transaction", transaction)), "400", "404", "412", "428", "503"),
+		},
+		"PATCH /transaction/:id": {
+			OperationID: "patchTransaction",
+			Tags:        []string{"transactions"},
+			Summary:     "Change description, metadata, reference or amount (JSON Merge Patch)",
+			Parameters:  []openapi.Parameter{ifMatch},
+			RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+				"application/merge-patch+json": {Schema: &openapi.Schema{Type: "object"}},
+				"application/json":             {Schema: &openapi.Schema{Type: "object"}},
+			}},
+			Responses: responses(g, "200", withETag(success("The patched transaction", transaction)), "400", "404", "409", "412", "428", "503"),
+		},
+		"DELETE /transaction/:id": {
+			OperationID: "deleteTransaction",
+			Tags:        []string{"transactions"},
+			Summary:     "Soft delete a transaction",
+			Parameters:  []openapi.Parameter{ifMatch},
+			Responses:   responses(g, "200", success("Deleted", &openapi.Schema{Nullable: true}), "400", "404", "412", "428", "503"),
+		},
+		"POST /transaction/:id/restore": {
+			OperationID: "restoreTransaction",
+			Tags:        []string{"transactions"},
+			Summary:     "Restore a soft deleted transaction",
+			Responses:   responses(g, "200", withETag(success("The restored transaction", transaction)), "400", "404", "503"),
+		},
+		"POST /transaction/import": {
+			OperationID: "importTransactions",
+			Tags:        []string{"transactions"},
+			Summary:     "Bulk import transactions from CSV or NDJSON",
+			Parameters: []openapi.Parameter{
+				{Name: "format", In: "query", Description: "Defaults to the file extension or content type", Schema: &openapi.Schema{Type: "string", Enum: []interface{}{"csv", "ndjson"}}},
+				query("dry_run", "boolean", "Validate without saving"),
+			},
+			RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+				"text/csv":             {Schema: &openapi.Schema{Type: "string"}},
+				"application/x-ndjson": {Schema: &openapi.Schema{Type: "string"}},
+				"multipart/form-data": {Schema: object(map[string]*openapi.Schema{
+					"file": {Type: "string", Format: "binary"},
+				})},
+			}},
+			Responses: responses(g, "200", success("Import report", g.Schema(controllers.ImportReport{})), "400", "413", "503"),
+		},
+		"POST /transaction/batch": {
+			OperationID: "batchTransactions",
+			Tags:        []string{"transactions"},
+			Summary:     "Create transactions and change statuses in one request",
+			RequestBody: jsonBody(g.Schema(controllers.BatchRequest{})),
+			Responses:   responses(g, "200", success("Result of every operation", g.Schema(controllers.BatchResponse{})), "400", "503"),
+		},
+
+		"GET /dashboard/summary": {
+			OperationID: "getDashboardSummary",
+			Tags:        []string{"dashboard"},
+			Summary:     "Dashboard aggregates",
+			Responses:   responses(g, "200", success("Summary", g.Schema(repository.TransactionSummary{})), "503"),
+		},
+		"GET /dashboard/report": {
+			OperationID: "getDashboardReport",
+			Tags:        []string{"dashboard"},
+			Summary:     "Successful transactions today and the latest transactions",
+			Responses: responses(g, "200", success("Report", object(map[string]*openapi.Schema{
+				"total_success_today":          {Type: "integer"},
+				"average_transaction_per_user": {Type: "number"},
+				"latest_transactions":          {Type: "array", Items: transaction},
+			})), "503"),
+		},
+		"GET /dashboard/live": {
+			OperationID: "dashboardLive",
+			Tags:        []string{"dashboard"},
+			Summary:     "Live dashboard metrics over WebSocket",
+			Description: "Send DashboardRequest messages; the server answers with DashboardMessage snapshots and deltas.",
+			Responses: responses(g, "101", openapi.Response{
+				Description: "Switching to WebSocket",
+				Content: jsonContent(&openapi.Schema{
+					Description: "Messages exchanged on the socket",
+					Type:        "object",
+					Properties: map[string]*openapi.Schema{
+						"request": g.Schema(controllers.DashboardRequest{}),
+						"message": g.Schema(controllers.DashboardMessage{}),
+					},
+				}),
+			}, "400", "503"),
+		},
+
+		"GET /graphql": {
+			OperationID: "graphqlGet",
+			Tags:        []string{"graphql"},
+			Summary:     "Run a GraphQL query",
+			Parameters: []openapi.Parameter{
+				{Name: "query", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}},
+				query("operationName", "string", ""),
+				query("variables", "string", "JSON encoded variables"),
+			},
+			Responses: graphqlResponses(),
+		},
+		"POST /graphql": {
+			OperationID: "graphqlPost",
+			Tags:        []string{"graphql"},
+			Summary:     "Run a GraphQL query",
+			RequestBody: jsonBody(g.Schema(graphqlapi.Request{})),
+			Responses:   graphqlResponses(),
+		},
+
+		"POST /webhooks": {
+			OperationID: "createWebhook",
+			Tags:        []string{"webhooks"},
+			Summary:     "Subscribe to transaction events",
+			RequestBody: jsonBody(g.Schema(controllers.WebhookRequest{})),
+			Responses:   responses(g, "201", success("The subscription, including its secret", webhook), "400", "409", "503"),
+		},
+		"GET /webhooks": {
+			OperationID: "listWebhooks",
+			Tags:        []string{"webhooks"},
+			Summary:     "List subscriptions",
+			Responses:   responses(g, "200", success("Subscriptions", &openapi.Schema{Type: "array", Items: webhook}), "503"),
+		},
+		"GET /webhooks/:id": {
+			OperationID: "getWebhook",
+			Tags:        []string{"webhooks"},
+			Summary:     "Get a subscription",
+			Responses:   responses(g, "200", success("The subscription", webhook), "400", "404", "503"),
+		},
+		"PUT /webhooks/:id": {
+			OperationID: "updateWebhook",
+			Tags:        []string{"webhooks"},
+			Summary:     "Replace a subscription",
+			RequestBody: jsonBody(g.Schema(controllers.WebhookRequest{})),
+			Responses:   responses(g, "200", success("The subscription", webhook), "400", "404", "409", "503"),
+		},
+		"DELETE /webhooks/:id": {
+			OperationID: "deleteWebhook",
+			Tags:        []string{"webhooks"},
+			Summary:     "Delete a subscription and its deliveries",
+			Responses:   responses(g, "200", success("Deleted", &openapi.Schema{Nullable: true}), "400", "404", "503"),
+		},
+		"GET /webhooks/:id/deliveries": {
+			OperationID: "listWebhookDeliveries",
+			Tags:        []string{"webhooks"},
+			Summary:     "Delivery log of a subscription, newest first",
+			Parameters:  pagination,
+			Responses:   responses(g, "200", success("A page of deliveries", page(delivery)), "400", "404", "503"),
+		},
+		"POST /webhooks/:id/deliveries/:delivery_id/redeliver": {
+			OperationID: "redeliverWebhook",
+			Tags:        []string{"webhooks"},
+			Summary:     "Send the payload of a delivery again",
+			Responses:   responses(g, "202", success("The new delivery", delivery), "400", "404", "503"),
+		},
+	}
+}
+
+func graphqlResponses() map[string]openapi.Response {
+	result := jsonContent(object(map[string]*openapi.Schema{
+		"data":   {Type: "object", Nullable: true},
+		"errors": {Type: "array", Items: &openapi.Schema{Type: "object"}},
+	}))
+	return map[string]openapi.Response{
+		"200": {Description: "Executed; resolver errors are listed in errors", Content: result},
+		"400": {Description: "Malformed, invalid or over the complexity limits", Content: result},
+	}
+}
@@ -0,0 +1,188 @@
+package routers
+
+import (
+	"encoding/json"
+	"gin-boilerplate/config"
+	"gin-boilerplate/events"
+	"gin-boilerplate/openapi"
+	"gin-boilerplate/webhooks"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterRoutes(router, Services{Config: config.Default(), Webhooks: &webhooks.Service{}})
+	return router
+}
+
+// TestOpenAPI_MatchesRoutes fails when a route is added without documenting
+// it in v1Operations or systemOperations, or a documented operation no
+// longer has a route.
+func TestOpenAPI_MatchesRoutes(t *testing.T) {
+	router := newTestRouter()
+
+	doc, undocumented, unused := BuildOpenAPI(router.Routes(), config.Default().LegacyRoutes)
+	assert.Empty(t, undocumented, "routes missing from v1Operations and systemOperations")
+	assert.Empty(t, unused, "v1Operations or systemOperations entries without a route")
+
+	operationIDs := map[string]string{}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			key := method + " " + path
+			require.NotEmpty(t, op.OperationID, key)
+			if other, ok := operationIDs[op.OperationID]; ok {
+				t.Errorf("operationId %q used by %s and %s", op.OperationID, other, key)
+			}
+			operationIDs[op.OperationID] = key
+		}
+	}
+}
+
+func TestOpenAPI_Served(t *testing.T) {
+	router := newTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc openapi.Document
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+
+	get := doc.Paths["/api/v1/transaction/{id}"]["get"]
+	require.NotNil(t, get)
+	require.Len(t, get.Parameters, 1)
+	assert.Equal(t, "id", get.Parameters[0].Name)
+	assert.Equal(t, "path", get.Parameters[0].In)
+	assert.False(t, get.Deprecated)
+
+	legacy := doc.Paths["/transaction/{id}"]["get"]
+	require.NotNil(t, legacy)
+	assert.True(t, legacy.Deprecated)
+	assert.Equal(t, "getTransactionLegacy", legacy.OperationID)
+	assert.Contains(t, legacy.Responses["200"].Headers, "Sunset")
+	assert.Contains(t, legacy.Responses["200"].Headers, "ETag")
+	assert.Empty(t, get.Responses["200"].Headers["Sunset"].Description, "the v1 operation must not be modified")
+
+	create := doc.Components.Schemas["CreateTransactionRequest"]
+	require.NotNil(t, create)
+	assert.ElementsMatch(t, []string{"user_id", "amount"}, create.Required)
+	assert.True(t, create.Properties["amount"].ExclusiveMinimum)
+
+	webhook := doc.Components.Schemas["WebhookRequest"]
+	require.NotNil(t, webhook)
+	assert.Equal(t, "uri", webhook.Properties["url"].Format)
+	assert.Len(t, webhook.Properties["events"].Items.Enum, 4)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/docs", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "/openapi.json")
+	assert.NotContains(t, w.Body.String(), "https://", "Swagger UI is served locally")
+
+	for _, asset := range []string{"/docs/swagger-ui.css", "/docs/swagger-ui-bundle.js"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, asset, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, asset)
+		assert.NotEmpty(t, w.Body.Bytes(), asset)
+	}
+}
+
+// TestOpenAPI_MatchesHandlers sends an invalid value for every documented
+// path parameter and every integer, boolean or enum query parameter, and
+// fails unless the handler rejects it naming that parameter with a
+// documented 400. A parameter documented under the wrong name, or on an
+// operation whose handler never reads it, is caught this way.
+func TestOpenAPI_MatchesHandlers(t *testing.T) {
+	cfg := config.Default()
+	cfg.Transaction.IncludeDeletedEnabled = true
+	router := gin.New()
+	RegisterRoutes(router, Services{Config: cfg, Webhooks: &webhooks.Service{}, Live: events.NewHub(1, 1)})
+	doc, _, _ := BuildOpenAPI(router.Routes(), cfg.LegacyRoutes)
+
+	for path, item := range doc.Paths {
+		if !strings.HasPrefix(path, apiV1Prefix) {
+			continue
+		}
+		for method, op := range item {
+			for _, param := range op.Parameters {
+				var invalid string
+				switch {
+				case param.In == "path":
+					invalid = "abc"
+				case param.In == "query" && (param.Schema.Type == "integer" || param.Schema.Type == "boolean" || len(param.Schema.Enum) > 0):
+					invalid = "bogus"
+				default:
+					continue
+				}
+
+				target := path
+				for _, other := range op.Parameters {
+					if other.In == "path" {
+						value := "1"
+						if other.Name == param.Name {
+							value = invalid
+						}
+						target = strings.Replace(target, "{"+other.Name+"}", value, 1)
+					}
+				}
+				if param.In == "query" {
+					target += "?" + url.Values{param.Name: {invalid}}.Encode()
+				}
+
+				name := strings.ToUpper(method) + " " + target
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest(strings.ToUpper(method), target, strings.NewReader("{}"))
+				req.Header.Set("Content-Type", "application/json")
+				router.ServeHTTP(w, req)
+
+				if assert.Equal(t, http.StatusBadRequest, w.Code, "%s: %s", name, w.Body.String()) {
+					assert.Contains(t, w.Body.String(), `"field":"`+param.Name+`"`, name)
+					assert.Contains(t, op.Responses, "400", "%s: 400 not documented", name)
+				}
+			}
+		}
+	}
+}
+
+// TestOpenAPI_DocumentsIfMatchRequired checks that every operation whose
+// handler answers 428 without If-Match documents both the header and 428.
+func TestOpenAPI_DocumentsIfMatchRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Server.RequireIfMatch = true
+	router := gin.New()
+	RegisterRoutes(router, Services{Config: cfg, Webhooks: &webhooks.Service{}})
+	doc, _, _ := BuildOpenAPI(router.Routes(), cfg.LegacyRoutes)
+
+	for _, key := range []string{"put /api/v1/transaction/{id}", "patch /api/v1/transaction/{id}", "delete /api/v1/transaction/{id}"} {
+		method, path := splitKey(key)
+		op := doc.Paths[path][method]
+		require.NotNil(t, op, key)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(strings.ToUpper(method), strings.Replace(path, "{id}", "1", 1), strings.NewReader(`{"status":"success","description":"x"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code, key)
+		assert.Contains(t, op.Responses, "428", key)
+		var documented bool
+		for _, param := range op.Parameters {
+			documented = documented || (param.In == "header" && param.Name == "If-Match")
+		}
+		assert.True(t, documented, "%s: If-Match not documented", key)
+	}
+}
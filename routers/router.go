@@ -1,28 +1,30 @@
 package routers
 
 import (
+	"gin-boilerplate/infra/metrics"
+	"gin-boilerplate/infra/tracing"
 	"gin-boilerplate/routers/middleware"
 	"github.com/gin-gonic/gin"
-	"github.com/spf13/viper"
 )
 
-func SetupRoute() *gin.Engine {
+func SetupRoute(services Services) *gin.Engine {
 
-	environment := viper.GetBool("DEBUG")
-	if environment {
+	if services.Config.Server.Debug {
 		gin.SetMode(gin.DebugMode)
 	} else {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	allowedHosts := viper.GetString("ALLOWED_HOSTS")
 	router := gin.New()
-	router.SetTrustedProxies([]string{allowedHosts})
-	router.Use(gin.Logger())
+	router.SetTrustedProxies(services.Config.Server.AllowedHosts)
+	router.Use(middleware.RequestID())
+	router.Use(tracing.Middleware(services.Config.Tracing.ServiceName))
+	router.Use(metrics.Middleware())
+	router.Use(middleware.RequestLogger())
 	router.Use(gin.Recovery())
-	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.CORSMiddleware(services.Config.Server.AllowedOrigins))
 
-	RegisterRoutes(router) //routes register
+	RegisterRoutes(router, services) //routes register
 
 	return router
 }
@@ -0,0 +1,82 @@
+package routers
+
+import (
+	"gin-boilerplate/controllers"
+	"gin-boilerplate/graphqlapi"
+	"gin-boilerplate/infra/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// v1Routes builds the controllers of API v1 and returns a function that
+// registers its routes on a group. Every version has its own builder, so a
+// v2 can bring its own controllers and response shapes while v1 keeps
+// serving the old ones unchanged.
+func v1Routes(services Services) func(r gin.IRoutes) {
+	// Inisialisasi Repository dan Controller
+	transactionRepo := services.Transactions
+	transactionConfig := services.Config.Transaction
+	transactionController := &controllers.TransactionController{
+		Repo:           transactionRepo,
+		RequireIfMatch: services.Config.Server.RequireIfMatch,
+		AllowedOrigins: services.Config.Server.AllowedOrigins,
+		CreateRules: controllers.CreateTransactionRules{
+			MaxAmount:       transactionConfig.MaxAmount,
+			InitialStatuses: transactionConfig.InitialStatuses,
+		},
+		ExportMaxRows:         transactionConfig.ExportMaxRows,
+		ImportBatchSize:       transactionConfig.ImportBatchSize,
+		ImportMaxRows:         transactionConfig.ImportMaxRows,
+		ImportMaxBytes:        transactionConfig.ImportMaxBytes,
+		BatchMaxOperations:    transactionConfig.BatchMaxOperations,
+		IncludeDeletedEnabled: transactionConfig.IncludeDeletedEnabled,
+		Live:                  services.Live,
+		StreamHeartbeat:       services.Config.Stream.Heartbeat,
+		DashboardChanges:      services.DashboardChanges,
+		DashboardResync:       services.Config.Stream.DashboardResync,
+		Closing:               services.Lifecycle.Closing(),
+	}
+	webhookController := &controllers.WebhookController{
+		Repo:    services.Webhooks.Repo,
+		Service: services.Webhooks,
+	}
+
+	graphqlConfig := services.Config.GraphQL
+	graphqlHandler, err := graphqlapi.NewHandler(transactionRepo, graphqlapi.Limits{
+		MaxComplexity:  graphqlConfig.MaxComplexity,
+		MaxDepth:       graphqlConfig.MaxDepth,
+		MaxPageSize:    graphqlConfig.MaxPageSize,
+		IncludeDeleted: transactionConfig.IncludeDeletedEnabled,
+	})
+	if err != nil {
+		logger.Fatalf("graphql schema error: %s", err)
+	}
+
+	return func(r gin.IRoutes) {
+		r.GET("/transaction", transactionController.GetTransactions)
+		r.GET("/transaction/export", transactionController.ExportTransactions)
+		r.GET("/transaction/stream", transactionController.StreamTransactions)
+		r.GET("/transaction/:id", transactionController.GetTransactionByID)
+		r.GET("/dashboard/summary", transactionController.GetDashboardSummary)
+		r.GET("/dashboard/live", transactionController.DashboardLive)
+		r.DELETE("/transaction/:id", transactionController.DeleteTransaction)
+		r.POST("/transaction/:id/restore", transactionController.RestoreTransaction)
+		r.PUT("/transaction/:id", transactionController.UpdateTransactionStatus)
+		r.PATCH("/transaction/:id", transactionController.PatchTransaction)
+		r.POST("/transaction", transactionController.CreateTransaction)
+		r.POST("/transaction/import", transactionController.ImportTransactions)
+		r.POST("/transaction/batch", transactionController.BatchTransactions)
+		r.GET("/dashboard/report", transactionController.GetDashboardReport)
+
+		r.GET("/graphql", graphqlHandler.Serve)
+		r.POST("/graphql", graphqlHandler.Serve)
+
+		r.POST("/webhooks", webhookController.CreateWebhook)
+		r.GET("/webhooks", webhookController.GetWebhooks)
+		r.GET("/webhooks/:id", webhookController.GetWebhookByID)
+		r.PUT("/webhooks/:id", webhookController.UpdateWebhook)
+		r.DELETE("/webhooks/:id", webhookController.DeleteWebhook)
+		r.GET("/webhooks/:id/deliveries", webhookController.GetWebhookDeliveries)
+		r.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", webhookController.RedeliverWebhook)
+	}
+}
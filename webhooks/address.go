@@ -0,0 +1,77 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned for webhook URLs that resolve to a loopback,
+// private, link-local or otherwise internal address, so subscribers cannot
+// make the server call into its own network.
+var ErrPrivateAddress = errors.New("webhook URL must resolve to a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), internal
+// like the RFC 1918 ranges but not covered by net.IP.IsPrivate.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP reports whether ip may be called by the delivery worker.
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
+
+// CheckURL resolves the host of rawURL and returns ErrPrivateAddress unless
+// every address it resolves to is public. The dialer checks again on every
+// delivery, in case DNS changed since.
+func (s *Service) CheckURL(ctx context.Context, rawURL string) error {
+	if s.Config.AllowPrivateAddresses {
+		return nil
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, err)
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// newClient returns the delivery client. Unless allowPrivate is set its
+// dialer refuses non-public addresses after resolving, and it ignores proxy
+// settings, which would otherwise hide the real destination from the check.
+func newClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	if allowPrivate {
+		return &http.Client{Timeout: timeout}
+	}
+
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,267 @@
+// Package webhooks delivers transaction events to subscribed HTTP endpoints.
+// Every event is stored as one delivery per matching subscription and sent
+// by a background worker, which retries failures with exponential backoff.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-boilerplate/events"
+	"gin-boilerplate/infra/logger"
+	"gin-boilerplate/infra/requestid"
+	"gin-boilerplate/models"
+	"gin-boilerplate/repository"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Config controls delivery and retries. Attempt n (counting from 1) that
+// fails is retried after BaseBackoff * 2^(n-1), capped at MaxBackoff, until
+// MaxAttempts have been made. AllowPrivateAddresses lets webhooks call
+// loopback and internal addresses, for local development only.
+type Config struct {
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	Timeout      time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+
+	AllowPrivateAddresses bool
+}
+
+// DefaultConfig is used for every zero field of the Config given to NewService.
+var DefaultConfig = Config{
+	MaxAttempts:  8,
+	BaseBackoff:  30 * time.Second,
+	MaxBackoff:   6 * time.Hour,
+	Timeout:      10 * time.Second,
+	PollInterval: 5 * time.Second,
+	BatchSize:    50,
+}
+
+type Service struct {
+	Repo   repository.WebhookRepository
+	Client *http.Client
+	Config Config
+
+	now func() time.Time
+}
+
+func NewService(repo repository.WebhookRepository, config Config) *Service {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultConfig.MaxAttempts
+	}
+	if config.BaseBackoff <= 0 {
+		config.BaseBackoff = DefaultConfig.BaseBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultConfig.MaxBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultConfig.Timeout
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultConfig.PollInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultConfig.BatchSize
+	}
+
+	return &Service{
+		Repo:   repo,
+		Client: newClient(config.Timeout, config.AllowPrivateAddresses),
+		Config: config,
+		now:    time.Now,
+	}
+}
+
+// Publish implements events.Publisher by enqueueing event. An event
+// published twice is delivered twice with the same X-Webhook-Event-Id.
+func (s *Service) Publish(ctx context.Context, event events.Event) error {
+	return s.Enqueue(event)
+}
+
+// Enqueue stores a pending delivery of event for every matching subscription.
+func (s *Service) Enqueue(event events.Event) error {
+	subscriptions, err := s.Repo.ListActiveSubscriptions()
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	var deliveries []*models.WebhookDelivery
+	for _, subscription := range subscriptions {
+		if !subscription.Matches(event.Type) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(event); err != nil {
+				return err
+			}
+		}
+		deliveries = append(deliveries, &models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			Event:          event.Type,
+			Payload:        payload,
+			Status:         models.DeliveryPending,
+			NextAttemptAt:  s.now(),
+			RequestID:      event.RequestID,
+		})
+	}
+	return s.Repo.CreateDeliveries(deliveries)
+}
+
+// Redeliver queues a fresh copy of a delivery, keeping the original in the
+// log untouched.
+func (s *Service) Redeliver(ctx context.Context, subscriptionID, deliveryID int) (*models.WebhookDelivery, error) {
+	repo := s.Repo.WithContext(ctx)
+	original, err := repo.GetDelivery(subscriptionID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := &models.WebhookDelivery{
+		SubscriptionID: original.SubscriptionID,
+		EventID:        original.EventID,
+		Event:          original.Event,
+		Payload:        original.Payload,
+		Status:         models.DeliveryPending,
+		NextAttemptAt:  s.now(),
+		RequestID:      original.RequestID,
+	}
+	if err := repo.CreateDeliveries([]*models.WebhookDelivery{delivery}); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// Run sends due deliveries every PollInterval until ctx is cancelled.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.ProcessDue(ctx); err != nil {
+			logger.Errorf("webhook delivery error: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessDue sends one batch of due deliveries and returns how many were
+// attempted.
+func (s *Service) ProcessDue(ctx context.Context) (int, error) {
+	// The lease must outlast a whole batch of timed out requests.
+	lease := s.Config.Timeout*time.Duration(s.Config.BatchSize) + time.Minute
+	deliveries, err := s.Repo.ClaimDueDeliveries(s.now(), s.Config.BatchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	subscriptions := map[uint]*models.WebhookSubscription{}
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			return i, ctx.Err()
+		}
+
+		delivery := &deliveries[i]
+		subscription, ok := subscriptions[delivery.SubscriptionID]
+		if !ok {
+			subscription, err = s.Repo.GetSubscriptionByID(int(delivery.SubscriptionID))
+			if err != nil && !errors.Is(err, repository.ErrWebhookNotFound) {
+				return i, err
+			}
+			subscriptions[delivery.SubscriptionID] = subscription
+		}
+
+		s.attempt(ctx, subscription, delivery)
+		if err := s.Repo.SaveDelivery(delivery); err != nil {
+			return i + 1, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// attempt sends delivery once and records the outcome on it.
+func (s *Service) attempt(ctx context.Context, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) {
+	if subscription == nil || !subscription.Active {
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = "subscription deleted or inactive"
+		return
+	}
+
+	delivery.Attempts++
+	status, err := s.send(ctx, subscription, delivery)
+	delivery.ResponseStatus = status
+
+	if err == nil {
+		now := s.now()
+		delivery.Status = models.DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= s.Config.MaxAttempts {
+		delivery.Status = models.DeliveryFailed
+		return
+	}
+	delivery.NextAttemptAt = s.now().Add(s.backoff(delivery.Attempts))
+}
+
+func (s *Service) send(ctx context.Context, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := s.now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gin-boilerplate-webhooks/1")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderEventID, delivery.EventID)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(subscription.Secret, timestamp, body))
+	requestid.SetHeader(req, delivery.RequestID)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// The body is not kept: the delivery log is readable by whoever
+	// registered the URL. Draining some of it lets the connection be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (s *Service) backoff(attempts int) time.Duration {
+	wait := s.Config.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= s.Config.MaxBackoff {
+			return s.Config.MaxBackoff
+		}
+	}
+	return wait
+}
@@ -0,0 +1,349 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"gin-boilerplate/events"
+	"gin-boilerplate/models"
+	"gin-boilerplate/repository"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryRepo is an in-memory WebhookRepository.
+type memoryRepo struct {
+	mu            sync.Mutex
+	subscriptions []models.WebhookSubscription
+	deliveries    []models.WebhookDelivery
+}
+
+func (m *memoryRepo) WithContext(ctx context.Context) repository.WebhookRepository {
+	return m
+}
+
+func (m *memoryRepo) CreateSubscription(subscription *models.WebhookSubscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscription.ID = uint(len(m.subscriptions) + 1)
+	m.subscriptions = append(m.subscriptions, *subscription)
+	return nil
+}
+
+func (m *memoryRepo) GetSubscriptionByID(id int) (*models.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, subscription := range m.subscriptions {
+		if int(subscription.ID) == id {
+			return &subscription, nil
+		}
+	}
+	return nil, repository.ErrWebhookNotFound
+}
+
+func (m *memoryRepo) ListSubscriptions() ([]models.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.WebhookSubscription(nil), m.subscriptions...), nil
+}
+
+func (m *memoryRepo) ListActiveSubscriptions() ([]models.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var active []models.WebhookSubscription
+	for _, subscription := range m.subscriptions {
+		if subscription.Active {
+			active = append(active, subscription)
+		}
+	}
+	return active, nil
+}
+
+func (m *memoryRepo) UpdateSubscription(subscription *models.WebhookSubscription) error {
+	panic("not used")
+}
+
+func (m *memoryRepo) DeleteSubscriptionByID(id int) error {
+	panic("not used")
+}
+
+func (m *memoryRepo) CreateDeliveries(deliveries []*models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, delivery := range deliveries {
+		delivery.ID = uint(len(m.deliveries) + 1)
+		m.deliveries = append(m.deliveries, *delivery)
+	}
+	return nil
+}
+
+func (m *memoryRepo) GetDelivery(subscriptionID, id int) (*models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, delivery := range m.deliveries {
+		if int(delivery.ID) == id && int(delivery.SubscriptionID) == subscriptionID {
+			return &delivery, nil
+		}
+	}
+	return nil, repository.ErrDeliveryNotFound
+}
+
+func (m *memoryRepo) ListDeliveries(deliveries *[]models.WebhookDelivery, subscriptionID, pageNumber, pageSize int) (int64, error) {
+	panic("not used")
+}
+
+func (m *memoryRepo) ClaimDueDeliveries(now time.Time, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []models.WebhookDelivery
+	for i := range m.deliveries {
+		delivery := &m.deliveries[i]
+		if len(due) < limit && delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			delivery.NextAttemptAt = now.Add(lease)
+			due = append(due, *delivery)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryRepo) SaveDelivery(delivery *models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries[delivery.ID-1] = *delivery
+	return nil
+}
+
+func (m *memoryRepo) delivery(id uint) models.WebhookDelivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.deliveries[id-1]
+}
+
+// receiver records the requests it gets and answers with the next status in
+// statuses, then 200, each with a body.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+	w.Write(bytes.Repeat([]byte("received\n"), 1<<10))
+}
+
+func newTestService(t *testing.T, rc *receiver, events ...string) (*Service, *memoryRepo, *time.Time) {
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+
+	repo := &memoryRepo{}
+	require.NoError(t, repo.CreateSubscription(&models.WebhookSubscription{
+		URL:    server.URL,
+		Secret: "test-secret-0123456789",
+		Events: events,
+		Active: true,
+	}))
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	service := NewService(repo, Config{MaxAttempts: 3, BaseBackoff: time.Minute, MaxBackoff: 90 * time.Second, AllowPrivateAddresses: true})
+	service.now = func() time.Time { return now }
+	return service, repo, &now
+}
+
+func TestDeliverySignedPayload(t *testing.T) {
+	rc := &receiver{}
+	service, repo, _ := newTestService(t, rc, events.TransactionCreated)
+
+	event := events.New(events.TransactionCreated, &models.Transaction{ID: 7, UserID: 1, Amount: 100, Status: "pending"})
+	event.RequestID = "req-1"
+	require.NoError(t, service.Enqueue(event))
+	// Not subscribed, so no delivery is queued.
+	require.NoError(t, service.Enqueue(events.New(events.TransactionDeleted, events.Deleted{ID: 7})))
+
+	attempted, err := service.ProcessDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempted)
+
+	require.Len(t, rc.requests, 1)
+	req, body := rc.requests[0], rc.bodies[0]
+	assert.Equal(t, events.TransactionCreated, req.Header.Get(HeaderEvent))
+	assert.Equal(t, event.ID, req.Header.Get(HeaderEventID))
+	assert.Equal(t, "1", req.Header.Get(HeaderDelivery))
+	assert.Equal(t, "req-1", req.Header.Get("X-Request-ID"))
+
+	timestamp, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+	require.NoError(t, err)
+	assert.True(t, Verify("test-secret-0123456789", timestamp, body, req.Header.Get(HeaderSignature)))
+	assert.False(t, Verify("other-secret", timestamp, body, req.Header.Get(HeaderSignature)))
+
+	var payload struct {
+		ID   string             `json:"id"`
+		Type string             `json:"type"`
+		Data models.Transaction `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, event.ID, payload.ID)
+	assert.Equal(t, uint(7), payload.Data.ID)
+
+	delivery := repo.delivery(1)
+	assert.Equal(t, models.DeliverySucceeded, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusOK, delivery.ResponseStatus)
+	assert.NotNil(t, delivery.DeliveredAt)
+}
+
+func TestDeliveryRetriesWithBackoff(t *testing.T) {
+	rc := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}}
+	service, repo, now := newTestService(t, rc)
+
+	require.NoError(t, service.Enqueue(events.New(events.TransactionDeleted, events.Deleted{ID: 1})))
+
+	_, err := service.ProcessDue(context.Background())
+	require.NoError(t, err)
+	delivery := repo.delivery(1)
+	assert.Equal(t, models.DeliveryPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, delivery.ResponseStatus)
+	assert.Equal(t, now.Add(time.Minute), delivery.NextAttemptAt)
+
+	// Not due yet.
+	attempted, err := service.ProcessDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, attempted)
+
+	*now = now.Add(time.Minute)
+	_, err = service.ProcessDue(context.Background())
+	require.NoError(t, err)
+	delivery = repo.delivery(1)
+	assert.Equal(t, 2, delivery.Attempts)
+	// 2 minutes, capped at MaxBackoff.
+	assert.Equal(t, now.Add(90*time.Second), delivery.NextAttemptAt)
+
+	*now = now.Add(90 * time.Second)
+	_, err = service.ProcessDue(context.Background())
+	require.NoError(t, err)
+	delivery = repo.delivery(1)
+	assert.Equal(t, models.DeliveryFailed, delivery.Status)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Equal(t, "unexpected status 503", delivery.LastError)
+
+	// A manual redelivery starts over and succeeds.
+	redelivery, err := service.Redeliver(context.Background(), 1, 1)
+	require.NoError(t, err)
+	_, err = service.ProcessDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, models.DeliverySucceeded, repo.delivery(redelivery.ID).Status)
+	assert.Equal(t, models.DeliveryFailed, repo.delivery(1).Status)
+	assert.Len(t, rc.requests, 4)
+}
+
+// drainRecorder records how much of each response body was read before it
+// was closed.
+type drainRecorder struct {
+	mu      sync.Mutex
+	drained []bool
+}
+
+func (d *drainRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		resp.Body = &recordedBody{ReadCloser: resp.Body, recorder: d}
+	}
+	return resp, err
+}
+
+type recordedBody struct {
+	io.ReadCloser
+	recorder *drainRecorder
+	eof      bool
+}
+
+func (b *recordedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.eof = b.eof || err == io.EOF
+	return n, err
+}
+
+func (b *recordedBody) Close() error {
+	b.recorder.mu.Lock()
+	b.recorder.drained = append(b.recorder.drained, b.eof)
+	b.recorder.mu.Unlock()
+	return b.ReadCloser.Close()
+}
+
+func TestDeliveryDrainsResponses(t *testing.T) {
+	rc := &receiver{statuses: []int{http.StatusInternalServerError}}
+	service, _, now := newTestService(t, rc)
+	recorder := &drainRecorder{}
+	service.Client = &http.Client{Transport: recorder}
+
+	require.NoError(t, service.Enqueue(events.New(events.TransactionDeleted, events.Deleted{ID: 1})))
+	_, err := service.ProcessDue(context.Background())
+	require.NoError(t, err)
+	*now = now.Add(time.Minute)
+	_, err = service.ProcessDue(context.Background())
+	require.NoError(t, err)
+
+	// Read to the end, so the keep-alive connection can be reused.
+	assert.Equal(t, []bool{true, true}, recorder.drained)
+	require.Len(t, rc.requests, 2)
+	assert.Equal(t, rc.requests[0].RemoteAddr, rc.requests[1].RemoteAddr)
+}
+
+func TestDeliveryRefusesPrivateAddresses(t *testing.T) {
+	rc := &receiver{}
+	service, repo, _ := newTestService(t, rc)
+	service.Config.AllowPrivateAddresses = false
+	service.Client = newClient(time.Second, false)
+
+	require.NoError(t, service.Enqueue(events.New(events.TransactionDeleted, events.Deleted{ID: 1})))
+	_, err := service.ProcessDue(context.Background())
+	require.NoError(t, err)
+
+	delivery := repo.delivery(1)
+	assert.Equal(t, models.DeliveryPending, delivery.Status)
+	assert.Contains(t, delivery.LastError, ErrPrivateAddress.Error())
+	assert.Empty(t, rc.requests)
+}
+
+func TestCheckURL(t *testing.T) {
+	service := NewService(&memoryRepo{}, Config{})
+
+	for _, url := range []string{
+		"http://127.0.0.1:8000/hook",
+		"http://localhost/hook",
+		"http://10.1.2.3/hook",
+		"http://192.168.0.10/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://100.64.0.1/hook",
+		"http://[::1]/hook",
+		"http://[fe80::1]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		assert.ErrorIs(t, service.CheckURL(context.Background(), url), ErrPrivateAddress, url)
+	}
+	assert.NoError(t, service.CheckURL(context.Background(), "https://93.184.216.34/hook"))
+
+	service.Config.AllowPrivateAddresses = true
+	assert.NoError(t, service.CheckURL(context.Background(), "http://127.0.0.1:8000/hook"))
+}